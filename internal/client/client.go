@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -79,7 +80,7 @@ func (client *Client) initMasterClient(conf *config.Config) error {
 		return err
 	}
 
-	client.master = &masterClient{client: client, Store: etcdStore, cfg: conf}
+	client.master = &masterClient{client: client, Store: etcdStore, cfg: conf, verified: newVerifiedCache()}
 	masterServer.init(len(conf.Masters))
 	return nil
 }
@@ -158,9 +159,37 @@ func (r *routerRequest) SetMethod(method string) *routerRequest {
 // SetHead Set head
 func (r *routerRequest) SetHead(head *vearchpb.RequestHead) *routerRequest {
 	r.head = head
+	if priority := head.GetParams()[PriorityParam]; priority != "" {
+		r.md[PriorityParam] = priority
+	}
+	if token := head.GetParams()[ConsistencyTokenParam]; token != "" {
+		r.md[ConsistencyTokenParam] = token
+	}
+	if consistency := head.GetParams()[ConsistencyParam]; consistency != "" {
+		r.md[ConsistencyParam] = consistency
+	}
 	return r
 }
 
+// setConsistencyToken puts the applied indexes in the replies of a write to
+// md, they are returned in response head params
+func (r *routerRequest) setConsistencyToken(replies []*vearchpb.PartitionData) {
+	token := make(entity.ConsistencyToken)
+	for _, reply := range replies {
+		if len(reply.Data) == 0 {
+			continue
+		}
+		if index, err := strconv.ParseUint(string(reply.Data), 10, 64); err == nil {
+			token.Add(entity.PartitionID(reply.PartitionID), index)
+		}
+	}
+	if len(token) > 0 {
+		r.md[ConsistencyTokenParam] = token.String()
+	} else {
+		delete(r.md, ConsistencyTokenParam)
+	}
+}
+
 // SetSpace set space by dbName and spaceName
 func (r *routerRequest) SetSpace() *routerRequest {
 	if r.Err != nil {
@@ -353,10 +382,13 @@ func (r *routerRequest) Execute() []*vearchpb.Item {
 	wg.Wait()
 	close(respChain)
 	tmpItems := make([]*vearchpb.Item, 0)
+	replies := make([]*vearchpb.PartitionData, 0, len(r.sendMap))
 	for resp := range respChain {
 		setPartitionErr(resp)
 		tmpItems = append(tmpItems, resp.Items...)
+		replies = append(replies, resp)
 	}
+	r.setConsistencyToken(replies)
 	docIndexMap := make(map[string]int, len(r.docs))
 	for i, doc := range r.docs {
 		docIndexMap[doc.PKey] = i
@@ -388,6 +420,7 @@ func (r *routerRequest) searchFromPartition(ctx context.Context, partitionID ent
 			searchResponse := &vearchpb.SearchResponse{Head: head}
 			pd.SearchResponse = searchResponse
 			responseDoc.PartitionData = pd
+			responseDoc.Failure = partitionFailure(partitionID, 0, nil, pd)
 			respChain <- responseDoc
 		}
 	}()
@@ -407,6 +440,7 @@ func (r *routerRequest) searchFromPartition(ctx context.Context, partitionID ent
 		searchResponse := &vearchpb.SearchResponse{Head: head}
 		pd.SearchResponse = searchResponse
 		responseDoc.PartitionData = pd
+		responseDoc.Failure = partitionFailure(partitionID, 0, nil, pd)
 		respChain <- responseDoc
 		return
 	}
@@ -441,6 +475,8 @@ func (r *routerRequest) searchFromPartition(ctx context.Context, partitionID ent
 
 	rpcEnd, rpcStart := time.Now(), time.Now()
 	nodeID := GetNodeIdsByClientType(clientType, partition, servers, r.client)
+	tried := make(map[entity.NodeID]bool)
+	rpcErr := fmt.Errorf("no available replica of partition:[%d]", partitionID)
 
 	for len(partition.Replicas) > r.client.PS().faultyList.ItemCount() {
 		if r.client.PS().TestFaulty(nodeID) {
@@ -466,6 +502,7 @@ func (r *routerRequest) searchFromPartition(ctx context.Context, partitionID ent
 			searchResponse := &vearchpb.SearchResponse{Head: head}
 			pd.SearchResponse = searchResponse
 			responseDoc.PartitionData = pd
+			responseDoc.Failure = partitionFailure(partitionID, nodeID, nil, pd)
 			respChain <- responseDoc
 			return
 		}
@@ -534,9 +571,15 @@ func (r *routerRequest) searchFromPartition(ctx context.Context, partitionID ent
 			}
 		}
 		rpcStart = time.Now()
-		err := rpcClient.Execute(ctx, UnaryHandler, pd, replyPartition)
+		var err error
+		nodeID, replyPartition, err = r.executeHedged(ctx, rpcClient, clientType, partition, nodeID, pd, replyPartition, tried)
 		rpcEnd = time.Now()
+		rpcErr = err
 		if err == nil {
+			if next, ok := r.retryOverloaded(clientType, partition, nodeID, replyPartition, tried); ok {
+				nodeID = next
+				continue
+			}
 			break
 		}
 
@@ -605,6 +648,10 @@ func (r *routerRequest) searchFromPartition(ctx context.Context, partitionID ent
 	}
 	responseDoc.PartitionData = replyPartition
 	responseDoc.SortValueMap = sortValueMap
+	if reportPartitions(pd.SearchRequest.Head) {
+		responseDoc.Explain = partitionExplain(partitionID, nodeID, rpcEnd.Sub(rpcStart), searchResponse)
+	}
+	responseDoc.Failure = partitionFailure(partitionID, nodeID, rpcErr, replyPartition)
 	respChain <- responseDoc
 }
 
@@ -663,30 +710,10 @@ func (r *routerRequest) SearchFieldSortExecute(sortOrder sortorder.SortOrder) *v
 		searchPartitionsStr = strconv.FormatFloat(searchPartitions, 'f', 4, 64)
 	}
 
-	var result []*vearchpb.SearchResult
-	var sortValueMap map[string][]sortorder.SortValue
-	var searchResponse *vearchpb.SearchResponse
-
 	mergeStartTime := time.Now()
-	for r := range respChain {
-		if result == nil && r != nil {
-			searchResponse = r.PartitionData.SearchResponse
-			if searchResponse != nil && searchResponse.Results != nil && len(searchResponse.Results) > 0 {
-				result = searchResponse.Results
-				sortValueMap = r.SortValueMap
-				continue
-			}
-		}
-
-		sortValue := r.SortValueMap
-		for PKey, sortValue := range sortValue {
-			sortValueMap[PKey] = sortValue
-		}
-		var err error
-		if err = AddMergeResultArr(result, r.PartitionData.SearchResponse.Results); err != nil {
-			log.Error("msearch AddMergeResultArr error:", err)
-		}
-	}
+	merged := mergePartitionResults(respChain)
+	result, sortValueMap, searchResponse := merged.results, merged.sortValueMap, merged.response
+	explains, failures := merged.explains, merged.failures
 
 	if len(result) > 1 {
 		var wg sync.WaitGroup
@@ -742,7 +769,9 @@ func (r *routerRequest) SearchFieldSortExecute(sortOrder sortorder.SortOrder) *v
 		searchExecuteStr := strconv.FormatFloat(searchExecute, 'f', 4, 64)
 		searchResponse.Head.Params["searchExecute"] = searchExecuteStr
 	}
+	setExplain(searchResponse, explains)
 	searchResponse.Results = result
+	setFailures(searchResponse, searchReq.Head, failures, len(sendPartitionMap))
 	return searchResponse
 }
 
@@ -756,6 +785,7 @@ func (r *routerRequest) queryFromPartition(ctx context.Context, partitionID enti
 			searchResponse := &vearchpb.SearchResponse{Head: head}
 			pd.SearchResponse = searchResponse
 			responseDoc.PartitionData = pd
+			responseDoc.Failure = partitionFailure(partitionID, 0, nil, pd)
 			respChain <- responseDoc
 		}
 	}()
@@ -767,6 +797,7 @@ func (r *routerRequest) queryFromPartition(ctx context.Context, partitionID enti
 		searchResponse := &vearchpb.SearchResponse{Head: head}
 		pd.SearchResponse = searchResponse
 		responseDoc.PartitionData = pd
+		responseDoc.Failure = partitionFailure(partitionID, 0, nil, pd)
 		respChain <- responseDoc
 		return
 	}
@@ -792,7 +823,10 @@ func (r *routerRequest) queryFromPartition(ctx context.Context, partitionID enti
 	// ensure node is alive
 	servers := r.client.Master().Cache().serverCache
 
+	rpcEnd, rpcStart := time.Now(), time.Now()
 	nodeID := GetNodeIdsByClientType(clientType, partition, servers, r.client)
+	tried := make(map[entity.NodeID]bool)
+	rpcErr := fmt.Errorf("no available replica of partition:[%d]", partitionID)
 
 	for len(partition.Replicas) > r.client.PS().faultyList.ItemCount() {
 		if r.client.PS().TestFaulty(nodeID) {
@@ -807,12 +841,21 @@ func (r *routerRequest) queryFromPartition(ctx context.Context, partitionID enti
 			searchResponse := &vearchpb.SearchResponse{Head: head}
 			pd.SearchResponse = searchResponse
 			responseDoc.PartitionData = pd
+			responseDoc.Failure = partitionFailure(partitionID, nodeID, nil, pd)
 			respChain <- responseDoc
 			return
 		}
 
-		err := rpcClient.Execute(ctx, UnaryHandler, pd, replyPartition)
+		rpcStart = time.Now()
+		var err error
+		nodeID, replyPartition, err = r.executeHedged(ctx, rpcClient, clientType, partition, nodeID, pd, replyPartition, tried)
+		rpcEnd = time.Now()
+		rpcErr = err
 		if err == nil {
+			if next, ok := r.retryOverloaded(clientType, partition, nodeID, replyPartition, tried); ok {
+				nodeID = next
+				continue
+			}
 			break
 		}
 
@@ -850,6 +893,10 @@ func (r *routerRequest) queryFromPartition(ctx context.Context, partitionID enti
 	}
 	responseDoc.PartitionData = replyPartition
 	responseDoc.SortValueMap = sortValueMap
+	if reportPartitions(pd.QueryRequest.Head) {
+		responseDoc.Explain = partitionExplain(partitionID, nodeID, rpcEnd.Sub(rpcStart), searchResponse)
+	}
+	responseDoc.Failure = partitionFailure(partitionID, nodeID, rpcErr, replyPartition)
 	respChain <- responseDoc
 }
 
@@ -871,29 +918,9 @@ func (r *routerRequest) QueryFieldSortExecute(sortOrder sortorder.SortOrder) *ve
 	wg.Wait()
 	close(respChain)
 
-	var result []*vearchpb.SearchResult
-	var sortValueMap map[string][]sortorder.SortValue
-	var searchResponse *vearchpb.SearchResponse
-
-	for r := range respChain {
-		if result == nil && r != nil {
-			searchResponse = r.PartitionData.SearchResponse
-			if searchResponse != nil && searchResponse.Results != nil && len(searchResponse.Results) > 0 {
-				result = searchResponse.Results
-				sortValueMap = r.SortValueMap
-				continue
-			}
-		}
-
-		sortValue := r.SortValueMap
-		for PKey, sortValue := range sortValue {
-			sortValueMap[PKey] = sortValue
-		}
-		var err error
-		if err = AddMergeResultArr(result, r.PartitionData.SearchResponse.Results); err != nil {
-			log.Error("query AddMergeResultArr error:", err)
-		}
-	}
+	merged := mergePartitionResults(respChain)
+	result, sortValueMap, searchResponse := merged.results, merged.sortValueMap, merged.response
+	explains, failures := merged.explains, merged.failures
 
 	if len(result) > 1 {
 		err := &vearchpb.Error{Code: vearchpb.ErrorEnum_ROUTER_CALL_PS_RPC_ERR, Msg: "the document_ids of query should be a one-dimensional array"}
@@ -920,7 +947,9 @@ func (r *routerRequest) QueryFieldSortExecute(sortOrder sortorder.SortOrder) *ve
 		responseHead := &vearchpb.ResponseHead{Err: err}
 		searchResponse.Head = responseHead
 	}
+	setExplain(searchResponse, explains)
 	searchResponse.Results = result
+	setFailures(searchResponse, searchReq.Head, failures, len(sendPartitionMap))
 	return searchResponse
 }
 
@@ -1044,6 +1073,51 @@ func (r *routerRequest) BulkSearchByPartitions(searchReq []*vearchpb.SearchReque
 	return r
 }
 
+// overloaded reports whether ps rejected the request by admission control,
+// it can be retried on another replica
+func overloaded(reply *vearchpb.PartitionData) bool {
+	if reply.Err != nil && reply.Err.Code == vearchpb.ErrorEnum_SERVICE_UNAVAILABLE {
+		return true
+	}
+	head := reply.SearchResponse.GetHead()
+	return head != nil && head.Err != nil && head.Err.Code == vearchpb.ErrorEnum_SERVICE_UNAVAILABLE
+}
+
+// retryOverloaded returns the replica to retry if nodeID rejected the
+// request by admission control, the reply is reset for the retry
+func (r *routerRequest) retryOverloaded(clientType string, partition *entity.Partition, nodeID entity.NodeID, reply *vearchpb.PartitionData, tried map[entity.NodeID]bool) (entity.NodeID, bool) {
+	if !overloaded(reply) {
+		return 0, false
+	}
+	tried[nodeID] = true
+	next, ok := r.otherReplica(clientType, partition, tried)
+	if !ok {
+		return 0, false
+	}
+	log.Warn("partition:[%d] nodeID:[%d] overloaded, retry nodeID:[%d]", partition.Id, nodeID, next)
+	reply.Err = nil
+	reply.SearchResponse = &vearchpb.SearchResponse{Head: &vearchpb.ResponseHead{Params: make(map[string]string)}}
+	return next, true
+}
+
+// otherReplica returns a replica of partition not tried and not faulty, the
+// requests which must read leader are not retried
+func (r *routerRequest) otherReplica(clientType string, partition *entity.Partition, tried map[entity.NodeID]bool) (entity.NodeID, bool) {
+	if clientType == "leader" {
+		return 0, false
+	}
+	for _, nodeID := range partition.Replicas {
+		if tried[nodeID] || r.client.PS().TestFaulty(nodeID) {
+			continue
+		}
+		if config.Conf().Global.RaftConsistent && partition.ReStatusMap[nodeID] != entity.ReplicasOK {
+			continue
+		}
+		return nodeID, true
+	}
+	return 0, false
+}
+
 var replicaRoundRobin = algorithm.NewRoundRobin[entity.PartitionID, entity.NodeID]()
 
 func GetNodeIdsByClientType(clientType string, partition *entity.Partition, servers *cache.Cache, client *Client) entity.NodeID {
@@ -1082,6 +1156,42 @@ func GetNodeIdsByClientType(clientType string, partition *entity.Partition, serv
 			}
 		}
 		nodeId = replicaRoundRobin.Next(partition.Id, randIDs)
+	case "zone_local":
+		// replicas in the zone of router first, the other zones only when
+		// none of them is usable
+		localIDs, otherIDs := make([]entity.NodeID, 0), make([]entity.NodeID, 0)
+		for _, nodeID := range partition.Replicas {
+			value, serverExist := servers.Get(cast.ToString(nodeID))
+			if !serverExist {
+				continue
+			}
+			if config.Conf().Global.RaftConsistent && partition.ReStatusMap[nodeID] != entity.ReplicasOK {
+				continue
+			}
+			if value.(*entity.Server).Zone == config.Conf().Router.Zone && !client.PS().TestFaulty(nodeID) {
+				localIDs = append(localIDs, nodeID)
+			} else {
+				otherIDs = append(otherIDs, nodeID)
+			}
+		}
+		if len(localIDs) > 0 {
+			nodeId = replicaRoundRobin.Next(partition.Id, localIDs)
+		} else {
+			nodeId = replicaRoundRobin.Next(partition.Id, otherIDs)
+		}
+	case "latency_aware":
+		nodeIDs := make([]entity.NodeID, 0)
+		for _, nodeID := range partition.Replicas {
+			_, serverExist := servers.Get(cast.ToString(nodeID))
+			if !serverExist || client.PS().TestFaulty(nodeID) {
+				continue
+			}
+			if config.Conf().Global.RaftConsistent && partition.ReStatusMap[nodeID] != entity.ReplicasOK {
+				continue
+			}
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+		nodeId = client.PS().nodeByLatency(nodeIDs)
 	case "least_connection":
 		leastId := uint64(0)
 		most := 1<<32 - 1
@@ -1290,6 +1400,9 @@ func GetSource(doc *vearchpb.ResultItem, space *entity.Space, sortFieldMap map[s
 			default:
 				log.Warn("can not set value by type:[%v] ", field.FieldType)
 			}
+			if field.IsNull(fv.Value) {
+				source[name] = nil
+			}
 		}
 	}
 
@@ -1643,11 +1756,209 @@ func (r *routerRequest) DelByQueryeExecute() *vearchpb.DelByQueryeResponse {
 	close(respChain)
 
 	delByQueryResponse := &vearchpb.DelByQueryeResponse{}
+	replies := make([]*vearchpb.PartitionData, 0, partitionLen)
 	for resp := range respChain {
 		if len(resp.DelByQueryResponse.IdsStr) > 0 {
 			delByQueryResponse.IdsStr = append(delByQueryResponse.IdsStr, resp.DelByQueryResponse.IdsStr...)
 		}
+		replies = append(replies, resp)
 	}
 	delByQueryResponse.DelNum = int32(len(delByQueryResponse.IdsStr))
+	r.setConsistencyToken(replies)
+	if token := r.md[ConsistencyTokenParam]; token != "" {
+		delByQueryResponse.Head = &vearchpb.ResponseHead{Params: map[string]string{ConsistencyTokenParam: token}}
+	}
 	return delByQueryResponse
 }
+
+// ExplainParam in request head params asks the partitions of a search or
+// query to report what they did, see response.PartitionExplain
+const ExplainParam = "explain"
+
+func IsExplain(head *vearchpb.RequestHead) bool {
+	return head != nil && head.Params != nil && head.Params[ExplainParam] == "true"
+}
+
+// PartitionTookParam in request head params asks the partitions of a search
+// or query to report what they did like ExplainParam, but the search itself
+// is not explained, slow log uses it for the latency of partitions
+const PartitionTookParam = "partition_took"
+
+func reportPartitions(head *vearchpb.RequestHead) bool {
+	return IsExplain(head) || (head != nil && head.Params != nil && head.Params[PartitionTookParam] == "true")
+}
+
+func partitionExplain(partitionID entity.PartitionID, nodeID entity.NodeID, took time.Duration, resp *vearchpb.SearchResponse) *response.PartitionExplain {
+	explain := &response.PartitionExplain{
+		PartitionID: uint32(partitionID),
+		NodeID:      uint64(nodeID),
+		Took:        took.Seconds() * 1000,
+		Candidates:  make([]int, 0),
+		TotalHits:   make([]int32, 0),
+	}
+	if resp == nil {
+		return explain
+	}
+	if resp.Head != nil && resp.Head.Err != nil && resp.Head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		explain.Error = resp.Head.Err.Msg
+	}
+	for _, result := range resp.Results {
+		explain.Candidates = append(explain.Candidates, len(result.ResultItems))
+		explain.TotalHits = append(explain.TotalHits, result.TotalHits)
+		explain.Timeout = explain.Timeout || result.Timeout
+	}
+	return explain
+}
+
+// setExplain puts the partition explains into response head params as
+// json, it is decoded by the router for the response
+func setExplain(searchResponse *vearchpb.SearchResponse, explains []*response.PartitionExplain) {
+	if len(explains) == 0 {
+		return
+	}
+	sort.Slice(explains, func(i, j int) bool { return explains[i].PartitionID < explains[j].PartitionID })
+	data, err := json.Marshal(explains)
+	if err != nil {
+		log.Error("marshal partition explain err: %s", err.Error())
+		return
+	}
+	if searchResponse.Head == nil {
+		searchResponse.Head = &vearchpb.ResponseHead{}
+	}
+	if searchResponse.Head.Params == nil {
+		searchResponse.Head.Params = make(map[string]string)
+	}
+	searchResponse.Head.Params[ExplainParam] = string(data)
+}
+
+// AllowPartialResultsParam in request head params tells if a search or query
+// returns the results of the partitions answered when others failed, the
+// router config is used if it is not set
+const AllowPartialResultsParam = "allow_partial_results"
+
+// FailedPartitionsParam in response head params is the json of the
+// partitions failed, see response.PartitionFailure
+const FailedPartitionsParam = "failed_partitions"
+
+func allowPartialResults(head *vearchpb.RequestHead) bool {
+	if head != nil && head.Params != nil {
+		if v, err := strconv.ParseBool(head.Params[AllowPartialResultsParam]); err == nil {
+			return v
+		}
+	}
+	cfg := config.Conf().Router
+	return cfg == nil || cfg.AllowPartialResults == nil || *cfg.AllowPartialResults
+}
+
+// partitionResults is the results of partitions merged
+type partitionResults struct {
+	response     *vearchpb.SearchResponse
+	results      []*vearchpb.SearchResult
+	sortValueMap map[string][]sortorder.SortValue
+	explains     []*response.PartitionExplain
+	failures     []*response.PartitionFailure
+}
+
+// mergePartitionResults merges the results of partitions answered, the
+// response of the first one with results is the base of merge. A failed
+// partition is not merged, its error is reported by the partial results
+// policy.
+func mergePartitionResults(respChain <-chan *response.SearchDocResult) *partitionResults {
+	merged := &partitionResults{
+		sortValueMap: make(map[string][]sortorder.SortValue),
+		explains:     make([]*response.PartitionExplain, 0),
+		failures:     make([]*response.PartitionFailure, 0),
+	}
+	for r := range respChain {
+		if r == nil {
+			continue
+		}
+		if r.Explain != nil {
+			merged.explains = append(merged.explains, r.Explain)
+		}
+		if r.Failure != nil {
+			merged.failures = append(merged.failures, r.Failure)
+			continue
+		}
+		if r.PartitionData == nil || r.PartitionData.SearchResponse == nil {
+			continue
+		}
+		for PKey, sortValue := range r.SortValueMap {
+			merged.sortValueMap[PKey] = sortValue
+		}
+		searchResponse := r.PartitionData.SearchResponse
+		if merged.results == nil {
+			merged.response = searchResponse
+			if len(searchResponse.Results) > 0 {
+				merged.results = searchResponse.Results
+			}
+			continue
+		}
+		if err := AddMergeResultArr(merged.results, searchResponse.Results); err != nil {
+			log.Error("merge partition results error: %v", err)
+		}
+	}
+	return merged
+}
+
+// partitionFailure returns why a partition failed, nil if it answered. err is
+// the rpc error of the last replica called.
+func partitionFailure(partitionID entity.PartitionID, nodeID entity.NodeID, err error, pd *vearchpb.PartitionData) *response.PartitionFailure {
+	failure := &response.PartitionFailure{PartitionID: uint32(partitionID), NodeID: uint64(nodeID)}
+	switch {
+	case err != nil:
+		code := vearchpb.ErrorEnum_ROUTER_CALL_PS_RPC_ERR
+		if errors.Is(err, context.DeadlineExceeded) {
+			code = vearchpb.ErrorEnum_TIMEOUT
+		}
+		failure.Code, failure.Msg = code.String(), err.Error()
+	case pd == nil:
+		failure.Code, failure.Msg = vearchpb.ErrorEnum_ROUTER_CALL_PS_RPC_ERR.String(), "no reply"
+	case pd.Err != nil && pd.Err.Code != vearchpb.ErrorEnum_SUCCESS:
+		failure.Code, failure.Msg = pd.Err.Code.String(), pd.Err.Msg
+	case pd.SearchResponse != nil && pd.SearchResponse.Head != nil && pd.SearchResponse.Head.Err != nil && pd.SearchResponse.Head.Err.Code != vearchpb.ErrorEnum_SUCCESS:
+		failure.Code, failure.Msg = pd.SearchResponse.Head.Err.Code.String(), pd.SearchResponse.Head.Err.Msg
+	default:
+		return nil
+	}
+	return failure
+}
+
+// setFailures applies the partial results policy, the failed partitions are
+// put into response head params. The response fails with all of them if
+// partial results are not allowed or no partition answered.
+func setFailures(searchResponse *vearchpb.SearchResponse, head *vearchpb.RequestHead, failures []*response.PartitionFailure, partitions int) {
+	if len(failures) == 0 {
+		return
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].PartitionID < failures[j].PartitionID })
+	if searchResponse.Head == nil {
+		searchResponse.Head = &vearchpb.ResponseHead{}
+	}
+	if searchResponse.Head.Params == nil {
+		searchResponse.Head.Params = make(map[string]string)
+	}
+	data, err := json.Marshal(failures)
+	if err != nil {
+		log.Error("marshal partition failures err: %s", err.Error())
+	} else {
+		searchResponse.Head.Params[FailedPartitionsParam] = string(data)
+	}
+
+	if len(failures) < partitions && allowPartialResults(head) {
+		return
+	}
+	msgs := make([]string, 0, len(failures))
+	for _, f := range failures {
+		msgs = append(msgs, fmt.Sprintf("partition:[%d] nodeID:[%d] %s: %s", f.PartitionID, f.NodeID, f.Code, f.Msg))
+	}
+	code, ok := vearchpb.ErrorEnum_value[failures[0].Code]
+	if !ok {
+		code = int32(vearchpb.ErrorEnum_ROUTER_CALL_PS_RPC_ERR)
+	}
+	searchResponse.Head.Err = &vearchpb.Error{
+		Code: vearchpb.ErrorEnum(code),
+		Msg:  fmt.Sprintf("%d of %d partitions failed: %s", len(failures), partitions, strings.Join(msgs, "; ")),
+	}
+	searchResponse.Results = nil
+}
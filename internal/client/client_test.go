@@ -0,0 +1,102 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// testConfig loads conf as the config of the test
+func testConfig(t *testing.T, conf string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(conf), 0644); err != nil {
+		t.Fatalf("write config err: %v", err)
+	}
+	config.InitConfig(path)
+}
+
+func newTestClient() *Client {
+	c := &Client{}
+	c.ps = &psClient{client: c, faultyList: cache.New(time.Second*30, time.Second*5)}
+	return c
+}
+
+func TestOverloaded(t *testing.T) {
+	unavailable := &vearchpb.Error{Code: vearchpb.ErrorEnum_SERVICE_UNAVAILABLE}
+	assert.True(t, overloaded(&vearchpb.PartitionData{Err: unavailable}))
+	assert.True(t, overloaded(&vearchpb.PartitionData{SearchResponse: &vearchpb.SearchResponse{Head: &vearchpb.ResponseHead{Err: unavailable}}}))
+	assert.False(t, overloaded(&vearchpb.PartitionData{}))
+	assert.False(t, overloaded(&vearchpb.PartitionData{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_INTERNAL_ERROR}}))
+	assert.False(t, overloaded(&vearchpb.PartitionData{SearchResponse: &vearchpb.SearchResponse{Head: &vearchpb.ResponseHead{}}}))
+}
+
+func TestRetryOverloaded(t *testing.T) {
+	testConfig(t, "[global]\nraft_consistent = false\n")
+	r := &routerRequest{client: newTestClient()}
+	partition := &entity.Partition{Id: 1, Replicas: []entity.NodeID{1, 2, 3}, LeaderID: 1}
+	overloadedReply := func() *vearchpb.PartitionData {
+		return &vearchpb.PartitionData{
+			Err:            &vearchpb.Error{Code: vearchpb.ErrorEnum_SERVICE_UNAVAILABLE},
+			SearchResponse: &vearchpb.SearchResponse{Head: &vearchpb.ResponseHead{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_SERVICE_UNAVAILABLE}}},
+		}
+	}
+
+	tried := make(map[entity.NodeID]bool)
+	_, ok := r.retryOverloaded("random", partition, 1, &vearchpb.PartitionData{}, tried)
+	assert.False(t, ok, "a reply not overloaded is not retried")
+	assert.Empty(t, tried)
+
+	// node 2 is faulty, the retry goes to node 3 with a reset reply
+	r.client.PS().AddFaulty(2, time.Minute)
+	reply := overloadedReply()
+	next, ok := r.retryOverloaded("random", partition, 1, reply, tried)
+	assert.True(t, ok)
+	assert.Equal(t, entity.NodeID(3), next)
+	assert.True(t, tried[1])
+	assert.Nil(t, reply.Err)
+	assert.False(t, overloaded(reply))
+
+	// every replica is tried or faulty
+	_, ok = r.retryOverloaded("random", partition, 3, overloadedReply(), tried)
+	assert.False(t, ok)
+	assert.True(t, tried[3])
+
+	// the requests of leader are not retried
+	_, ok = r.retryOverloaded("leader", partition, 1, overloadedReply(), make(map[entity.NodeID]bool))
+	assert.False(t, ok)
+}
+
+func TestOtherReplicaRaftConsistent(t *testing.T) {
+	testConfig(t, "[global]\nraft_consistent = true\n")
+	r := &routerRequest{client: newTestClient()}
+	partition := &entity.Partition{
+		Id:          1,
+		Replicas:    []entity.NodeID{1, 2, 3},
+		ReStatusMap: map[uint64]uint32{1: entity.ReplicasOK, 2: entity.ReplicasNotReady, 3: entity.ReplicasOK},
+	}
+	next, ok := r.otherReplica("random", partition, map[entity.NodeID]bool{1: true})
+	assert.True(t, ok)
+	assert.Equal(t, entity.NodeID(3), next, "a replica not in sync is skipped")
+}
@@ -0,0 +1,218 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	hedgeWindowSize      = 128
+	hedgeMinSamples      = 16
+	defaultHedgeMinDelay = 5 * time.Millisecond
+)
+
+var (
+	hedgeMetricsOnce sync.Once
+	hedgeRequests    = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vearch_router_hedged_requests_total",
+		Help: "router requests hedged to another replica",
+	}, []string{"load_balance", "result"})
+
+	rpcLatencies sync.Map // entity.PartitionID -> *latencyWindow
+
+	// rpcExecute sends a request of executeHedged and rpcClientOf gets the
+	// client of a replica, tests replace them
+	rpcExecute = func(ctx context.Context, client *rpcClient, pd, reply *vearchpb.PartitionData) error {
+		return client.Execute(ctx, UnaryHandler, pd, reply)
+	}
+	rpcClientOf = func(ctx context.Context, ps *psClient, nodeID entity.NodeID) *rpcClient {
+		return ps.GetOrCreateRPCClient(ctx, nodeID)
+	}
+)
+
+// latencyWindow keeps the latencies of the recent rpcs to a partition
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples [hedgeWindowSize]time.Duration
+	n       int
+}
+
+func latencyOf(partitionID entity.PartitionID) *latencyWindow {
+	if w, ok := rpcLatencies.Load(partitionID); ok {
+		return w.(*latencyWindow)
+	}
+	w, _ := rpcLatencies.LoadOrStore(partitionID, &latencyWindow{})
+	return w.(*latencyWindow)
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	w.mu.Lock()
+	w.samples[w.n%hedgeWindowSize] = d
+	w.n++
+	w.mu.Unlock()
+}
+
+// percentile returns the p-th percentile of the window, false if there are
+// too few samples to trust it
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mu.Lock()
+	count := min(w.n, hedgeWindowSize)
+	if count < hedgeMinSamples {
+		w.mu.Unlock()
+		return 0, false
+	}
+	samples := make([]time.Duration, count)
+	copy(samples, w.samples[:count])
+	w.mu.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	i := int(math.Ceil(p/100*float64(count))) - 1
+	return samples[min(max(i, 0), count-1)], true
+}
+
+// hedgePercentile returns the percentile of hedge delay of load balance, false
+// if hedging is off for it
+func hedgePercentile(loadBalance string) (float64, bool) {
+	cfg := config.Conf().Router
+	if cfg == nil || len(cfg.HedgePercentiles) == 0 {
+		return 0, false
+	}
+	p, ok := cfg.HedgePercentiles[loadBalance]
+	if !ok || p <= 0 || p >= 100 {
+		return 0, false
+	}
+	hedgeMetricsOnce.Do(func() {
+		if err := prometheus.Register(hedgeRequests); err != nil {
+			log.Warnf("register router hedge metrics err: %s", err.Error())
+		}
+	})
+	return p, true
+}
+
+type hedgeResult struct {
+	nodeID entity.NodeID
+	reply  *vearchpb.PartitionData
+	err    error
+	took   time.Duration
+}
+
+func (res *hedgeResult) ok() bool {
+	return res.err == nil && !overloaded(res.reply)
+}
+
+// executeHedged sends pd to nodeID, if the replica does not answer within the
+// hedge delay of partition the same request is sent to another replica. The
+// first answer wins and the context of the other request is cancelled, which
+// makes rpcClient.Execute tell ps to abort it. It returns the node and the
+// reply of the answer used.
+func (r *routerRequest) executeHedged(ctx context.Context, client *rpcClient, clientType string, partition *entity.Partition, nodeID entity.NodeID, pd *vearchpb.PartitionData, reply *vearchpb.PartitionData, tried map[entity.NodeID]bool) (entity.NodeID, *vearchpb.PartitionData, error) {
+	loadBalance := clientType
+	if loadBalance == "" {
+		loadBalance = "random"
+	}
+	p, enabled := hedgePercentile(loadBalance)
+	if !enabled {
+		start := time.Now()
+		err := rpcExecute(ctx, client, pd, reply)
+		// a request the client gave up on is not the fault of node
+		r.client.PS().observe(nodeID, time.Since(start), (err != nil && ctx.Err() == nil) || (err == nil && overloaded(reply)))
+		return nodeID, reply, err
+	}
+	window := latencyOf(partition.Id)
+
+	results := make(chan *hedgeResult, 2)
+	send := func(ctx context.Context, nodeID entity.NodeID, client *rpcClient, reply *vearchpb.PartitionData) {
+		go func() {
+			start := time.Now()
+			err := rpcExecute(ctx, client, pd, reply)
+			took := time.Since(start)
+			// the request cancelled as loser took at least the time
+			r.client.PS().observe(nodeID, took, (err != nil && ctx.Err() == nil) || (err == nil && overloaded(reply)))
+			results <- &hedgeResult{nodeID: nodeID, reply: reply, err: err, took: took}
+		}()
+	}
+	finish := func(res *hedgeResult) (entity.NodeID, *vearchpb.PartitionData, error) {
+		if res.ok() {
+			window.add(res.took)
+		}
+		return res.nodeID, res.reply, res.err
+	}
+
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	defer cancelPrimary()
+	send(primaryCtx, nodeID, client, reply)
+
+	delay, ok := window.percentile(p)
+	if !ok {
+		return finish(<-results)
+	}
+	minDelay := defaultHedgeMinDelay
+	if config.Conf().Router.HedgeMinDelayMs > 0 {
+		minDelay = time.Duration(config.Conf().Router.HedgeMinDelayMs) * time.Millisecond
+	}
+	timer := time.NewTimer(max(delay, minDelay))
+	defer timer.Stop()
+	select {
+	case res := <-results:
+		return finish(res)
+	case <-timer.C:
+	}
+
+	skip := map[entity.NodeID]bool{nodeID: true}
+	for id := range tried {
+		skip[id] = true
+	}
+	next, found := r.otherReplica(clientType, partition, skip)
+	if !found {
+		return finish(<-results)
+	}
+	hedgeClient := rpcClientOf(ctx, r.client.PS(), next)
+	if hedgeClient == nil {
+		return finish(<-results)
+	}
+	hedgeRequests.WithLabelValues(loadBalance, "fired").Inc()
+	log.Debug("partition:[%d] nodeID:[%d] not answered in %v, hedge to nodeID:[%d]", partition.Id, nodeID, max(delay, minDelay), next)
+
+	hedgeCtx, cancelHedge := context.WithCancel(ctx)
+	defer cancelHedge()
+	hedgeReply := &vearchpb.PartitionData{SearchResponse: &vearchpb.SearchResponse{Head: &vearchpb.ResponseHead{Params: make(map[string]string)}}}
+	for k, v := range reply.GetSearchResponse().GetHead().GetParams() {
+		hedgeReply.SearchResponse.Head.Params[k] = v
+	}
+	send(hedgeCtx, next, hedgeClient, hedgeReply)
+
+	// a failed answer does not win while the other one may still succeed
+	first := <-results
+	if !first.ok() {
+		if second := <-results; second.ok() {
+			first = second
+		}
+	}
+	if first.nodeID == next {
+		hedgeRequests.WithLabelValues(loadBalance, "won").Inc()
+	}
+	return finish(first)
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestLatencyWindowPercentile(t *testing.T) {
+	w := &latencyWindow{}
+	for i := 1; i < hedgeMinSamples; i++ {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+	_, ok := w.percentile(50)
+	assert.False(t, ok, "too few samples")
+
+	w = &latencyWindow{}
+	for i := 100; i >= 1; i-- {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{1: 1, 50: 50, 95: 95, 99: 99, 100: 100, 0.001: 1} {
+		got, ok := w.percentile(p)
+		assert.True(t, ok)
+		assert.Equal(t, want*time.Millisecond, got, "p%v", p)
+	}
+
+	// the window keeps the recent samples only
+	w = &latencyWindow{}
+	for i := 0; i < hedgeWindowSize; i++ {
+		w.add(time.Millisecond)
+	}
+	for i := 0; i < hedgeWindowSize; i++ {
+		w.add(10 * time.Millisecond)
+	}
+	got, _ := w.percentile(1)
+	assert.Equal(t, 10*time.Millisecond, got)
+}
+
+func TestHedgePercentile(t *testing.T) {
+	testConfig(t, "[global]\n[router]\n[router.hedge_percentiles]\nrandom = 95.0\nleader = 0.0\nnot_leader = 100.0\n")
+	p, ok := hedgePercentile("random")
+	assert.True(t, ok)
+	assert.Equal(t, 95.0, p)
+	for _, lb := range []string{"leader", "not_leader", "least_connection"} {
+		_, ok = hedgePercentile(lb)
+		assert.False(t, ok, lb)
+	}
+
+	testConfig(t, "[global]\n")
+	_, ok = hedgePercentile("random")
+	assert.False(t, ok, "no router config")
+}
+
+// testReplica answers a request after delay with err, a cancelled request
+// returns the error of its context
+type testReplica struct {
+	delay     time.Duration
+	err       error
+	calls     atomic.Int32
+	cancelled atomic.Int32
+}
+
+// withTestReplicas sends the requests of executeHedged to replicas
+func withTestReplicas(t *testing.T, replicas map[entity.NodeID]*testReplica) map[entity.NodeID]*rpcClient {
+	t.Helper()
+	clients := make(map[entity.NodeID]*rpcClient)
+	byClient := make(map[*rpcClient]*testReplica)
+	for nodeID, replica := range replicas {
+		clients[nodeID] = &rpcClient{}
+		byClient[clients[nodeID]] = replica
+	}
+	execute, clientOf := rpcExecute, rpcClientOf
+	t.Cleanup(func() { rpcExecute, rpcClientOf = execute, clientOf })
+	rpcExecute = func(ctx context.Context, client *rpcClient, pd, reply *vearchpb.PartitionData) error {
+		replica := byClient[client]
+		replica.calls.Add(1)
+		select {
+		case <-time.After(replica.delay):
+			return replica.err
+		case <-ctx.Done():
+			replica.cancelled.Add(1)
+			return ctx.Err()
+		}
+	}
+	rpcClientOf = func(ctx context.Context, ps *psClient, nodeID entity.NodeID) *rpcClient {
+		return clients[nodeID]
+	}
+	return clients
+}
+
+func TestExecuteHedged(t *testing.T) {
+	testConfig(t, "[global]\n[router]\nhedge_min_delay_ms = 5\n[router.hedge_percentiles]\nrandom = 50.0\n")
+	slow, fast := 2*time.Second, time.Duration(0)
+	tests := []struct {
+		name       string
+		clientType string
+		samples    int
+		primary    *testReplica
+		hedge      *testReplica
+		wantNode   entity.NodeID
+		wantErr    bool
+		wantHedge  bool
+	}{
+		{name: "Fast primary", samples: hedgeMinSamples, primary: &testReplica{delay: fast}, hedge: &testReplica{}, wantNode: 1},
+		{name: "Slow primary", samples: hedgeMinSamples, primary: &testReplica{delay: slow}, hedge: &testReplica{delay: fast}, wantNode: 2, wantHedge: true},
+		{name: "Too few samples", samples: hedgeMinSamples - 1, primary: &testReplica{delay: 50 * time.Millisecond}, hedge: &testReplica{}, wantNode: 1},
+		{name: "Hedging off", clientType: "leader", samples: hedgeMinSamples, primary: &testReplica{delay: 50 * time.Millisecond}, hedge: &testReplica{}, wantNode: 1},
+		{name: "Failed hedge", samples: hedgeMinSamples, primary: &testReplica{delay: 100 * time.Millisecond}, hedge: &testReplica{err: fmt.Errorf("failed")}, wantNode: 1, wantHedge: true},
+		{name: "Both failed", samples: hedgeMinSamples, primary: &testReplica{delay: 100 * time.Millisecond, err: fmt.Errorf("failed")}, hedge: &testReplica{err: fmt.Errorf("failed")}, wantErr: true, wantHedge: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients := withTestReplicas(t, map[entity.NodeID]*testReplica{1: tt.primary, 2: tt.hedge})
+			partition := &entity.Partition{Id: entity.PartitionID(1000 + i), Replicas: []entity.NodeID{1, 2}}
+			rpcLatencies.Delete(partition.Id)
+			t.Cleanup(func() { rpcLatencies.Delete(partition.Id) })
+			window := latencyOf(partition.Id)
+			for j := 0; j < tt.samples; j++ {
+				window.add(time.Millisecond)
+			}
+
+			r := &routerRequest{client: newTestClient()}
+			reply := &vearchpb.PartitionData{}
+			start := time.Now()
+			nodeID, _, err := r.executeHedged(context.Background(), clients[1], tt.clientType, partition, 1, &vearchpb.PartitionData{}, reply, make(map[entity.NodeID]bool))
+			assert.Equal(t, tt.wantErr, err != nil, "err %v", err)
+			if !tt.wantErr {
+				assert.Equal(t, tt.wantNode, nodeID)
+			}
+			assert.Equal(t, tt.wantHedge, tt.hedge.calls.Load() == 1, "hedge sent")
+			assert.Less(t, time.Since(start), slow, "the slow replica is not waited for")
+			if !tt.wantErr && tt.wantHedge && nodeID == 2 {
+				assert.Eventually(t, func() bool { return tt.primary.cancelled.Load() == 1 }, time.Second, time.Millisecond, "the loser is cancelled")
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ package client
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -32,6 +33,7 @@ import (
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
 )
 
 const (
@@ -45,6 +47,8 @@ type masterClient struct {
 	store.Store
 	cfg      *config.Config
 	cliCache *clientCache
+	verified *verifiedCache
+	migrated passwordMigrations
 }
 
 // Client return the masterClient.client not masterClient
@@ -164,12 +168,153 @@ func (m *masterClient) QueryUserByPassword(ctx context.Context, username, passwo
 		return nil, err
 	}
 
-	if user.Password != password {
+	if !m.verified.check(user, password) {
 		return nil, vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, nil)
 	}
+	if !user.PasswordHashed() {
+		m.migratePassword(user.Name, password)
+	}
 	return user, nil
 }
 
+// migratePassword replaces the plaintext password of user in etcd with its
+// hash in background, once per user. It is a no-op if the password changed
+// meanwhile.
+func (m *masterClient) migratePassword(name, password string) {
+	m.migrated.start(name, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return m.hashPassword(ctx, name, password)
+	})
+}
+
+func (m *masterClient) hashPassword(ctx context.Context, name, password string) error {
+	hash, err := entity.HashPassword(password)
+	if err != nil {
+		log.Error("hash password of user:[%s] err:[%v]", name, err)
+		return err
+	}
+	err = m.STM(ctx, func(stm concurrency.STM) error {
+		value := stm.Get(entity.UserKey(name))
+		if value == "" {
+			return nil
+		}
+		user := new(entity.User)
+		if err := vjson.Unmarshal([]byte(value), user); err != nil {
+			return err
+		}
+		if user.PasswordHashed() || user.Password != password {
+			return nil
+		}
+		user.Password = hash
+		marshal, err := vjson.Marshal(user)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.UserKey(name), string(marshal))
+		return nil
+	})
+	if err != nil {
+		log.Error("migrate password of user:[%s] err:[%v]", name, err)
+		return err
+	}
+	log.Info("password of user:[%s] is migrated to hash", name)
+	return nil
+}
+
+// Authenticate returns the user of name if password matches, root is checked
+// against the signkey and other users are read from cache or etcd
+func (m *masterClient) Authenticate(ctx context.Context, name, password string) (*entity.User, error) {
+	if name == entity.RootUser {
+		if subtle.ConstantTimeCompare([]byte(password), []byte(m.cfg.Global.Signkey)) != 1 {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, nil)
+		}
+		return &entity.User{Name: entity.RootUser}, nil
+	}
+	if m.cliCache != nil {
+		if get, found := m.cliCache.userCache.Get(name); found {
+			user := get.(*entity.User)
+			if !m.verified.check(user, password) {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, nil)
+			}
+			if !user.PasswordHashed() {
+				m.migratePassword(user.Name, password)
+			}
+			return user, nil
+		}
+	}
+	user, err := m.QueryUserByPassword(ctx, name, password)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, nil)
+	}
+	return user, nil
+}
+
+// AuthenticateToken verifies the signature and expiry of a bearer token and
+// returns its user and api key, a deleted key revokes the token
+func (m *masterClient) AuthenticateToken(ctx context.Context, token string) (*entity.User, *entity.ApiKey, error) {
+	claims, err := entity.ParseToken(token, m.cfg.Global.Signkey, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	var key *entity.ApiKey
+	if m.cliCache != nil {
+		key = m.cliCache.ApiKeyByCache(claims.KeyID)
+	} else if bytes, err := m.Get(ctx, entity.ApiKeyKey(claims.KeyID)); err == nil && bytes != nil {
+		key = new(entity.ApiKey)
+		if err := vjson.Unmarshal(bytes, key); err != nil {
+			return nil, nil, err
+		}
+	}
+	if key == nil || key.User != claims.User {
+		return nil, nil, vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, fmt.Errorf("api key %s is revoked", claims.KeyID))
+	}
+	if key.Expired(time.Now()) {
+		return nil, nil, vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, fmt.Errorf("api key %s is expired", key.ID))
+	}
+	if key.User == entity.RootUser {
+		return &entity.User{Name: entity.RootUser}, key, nil
+	}
+	if m.cliCache != nil {
+		if get, found := m.cliCache.userCache.Get(key.User); found {
+			return get.(*entity.User), key, nil
+		}
+	}
+	user, err := m.QueryUser(ctx, key.User)
+	if err != nil {
+		return nil, nil, vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, fmt.Errorf("user %s of api key %s not exist", key.User, key.ID))
+	}
+	return user, key, nil
+}
+
+// PriviOn returns the privileges user has on the space of db by its roles,
+// root has all of them
+func (m *masterClient) PriviOn(ctx context.Context, user *entity.User, dbName, spaceName string) entity.UserPrivi {
+	if user.Name == entity.RootUser {
+		return entity.PrivilegeAll
+	}
+	roles := make([]*entity.Role, 0, len(user.Roles))
+	for _, name := range user.Roles {
+		if m.cliCache != nil {
+			if role := m.cliCache.RoleByCache(name); role != nil {
+				roles = append(roles, role)
+			}
+			continue
+		}
+		bytes, err := m.Get(ctx, entity.RoleKey(name))
+		if err != nil || bytes == nil {
+			continue
+		}
+		role := new(entity.Role)
+		if err := vjson.Unmarshal(bytes, role); err != nil {
+			log.Error("unmarshal role:[%s] err:[%v]", name, err)
+			continue
+		}
+		roles = append(roles, role)
+	}
+	return entity.PriviOn(roles, dbName, spaceName)
+}
+
 // QueryServers scan all servers
 func (m *masterClient) QueryServers(ctx context.Context) ([]*entity.Server, error) {
 	_, bytesServers, err := m.PrefixScan(ctx, entity.PrefixServer)
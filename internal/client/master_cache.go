@@ -53,6 +53,7 @@ type clientCache struct {
 	cancel                                                                       context.CancelFunc
 	lock                                                                         sync.Mutex
 	userCache, spaceCache, spaceIDCache, partitionCache, serverCache, aliasCache *cache.Cache
+	rateLimitCache, roleCache, apiKeyCache                                       *cache.Cache
 }
 
 func newClientCache(serverCtx context.Context, masterClient *masterClient) (*clientCache, error) {
@@ -67,6 +68,9 @@ func newClientCache(serverCtx context.Context, masterClient *masterClient) (*cli
 		partitionCache: cache.New(cache.NoExpiration, cache.NoExpiration),
 		serverCache:    cache.New(cache.NoExpiration, cache.NoExpiration),
 		aliasCache:     cache.New(cache.NoExpiration, cache.NoExpiration),
+		rateLimitCache: cache.New(cache.NoExpiration, cache.NoExpiration),
+		roleCache:      cache.New(cache.NoExpiration, cache.NoExpiration),
+		apiKeyCache:    cache.New(cache.NoExpiration, cache.NoExpiration),
 	}
 
 	if err := cc.startCacheJob(ctx); err != nil {
@@ -116,7 +120,7 @@ func (cliCache *clientCache) UserByCache(ctx context.Context, userName string) (
 	for i := 0; i < retryNum; i++ {
 		time.Sleep(retrySleepTime)
 		log.Debug("to find user by key:[%s] ", userName)
-		if get, found = cliCache.spaceCache.Get(userName); found {
+		if get, found = cliCache.userCache.Get(userName); found {
 			return get.(*entity.User), nil
 		}
 	}
@@ -433,7 +437,7 @@ func (cliCache *clientCache) startCacheJob(ctx context.Context) error {
 			if err := vjson.Unmarshal(value, user); err != nil {
 				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("put event user cache err, can't unmarshal event value: %s, error: %s", string(value), err.Error()))
 			}
-			cliCache.userCache.Set(entity.UserKey(user.Name), user, cache.NoExpiration)
+			cliCache.userCache.Set(user.Name, user, cache.NoExpiration)
 			return nil
 		},
 		delete: func(key string) (err error) {
@@ -596,6 +600,78 @@ func (cliCache *clientCache) startCacheJob(ctx context.Context) error {
 	}
 	aliasJob.start()
 
+	//init rate limit
+	if err := cliCache.initRateLimit(ctx); err != nil {
+		return err
+	}
+	rateLimitJob := watcherJob{ctx: ctx, prefix: entity.PrefixRateLimit, masterClient: cliCache.mc, cache: cliCache.rateLimitCache,
+		put: func(value []byte) (err error) {
+			defer errutil.CatchError(&err)
+			limit := &entity.RateLimit{}
+			if err := vjson.Unmarshal(value, limit); err != nil {
+				return err
+			}
+			log.Debug("[%v] add to rate limit cache.", *limit)
+			cliCache.rateLimitCache.Set(entity.RateLimitKey(limit.Scope, limit.Name), limit, cache.NoExpiration)
+			return nil
+		},
+		delete: func(key string) (err error) {
+			defer errutil.CatchError(&err)
+			log.Debug("[%s] delete from rate limit cache.", key)
+			cliCache.rateLimitCache.Delete(key)
+			return nil
+		},
+	}
+	rateLimitJob.start()
+
+	//init role
+	if err := cliCache.initRole(ctx); err != nil {
+		return err
+	}
+	roleJob := watcherJob{ctx: ctx, prefix: entity.PrefixRole, masterClient: cliCache.mc, cache: cliCache.roleCache,
+		put: func(value []byte) (err error) {
+			defer errutil.CatchError(&err)
+			role := &entity.Role{}
+			if err := vjson.Unmarshal(value, role); err != nil {
+				return err
+			}
+			log.Debug("[%s] add to role cache.", role.Name)
+			cliCache.roleCache.Set(entity.RoleKey(role.Name), role, cache.NoExpiration)
+			return nil
+		},
+		delete: func(key string) (err error) {
+			defer errutil.CatchError(&err)
+			log.Debug("[%s] delete from role cache.", key)
+			cliCache.roleCache.Delete(key)
+			return nil
+		},
+	}
+	roleJob.start()
+
+	//init api key
+	if err := cliCache.initApiKey(ctx); err != nil {
+		return err
+	}
+	apiKeyJob := watcherJob{ctx: ctx, prefix: entity.PrefixApiKey, masterClient: cliCache.mc, cache: cliCache.apiKeyCache,
+		put: func(value []byte) (err error) {
+			defer errutil.CatchError(&err)
+			key := &entity.ApiKey{}
+			if err := vjson.Unmarshal(value, key); err != nil {
+				return err
+			}
+			log.Debug("[%s] add to api key cache.", key.ID)
+			cliCache.apiKeyCache.Set(entity.ApiKeyKey(key.ID), key, cache.NoExpiration)
+			return nil
+		},
+		delete: func(key string) (err error) {
+			defer errutil.CatchError(&err)
+			log.Debug("[%s] delete from api key cache.", key)
+			cliCache.apiKeyCache.Delete(key)
+			return nil
+		},
+	}
+	apiKeyJob.start()
+
 	log.Info("cache inited ok use time %v", time.Since(start))
 
 	return nil
@@ -876,6 +952,81 @@ func (cliCache *clientCache) initAlias(ctx context.Context) error {
 	return nil
 }
 
+// RateLimitByCache returns the rate limit of scope, it is nil if no limit
+func (cliCache *clientCache) RateLimitByCache(scope, name string) *entity.RateLimit {
+	if get, found := cliCache.rateLimitCache.Get(entity.RateLimitKey(scope, name)); found {
+		return get.(*entity.RateLimit)
+	}
+	return nil
+}
+
+// RoleByCache returns the role of name, it is nil if the role not exist
+func (cliCache *clientCache) RoleByCache(name string) *entity.Role {
+	if get, found := cliCache.roleCache.Get(entity.RoleKey(name)); found {
+		return get.(*entity.Role)
+	}
+	return nil
+}
+
+func (cliCache *clientCache) initRole(ctx context.Context) error {
+	_, values, err := cliCache.mc.PrefixScan(ctx, entity.PrefixRole)
+	if err != nil {
+		log.Error("init role cache err , err:[%s]", err.Error())
+		return err
+	}
+	for _, value := range values {
+		role := &entity.Role{}
+		if err := vjson.Unmarshal(value, role); err != nil {
+			log.Error("unmarshal role cache err [%s]", err.Error())
+			continue
+		}
+		cliCache.roleCache.Set(entity.RoleKey(role.Name), role, cache.NoExpiration)
+	}
+	return nil
+}
+
+// ApiKeyByCache returns the api key of id, it is nil if the key not exist
+func (cliCache *clientCache) ApiKeyByCache(id string) *entity.ApiKey {
+	if get, found := cliCache.apiKeyCache.Get(entity.ApiKeyKey(id)); found {
+		return get.(*entity.ApiKey)
+	}
+	return nil
+}
+
+func (cliCache *clientCache) initApiKey(ctx context.Context) error {
+	_, values, err := cliCache.mc.PrefixScan(ctx, entity.PrefixApiKey)
+	if err != nil {
+		log.Error("init api key cache err , err:[%s]", err.Error())
+		return err
+	}
+	for _, value := range values {
+		key := &entity.ApiKey{}
+		if err := vjson.Unmarshal(value, key); err != nil {
+			log.Error("unmarshal api key cache err [%s]", err.Error())
+			continue
+		}
+		cliCache.apiKeyCache.Set(entity.ApiKeyKey(key.ID), key, cache.NoExpiration)
+	}
+	return nil
+}
+
+func (cliCache *clientCache) initRateLimit(ctx context.Context) error {
+	_, values, err := cliCache.mc.PrefixScan(ctx, entity.PrefixRateLimit)
+	if err != nil {
+		log.Error("init rate limit cache err , err:[%s]", err.Error())
+		return err
+	}
+	for _, value := range values {
+		limit := &entity.RateLimit{}
+		if err := vjson.Unmarshal(value, limit); err != nil {
+			log.Error("unmarshal rate limit cache err [%s]", err.Error())
+			continue
+		}
+		cliCache.rateLimitCache.Set(entity.RateLimitKey(limit.Scope, limit.Name), limit, cache.NoExpiration)
+	}
+	return nil
+}
+
 func (wj *watcherJob) start() {
 	go func() {
 		defer func() {
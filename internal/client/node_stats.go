@@ -0,0 +1,169 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+)
+
+const (
+	nodeStatsAlpha = 0.3
+	// a node is an outlier if its error rate is above it or its latency is
+	// more than the factor of the fastest replica
+	nodeEjectErrorRate     = 0.5
+	nodeEjectLatencyFactor = 3.0
+	nodeEjectMinSamples    = 10
+	nodeEjectDuration      = 10 * time.Second
+)
+
+// nodeStats is the ewma of response time and error rate of a ps seen by
+// this router
+type nodeStats struct {
+	mu           sync.Mutex
+	latency      float64 // ms
+	errorRate    float64
+	samples      int
+	ejectedUntil time.Time
+}
+
+func (ps *psClient) statsOf(nodeID entity.NodeID) *nodeStats {
+	if s, ok := ps.nodeStats.Load(nodeID); ok {
+		return s.(*nodeStats)
+	}
+	s, _ := ps.nodeStats.LoadOrStore(nodeID, &nodeStats{})
+	return s.(*nodeStats)
+}
+
+// observe adds a response of node to its stats, the latency of a failed
+// response is not counted
+func (ps *psClient) observe(nodeID entity.NodeID, took time.Duration, failed bool) {
+	s := ps.statsOf(nodeID)
+	ms := float64(took) / float64(time.Millisecond)
+	errored := 0.0
+	if failed {
+		errored = 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.samples == 0 {
+		s.errorRate = errored
+		if !failed {
+			s.latency = ms
+		}
+	} else {
+		s.errorRate = nodeStatsAlpha*errored + (1-nodeStatsAlpha)*s.errorRate
+		if !failed {
+			if s.latency == 0 {
+				s.latency = ms
+			} else {
+				s.latency = nodeStatsAlpha*ms + (1-nodeStatsAlpha)*s.latency
+			}
+		}
+	}
+	s.samples++
+}
+
+type nodeCandidate struct {
+	nodeID    entity.NodeID
+	stats     *nodeStats
+	latency   float64
+	errorRate float64
+	samples   int
+	ejected   bool
+}
+
+// snapshot reads the stats, an ejection past its time is lifted and the
+// stats are reset so the node is probed again
+func (s *nodeStats) snapshot(nodeID entity.NodeID, now time.Time) *nodeCandidate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ejectedUntil.IsZero() && now.After(s.ejectedUntil) {
+		s.latency, s.errorRate, s.samples, s.ejectedUntil = 0, 0, 0, time.Time{}
+	}
+	return &nodeCandidate{nodeID: nodeID, stats: s, latency: s.latency, errorRate: s.errorRate, samples: s.samples, ejected: !s.ejectedUntil.IsZero()}
+}
+
+func (s *nodeStats) eject(until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ejectedUntil.IsZero() {
+		s.ejectedUntil = until
+	}
+}
+
+// nodeByLatency picks the node of the lowest score, which grows with the
+// latency, the error rate and the requests in flight. The outliers are
+// ejected for a while, they are only picked when all the nodes are ejected.
+// A node without stats scores zero so it is tried first.
+func (ps *psClient) nodeByLatency(nodeIDs []entity.NodeID) entity.NodeID {
+	if len(nodeIDs) == 0 {
+		return 0
+	}
+	now := time.Now()
+	candidates := make([]*nodeCandidate, 0, len(nodeIDs))
+	fastest := math.MaxFloat64
+	for _, nodeID := range nodeIDs {
+		c := ps.statsOf(nodeID).snapshot(nodeID, now)
+		candidates = append(candidates, c)
+		if !c.ejected && c.samples >= nodeEjectMinSamples && c.latency > 0 {
+			fastest = math.Min(fastest, c.latency)
+		}
+	}
+
+	for _, c := range candidates {
+		if c.ejected || c.samples < nodeEjectMinSamples {
+			continue
+		}
+		if c.errorRate > nodeEjectErrorRate || (fastest < math.MaxFloat64 && c.latency > nodeEjectLatencyFactor*fastest) {
+			log.Warn("eject ps node:[%d] for %v, latency %.2fms, error rate %.2f, fastest replica %.2fms", c.nodeID, nodeEjectDuration, c.latency, c.errorRate, fastest)
+			c.stats.eject(now.Add(nodeEjectDuration))
+			c.ejected = true
+		}
+	}
+
+	var best *nodeCandidate
+	bestScore := math.MaxFloat64
+	for _, ejected := range []bool{false, true} {
+		for _, c := range candidates {
+			if c.ejected != ejected {
+				continue
+			}
+			// a node only failed has no latency, it is as fast as the fastest
+			latency := c.latency
+			if latency == 0 && c.samples > 0 {
+				latency = 1
+				if fastest < math.MaxFloat64 {
+					latency = fastest
+				}
+			}
+			concurrent := rpcClientOf(context.Background(), ps, c.nodeID).GetConcurrent()
+			score := latency * float64(1+max(concurrent, 0)) * (1 + 10*c.errorRate)
+			if score < bestScore {
+				best, bestScore = c, score
+			}
+		}
+		if best != nil {
+			break
+		}
+	}
+	return best.nodeID
+}
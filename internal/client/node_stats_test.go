@@ -0,0 +1,143 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// withoutConcurrency makes every node report no request in flight
+func withoutConcurrency(t *testing.T) {
+	clientOf := rpcClientOf
+	t.Cleanup(func() { rpcClientOf = clientOf })
+	rpcClientOf = func(ctx context.Context, ps *psClient, nodeID entity.NodeID) *rpcClient {
+		return nilClient
+	}
+}
+
+func observeN(ps *psClient, nodeID entity.NodeID, n int, took time.Duration, failed bool) {
+	for i := 0; i < n; i++ {
+		ps.observe(nodeID, took, failed)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	ps := newTestClient().PS()
+	ps.observe(1, 10*time.Millisecond, false)
+	s := ps.statsOf(1)
+	assert.Equal(t, 10.0, s.latency)
+	assert.Equal(t, 0.0, s.errorRate)
+
+	ps.observe(1, 20*time.Millisecond, false)
+	assert.InDelta(t, nodeStatsAlpha*20+(1-nodeStatsAlpha)*10, s.latency, 1e-9)
+
+	// the latency of a failure is not counted
+	latency := s.latency
+	ps.observe(1, time.Second, true)
+	assert.Equal(t, latency, s.latency)
+	assert.InDelta(t, nodeStatsAlpha, s.errorRate, 1e-9)
+	assert.Equal(t, 3, s.samples)
+
+	// a node only failed has no latency until it answers
+	ps.observe(2, time.Second, true)
+	assert.Equal(t, 0.0, ps.statsOf(2).latency)
+	ps.observe(2, 5*time.Millisecond, false)
+	assert.Equal(t, 5.0, ps.statsOf(2).latency)
+}
+
+func TestNodeByLatency(t *testing.T) {
+	withoutConcurrency(t)
+	ps := newTestClient().PS()
+	assert.Equal(t, entity.NodeID(0), ps.nodeByLatency(nil))
+
+	observeN(ps, 1, 3, 20*time.Millisecond, false)
+	observeN(ps, 2, 3, 10*time.Millisecond, false)
+	assert.Equal(t, entity.NodeID(2), ps.nodeByLatency([]entity.NodeID{1, 2}))
+	assert.Equal(t, entity.NodeID(3), ps.nodeByLatency([]entity.NodeID{1, 2, 3}), "a node without stats is tried first")
+
+	// errors make a fast node score worse
+	observeN(ps, 2, 2, 0, true)
+	assert.Equal(t, entity.NodeID(1), ps.nodeByLatency([]entity.NodeID{1, 2}))
+}
+
+func TestNodeByLatencyEject(t *testing.T) {
+	withoutConcurrency(t)
+	ps := newTestClient().PS()
+	observeN(ps, 1, nodeEjectMinSamples, 10*time.Millisecond, false)
+	observeN(ps, 2, nodeEjectMinSamples, 40*time.Millisecond, false)
+	observeN(ps, 3, nodeEjectMinSamples, 0, true)
+	observeN(ps, 4, nodeEjectMinSamples-1, 100*time.Millisecond, false)
+
+	assert.Equal(t, entity.NodeID(1), ps.nodeByLatency([]entity.NodeID{1, 2, 3, 4}))
+	assert.True(t, ps.statsOf(1).ejectedUntil.IsZero(), "the fastest node is kept")
+	assert.False(t, ps.statsOf(2).ejectedUntil.IsZero(), "a node slower than the factor of the fastest is ejected")
+	assert.False(t, ps.statsOf(3).ejectedUntil.IsZero(), "a node of high error rate is ejected")
+	assert.True(t, ps.statsOf(4).ejectedUntil.IsZero(), "a node of few samples is not ejected")
+
+	// an ejected node is only picked when all are ejected
+	observeN(ps, 1, 10, 0, true)
+	ps.nodeByLatency([]entity.NodeID{1, 2})
+	assert.False(t, ps.statsOf(1).ejectedUntil.IsZero())
+	assert.Equal(t, entity.NodeID(2), ps.nodeByLatency([]entity.NodeID{1, 2}), "the faster of the ejected nodes")
+	assert.Equal(t, entity.NodeID(4), ps.nodeByLatency([]entity.NodeID{1, 2, 4}))
+}
+
+func TestNodeByLatencyLiftEjection(t *testing.T) {
+	withoutConcurrency(t)
+	ps := newTestClient().PS()
+	observeN(ps, 1, nodeEjectMinSamples, 10*time.Millisecond, false)
+	observeN(ps, 2, nodeEjectMinSamples, 0, true)
+	assert.Equal(t, entity.NodeID(1), ps.nodeByLatency([]entity.NodeID{1, 2}))
+
+	s := ps.statsOf(2)
+	assert.False(t, s.ejectedUntil.IsZero())
+	s.mu.Lock()
+	s.ejectedUntil = time.Now().Add(-time.Millisecond)
+	s.mu.Unlock()
+
+	// the ejection is over, the node is probed again with fresh stats
+	assert.Equal(t, entity.NodeID(2), ps.nodeByLatency([]entity.NodeID{1, 2}))
+	assert.True(t, s.ejectedUntil.IsZero())
+	assert.Equal(t, 0, s.samples)
+	assert.Equal(t, 0.0, s.errorRate)
+}
+
+func TestExecuteObserveCancel(t *testing.T) {
+	testConfig(t, "[global]\n[router]\n")
+	clients := withTestReplicas(t, map[entity.NodeID]*testReplica{
+		1: {delay: time.Minute},
+		2: {err: fmt.Errorf("failed")},
+	})
+	r := &routerRequest{client: newTestClient()}
+	partition := &entity.Partition{Id: 1, Replicas: []entity.NodeID{1, 2}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, _, err := r.executeHedged(ctx, clients[1], "random", partition, 1, &vearchpb.PartitionData{}, &vearchpb.PartitionData{}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, r.client.PS().statsOf(1).samples)
+	assert.Equal(t, 0.0, r.client.PS().statsOf(1).errorRate, "a request the client gave up on is not an error of node")
+
+	_, _, err = r.executeHedged(context.Background(), clients[2], "random", partition, 2, &vearchpb.PartitionData{}, &vearchpb.PartitionData{}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1.0, r.client.PS().statsOf(2).errorRate)
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestPartitionFailure(t *testing.T) {
+	failed := &vearchpb.Error{Code: vearchpb.ErrorEnum_INTERNAL_ERROR, Msg: "engine err"}
+
+	f := partitionFailure(1, 2, errors.New("conn refused"), nil)
+	assert.Equal(t, &response.PartitionFailure{PartitionID: 1, NodeID: 2, Code: vearchpb.ErrorEnum_ROUTER_CALL_PS_RPC_ERR.String(), Msg: "conn refused"}, f)
+
+	f = partitionFailure(1, 2, fmt.Errorf("call ps: %w", context.DeadlineExceeded), nil)
+	assert.Equal(t, vearchpb.ErrorEnum_TIMEOUT.String(), f.Code)
+
+	f = partitionFailure(1, 2, nil, nil)
+	assert.Equal(t, vearchpb.ErrorEnum_ROUTER_CALL_PS_RPC_ERR.String(), f.Code)
+
+	f = partitionFailure(1, 2, nil, &vearchpb.PartitionData{Err: failed})
+	assert.Equal(t, vearchpb.ErrorEnum_INTERNAL_ERROR.String(), f.Code)
+	assert.Equal(t, "engine err", f.Msg)
+
+	f = partitionFailure(1, 2, nil, &vearchpb.PartitionData{SearchResponse: &vearchpb.SearchResponse{Head: &vearchpb.ResponseHead{Err: failed}}})
+	assert.Equal(t, vearchpb.ErrorEnum_INTERNAL_ERROR.String(), f.Code)
+
+	assert.Nil(t, partitionFailure(1, 2, nil, &vearchpb.PartitionData{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_SUCCESS}}))
+	assert.Nil(t, partitionFailure(1, 2, nil, &vearchpb.PartitionData{SearchResponse: &vearchpb.SearchResponse{Head: &vearchpb.ResponseHead{}}}))
+}
+
+func TestSetFailures(t *testing.T) {
+	failure := func(id uint32) *response.PartitionFailure {
+		return &response.PartitionFailure{PartitionID: id, NodeID: 1, Code: vearchpb.ErrorEnum_TIMEOUT.String(), Msg: "timeout"}
+	}
+	head := func(allow string) *vearchpb.RequestHead {
+		if allow == "" {
+			return &vearchpb.RequestHead{}
+		}
+		return &vearchpb.RequestHead{Params: map[string]string{AllowPartialResultsParam: allow}}
+	}
+
+	tests := []struct {
+		name     string
+		conf     string
+		head     *vearchpb.RequestHead
+		failures []*response.PartitionFailure
+		failed   []uint32
+		err      bool
+	}{
+		{name: "all ok", head: head("")},
+		{name: "some failed allowed", head: head("true"), failures: []*response.PartitionFailure{failure(3), failure(2)}, failed: []uint32{2, 3}},
+		{name: "some failed not allowed", head: head("false"), failures: []*response.PartitionFailure{failure(2)}, failed: []uint32{2}, err: true},
+		{name: "all failed", head: head("true"), failures: []*response.PartitionFailure{failure(1), failure(2), failure(3)}, failed: []uint32{1, 2, 3}, err: true},
+		{name: "config default allowed", head: head(""), failures: []*response.PartitionFailure{failure(2)}, failed: []uint32{2}},
+		{name: "config not allowed", conf: "[router]\nallow_partial_results = false\n", head: head(""), failures: []*response.PartitionFailure{failure(2)}, failed: []uint32{2}, err: true},
+		{name: "head over config", conf: "[router]\nallow_partial_results = false\n", head: head("true"), failures: []*response.PartitionFailure{failure(2)}, failed: []uint32{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testConfig(t, "[router]\n")
+			if tt.conf != "" {
+				testConfig(t, tt.conf)
+			}
+			searchResponse := &vearchpb.SearchResponse{Results: []*vearchpb.SearchResult{{}}}
+			setFailures(searchResponse, tt.head, tt.failures, 3)
+
+			if len(tt.failed) == 0 {
+				assert.Nil(t, searchResponse.Head)
+				assert.Len(t, searchResponse.Results, 1)
+				return
+			}
+			failures := make([]*response.PartitionFailure, 0)
+			assert.NoError(t, json.Unmarshal([]byte(searchResponse.Head.Params[FailedPartitionsParam]), &failures))
+			ids := make([]uint32, 0, len(failures))
+			for _, f := range failures {
+				ids = append(ids, f.PartitionID)
+			}
+			assert.Equal(t, tt.failed, ids)
+			if tt.err {
+				assert.Equal(t, vearchpb.ErrorEnum_TIMEOUT, searchResponse.Head.Err.Code)
+				assert.Nil(t, searchResponse.Results)
+			} else {
+				assert.Nil(t, searchResponse.Head.Err)
+				assert.Len(t, searchResponse.Results, 1)
+			}
+		})
+	}
+}
+
+func TestMergePartitionResultsFailedFirst(t *testing.T) {
+	answer := func(hits int32, key string) *response.SearchDocResult {
+		return &response.SearchDocResult{PartitionData: &vearchpb.PartitionData{SearchResponse: &vearchpb.SearchResponse{
+			Results: []*vearchpb.SearchResult{{
+				TotalHits:   hits,
+				Status:      &vearchpb.SearchStatus{Total: 1, Successful: 1},
+				ResultItems: []*vearchpb.ResultItem{{PKey: key}},
+			}},
+		}}}
+	}
+
+	respChain := make(chan *response.SearchDocResult, 4)
+	// the failed partition answers first without a search response
+	respChain <- &response.SearchDocResult{
+		PartitionData: &vearchpb.PartitionData{},
+		Failure:       &response.PartitionFailure{PartitionID: 1, Code: vearchpb.ErrorEnum_TIMEOUT.String()},
+	}
+	respChain <- nil
+	respChain <- answer(2, "a")
+	respChain <- answer(3, "b")
+	close(respChain)
+
+	merged := mergePartitionResults(respChain)
+	assert.Len(t, merged.failures, 1)
+	assert.Equal(t, uint32(1), merged.failures[0].PartitionID)
+	assert.Len(t, merged.results, 1)
+	assert.Equal(t, int32(5), merged.results[0].TotalHits)
+	assert.Equal(t, int32(2), merged.results[0].Status.Successful)
+	assert.Len(t, merged.results[0].ResultItems, 2)
+	assert.NotNil(t, merged.response)
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+// verifiedTTL is how long a successful password verification is reused,
+// bcrypt is too slow to run on every request
+const verifiedTTL = time.Minute
+
+// verifiedCache remembers successful password verifications of users. It
+// keeps a mac of the password and the stored hash under a key of the
+// process, so a changed password misses and the cache is no faster to
+// brute force than bcrypt.
+type verifiedCache struct {
+	key   []byte
+	cache *cache.Cache
+}
+
+func newVerifiedCache() *verifiedCache {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return &verifiedCache{key: key, cache: cache.New(verifiedTTL, 2*verifiedTTL)}
+}
+
+func (v *verifiedCache) sum(user *entity.User, password string) []byte {
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write([]byte(user.Password))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// check reports whether password matches the stored one of user
+func (v *verifiedCache) check(user *entity.User, password string) bool {
+	sum := v.sum(user, password)
+	if get, found := v.cache.Get(user.Name); found && hmac.Equal(get.([]byte), sum) {
+		return true
+	}
+	if !user.CheckPassword(password) {
+		return false
+	}
+	v.cache.Set(user.Name, sum, cache.DefaultExpiration)
+	return true
+}
+
+// passwordMigrations runs the migrations of plaintext passwords to hash out
+// of the request path, one per user
+type passwordMigrations struct {
+	started sync.Map // user name -> struct{}
+}
+
+// start runs migrate of user name in background unless it is running or
+// done, a failed one runs again on the next authentication
+func (p *passwordMigrations) start(name string, migrate func() error) {
+	if _, loaded := p.started.LoadOrStore(name, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		if err := migrate(); err != nil {
+			p.started.Delete(name)
+		}
+	}()
+}
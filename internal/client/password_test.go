@@ -0,0 +1,71 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordMigrationsOnce(t *testing.T) {
+	var p passwordMigrations
+	var runs atomic.Int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// start returns before the migration ends
+			p.start("user", func() error {
+				runs.Add(1)
+				<-release
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(release)
+	assert.Eventually(t, func() bool { return runs.Load() == 1 }, 5*time.Second, time.Millisecond)
+
+	p.start("user", func() error {
+		runs.Add(1)
+		return nil
+	})
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), runs.Load(), "a migrated user is not migrated again")
+}
+
+func TestPasswordMigrationsRetry(t *testing.T) {
+	var p passwordMigrations
+	var runs atomic.Int32
+	failed := func() error {
+		runs.Add(1)
+		return errors.New("etcd unavailable")
+	}
+	p.start("user", failed)
+	assert.Eventually(t, func() bool {
+		_, started := p.started.Load("user")
+		return runs.Load() == 1 && !started
+	}, 5*time.Second, time.Millisecond)
+
+	p.start("user", failed)
+	assert.Eventually(t, func() bool { return runs.Load() == 2 }, 5*time.Second, time.Millisecond)
+}
@@ -16,11 +16,13 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
+	"github.com/smallnest/rpcx/share"
 	"github.com/spf13/cast"
 	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
@@ -43,6 +45,31 @@ const (
 	spaceRetry    = 3
 	adaptRetry    = 3
 	baseSleepTime = 200 * time.Millisecond
+	// ps may be too busy to answer a cancel soon, it is given up after it
+	cancelTimeout = time.Second
+)
+
+// PriorityParam in request head params and rpc metadata is the priority
+// class of a request, the batch searches and queries run in a smaller pool
+// of ps
+const (
+	PriorityParam       = "priority"
+	PriorityInteractive = "interactive"
+	PriorityBatch       = "batch"
+)
+
+// ConsistencyTokenParam in response head params of writes is the
+// entity.ConsistencyToken of the written partitions, the searches and queries
+// with it in request head params and rpc metadata only read the replicas
+// which have applied it
+const ConsistencyTokenParam = "consistency_token"
+
+// ConsistencyParam in request head params and rpc metadata is the read
+// consistency, a linearizable read is served by the leader after it confirms
+// its leadership with a quorum
+const (
+	ConsistencyParam        = "consistency"
+	ConsistencyLinearizable = "linearizable"
 )
 
 const (
@@ -62,6 +89,8 @@ const (
 	ForceMergeHandler             = "ForceMergeHandler"
 	RebuildIndexHandler           = "RebuildIndexHandler"
 	FlushHandler                  = "FlushHandler"
+	// CancelHandler aborts a running search or query of the message
+	CancelHandler = "CancelHandler"
 
 	CreatePartitionHandler = "CreatePartitionHandler"
 	DeletePartitionHandler = "DeletePartitionHandler"
@@ -77,6 +106,7 @@ const (
 type psClient struct {
 	client     *Client
 	faultyList *cache.Cache
+	nodeStats  sync.Map // entity.NodeID -> *nodeStats
 }
 
 func (ps *psClient) Client() *Client {
@@ -167,7 +197,32 @@ func (r *rpcClient) Execute(ctx context.Context, servicePath string, args interf
 	if r == nilClient {
 		return vearchpb.NewError(vearchpb.ErrorEnum_CREATE_RPCCLIENT_FAILED, nil)
 	}
-	return r.client.Execute(ctx, servicePath, args, reply)
+	err := r.client.Execute(ctx, servicePath, args, reply)
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		if pd, ok := args.(*vearchpb.PartitionData); ok {
+			r.cancel(ctx, pd)
+		}
+	}
+	return err
+}
+
+// cancel tells ps to abort the search or query of pd the caller gave up on,
+// e.g. the client is gone or another replica answered first. The deadline of
+// a request is sent with it, so ps aborts it by itself when it expires.
+func (r *rpcClient) cancel(ctx context.Context, pd *vearchpb.PartitionData) {
+	md, _ := ctx.Value(share.ReqMetaDataKey).(map[string]string)
+	if method := md[HandlerType]; method != SearchHandler && method != QueryHandler {
+		return
+	}
+	req := &vearchpb.PartitionData{PartitionID: pd.PartitionID, MessageID: pd.MessageID}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+		defer cancel()
+		ctx = context.WithValue(ctx, share.ReqMetaDataKey, map[string]string{HandlerType: CancelHandler})
+		if err := r.client.Execute(ctx, UnaryHandler, req, new(vearchpb.PartitionData)); err != nil {
+			log.Debug("cancel partition:[%d] message:[%s] err: %s", req.PartitionID, req.MessageID, err.Error())
+		}
+	}()
 }
 
 func (r *rpcClient) GetConcurrent() int {
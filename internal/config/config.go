@@ -343,6 +343,40 @@ type RouterCfg struct {
 	ConcurrentNum int      `toml:"concurrent_num" json:"concurrent_num"`
 	RpcTimeOut    int      `toml:"rpc_timeout" json:"rpc_timeout"` // ms
 	AllowOrigins  []string `toml:"allow_origins" json:"allow_origins"`
+	// default timeout of batch priority requests in ms
+	BatchRpcTimeOut int `toml:"batch_rpc_timeout" json:"batch_rpc_timeout"`
+	// requests of document api slower than it are written to slow log, 0 is off
+	SlowQueryMs int64 `toml:"slow_query_ms" json:"slow_query_ms"`
+	// max bytes of request body in slow log, negative is no body
+	SlowQueryBodyLimit int `toml:"slow_query_body_limit" json:"slow_query_body_limit"`
+	// ratio of slow log entries with request body, 0 is all
+	SlowQuerySampleRate float64 `toml:"slow_query_sample_rate" json:"slow_query_sample_rate"`
+	// max entries of search and query result cache, 0 is off
+	CacheSize int `toml:"cache_size" json:"cache_size"`
+	// ms the cached results live, results of writes from other routers are
+	// visible after it
+	CacheTTLMs int64 `toml:"cache_ttl_ms" json:"cache_ttl_ms"`
+	// load balance -> percentile of recent rpc latency of partition, a search
+	// or query not answered by then is also sent to another replica
+	HedgePercentiles map[string]float64 `toml:"hedge_percentiles" json:"hedge_percentiles"`
+	// min ms to wait before hedging
+	HedgeMinDelayMs int64 `toml:"hedge_min_delay_ms" json:"hedge_min_delay_ms"`
+	// zone of router, zone_local load balance prefers the replicas in it
+	Zone string `toml:"zone" json:"zone"`
+	// default of allow_partial_results of searches and queries, a request
+	// fails if any partition fails when it is false, nil is true
+	AllowPartialResults *bool `toml:"allow_partial_results" json:"allow_partial_results"`
+	// name -> cross-encoder endpoint of http rerank, a search picks one by
+	// name
+	Rerankers map[string]*RerankerCfg `toml:"rerankers" json:"rerankers"`
+}
+
+// RerankerCfg is a cross-encoder rerank endpoint, its key is read from the
+// environment variable api_key_env of router
+type RerankerCfg struct {
+	URL       string `toml:"url" json:"url"`
+	Model     string `toml:"model" json:"model"`
+	APIKeyEnv string `toml:"api_key_env" json:"api_key_env"`
 }
 
 func (routerCfg *RouterCfg) ApiUrl(keyNumber int) string {
@@ -368,11 +402,36 @@ type PSCfg struct {
 	RaftTruncateCount      int64  `toml:"raft_truncate_count" json:"raft_truncate_count"`
 	RaftDiffCount          uint64 `toml:"raft_diff_count" json:"raft_diff_count"`
 	PprofPort              uint16 `toml:"pprof_port" json:"pprof_port"`
+	MonitorPort            uint16 `toml:"monitor_port" json:"monitor_port"`
 	Private                bool   `toml:"private" json:"private"`                         //this ps is private if true you must set machine by dbConfig
 	FlushTimeInterval      uint32 `toml:"flush_time_interval" json:"flush_time_interval"` // seconds
 	FlushCountThreshold    uint32 `toml:"flush_count_threshold" json:"flush_count_threshold"`
 	ConcurrentNum          int    `toml:"concurrent_num" json:"concurrent_num"`
 	RpcTimeOut             int    `toml:"rpc_timeout" json:"rpc_timeout"`
+	TTLCheckInterval       int    `toml:"ttl_check_interval" json:"ttl_check_interval"` // seconds
+	TTLDeleteRate          int    `toml:"ttl_delete_rate" json:"ttl_delete_rate"`       // documents per second
+	TTLDeleteBatch         int    `toml:"ttl_delete_batch" json:"ttl_delete_batch"`
+	// max concurrent batch priority searches and queries
+	BatchConcurrentNum int `toml:"batch_concurrent_num" json:"batch_concurrent_num"`
+	// default timeout of batch priority requests in seconds
+	BatchRpcTimeOut int `toml:"batch_rpc_timeout" json:"batch_rpc_timeout"`
+	// admission control, max concurrent requests of every class, 0 is unlimited
+	SearchConcurrency int `toml:"search_concurrency" json:"search_concurrency"`
+	QueryConcurrency  int `toml:"query_concurrency" json:"query_concurrency"`
+	WriteConcurrency  int `toml:"write_concurrency" json:"write_concurrency"`
+	AdminConcurrency  int `toml:"admin_concurrency" json:"admin_concurrency"`
+	// requests of a class waiting for a slot, more are rejected at once, 0 is
+	// the concurrency of the class
+	AdmissionQueueSize int `toml:"admission_queue_size" json:"admission_queue_size"`
+	// ms a request waits for a slot before it is rejected
+	AdmissionWaitMs int `toml:"admission_wait_ms" json:"admission_wait_ms"`
+	// ms a search or query with consistency token waits for the replica to
+	// apply the index of token, it is retried on other replicas after it
+	ConsistencyWaitMs int `toml:"consistency_wait_ms" json:"consistency_wait_ms"`
+	// locality of ps, master spreads the replicas of a partition over zones
+	// and racks
+	Zone string `toml:"zone" json:"zone"`
+	Rack string `toml:"rack" json:"rack"`
 }
 
 func InitConfig(path string) {
@@ -0,0 +1,110 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// ApiKey is issued to a user, it acts as the user but only within its scopes
+// and until it expires. Deleting it revokes every token signed for it.
+type ApiKey struct {
+	ID       string         `json:"id"`
+	Name     string         `json:"name,omitempty"`
+	User     string         `json:"user"`
+	Scopes   []*RoleBinding `json:"scopes"`
+	ExpireAt int64          `json:"expire_at,omitempty"` // unix second, 0 never expires
+	CreateAt int64          `json:"create_at"`
+}
+
+func (k *ApiKey) Validate() error {
+	if k.User == "" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("user of api key can not be empty"))
+	}
+	if len(k.Scopes) == 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("scopes of api key can not be empty"))
+	}
+	for _, b := range k.Scopes {
+		if err := b.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Expired reports whether the key is expired at now
+func (k *ApiKey) Expired(now time.Time) bool {
+	return k.ExpireAt > 0 && now.Unix() >= k.ExpireAt
+}
+
+// PriviOn returns the privileges the scopes of key allow on the space of db
+func (k *ApiKey) PriviOn(dbName, spaceName string) UserPrivi {
+	return PriviOn([]*Role{{Privileges: k.Scopes}}, dbName, spaceName)
+}
+
+// TokenClaims is the signed payload of a bearer token
+type TokenClaims struct {
+	KeyID    string `json:"kid"`
+	User     string `json:"user"`
+	ExpireAt int64  `json:"exp,omitempty"`
+}
+
+var tokenEncoding = base64.RawURLEncoding
+
+func tokenSign(payload, signkey string) string {
+	mac := hmac.New(sha256.New, []byte(signkey))
+	mac.Write([]byte(payload))
+	return tokenEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignToken returns the bearer token of key signed by signkey, the token is
+// payload.signature in base64 url encoding
+func SignToken(k *ApiKey, signkey string) (string, error) {
+	bs, err := json.Marshal(&TokenClaims{KeyID: k.ID, User: k.User, ExpireAt: k.ExpireAt})
+	if err != nil {
+		return "", err
+	}
+	payload := tokenEncoding.EncodeToString(bs)
+	return payload + "." + tokenSign(payload, signkey), nil
+}
+
+// ParseToken verifies the signature and expiry of token and returns its
+// claims, revocation is checked by the caller against the stored key
+func ParseToken(token, signkey string, now time.Time) (*TokenClaims, error) {
+	payload, sign, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sign), []byte(tokenSign(payload, signkey))) {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, fmt.Errorf("invalid token"))
+	}
+	bs, err := tokenEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, fmt.Errorf("invalid token"))
+	}
+	claims := &TokenClaims{}
+	if err := json.Unmarshal(bs, claims); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, fmt.Errorf("invalid token"))
+	}
+	if claims.ExpireAt > 0 && now.Unix() >= claims.ExpireAt {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, fmt.Errorf("token of api key %s is expired", claims.KeyID))
+	}
+	return claims, nil
+}
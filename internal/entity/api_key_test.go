@@ -0,0 +1,57 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseToken(t *testing.T) {
+	now := time.Unix(1000, 0)
+	key := &ApiKey{ID: "k1", User: "alice", ExpireAt: 2000}
+	token, err := SignToken(key, "secret")
+	if err != nil {
+		t.Fatalf("SignToken() error = %v", err)
+	}
+	never, _ := SignToken(&ApiKey{ID: "k2", User: "alice"}, "secret")
+
+	tests := []struct {
+		name    string
+		token   string
+		signkey string
+		now     time.Time
+		wantKey string
+		wantErr bool
+	}{
+		{name: "Valid", token: token, signkey: "secret", now: now, wantKey: "k1"},
+		{name: "Never expires", token: never, signkey: "secret", now: time.Unix(1<<40, 0), wantKey: "k2"},
+		{name: "Expired", token: token, signkey: "secret", now: time.Unix(2000, 0), wantErr: true},
+		{name: "Wrong signkey", token: token, signkey: "other", now: now, wantErr: true},
+		{name: "Tampered payload", token: "x" + token, signkey: "secret", now: now, wantErr: true},
+		{name: "No signature", token: "abc", signkey: "secret", now: now, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseToken(tt.token, tt.signkey, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && claims.KeyID != tt.wantKey {
+				t.Fatalf("ParseToken() key = %s, want %s", claims.KeyID, tt.wantKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// ConsistencyToken is the raft applied index of the partitions written by a
+// request, a read with it is only served by the replicas which have applied
+// the index. Its text is partition_id:index pairs joined by comma.
+type ConsistencyToken map[PartitionID]uint64
+
+func ParseConsistencyToken(s string) (ConsistencyToken, error) {
+	token := make(ConsistencyToken)
+	if s == "" {
+		return token, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		pid, index, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("consistency token [%s] should be partition_id:index pairs", s))
+		}
+		id, err := strconv.ParseUint(pid, 10, 32)
+		if err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("consistency token [%s] has bad partition id [%s]", s, pid))
+		}
+		n, err := strconv.ParseUint(index, 10, 64)
+		if err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("consistency token [%s] has bad index [%s]", s, index))
+		}
+		token.Add(PartitionID(id), n)
+	}
+	return token, nil
+}
+
+// Add keeps the larger index of partition
+func (token ConsistencyToken) Add(pid PartitionID, index uint64) {
+	if index > token[pid] {
+		token[pid] = index
+	}
+}
+
+func (token ConsistencyToken) String() string {
+	pids := make([]PartitionID, 0, len(token))
+	for pid := range token {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+	pairs := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		pairs = append(pairs, fmt.Sprintf("%d:%d", pid, token[pid]))
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestParseConsistencyToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "Empty token",
+			token: "",
+			want:  "",
+		},
+		{
+			name:  "Valid token is sorted by partition",
+			token: "12:300,3:41",
+			want:  "3:41,12:300",
+		},
+		{
+			name:  "Valid token keeps larger index of partition",
+			token: "3:41,3:7",
+			want:  "3:41",
+		},
+		{
+			name:    "Invalid pair without index",
+			token:   "3",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid partition id",
+			token:   "p3:41",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid negative index",
+			token:   "3:-1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := ParseConsistencyToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConsistencyToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && token.String() != tt.want {
+				t.Fatalf("ParseConsistencyToken() = %s, want %s", token.String(), tt.want)
+			}
+		})
+	}
+}
@@ -107,3 +107,54 @@ func NewErrInternal(err error) *ErrRequest {
 		httpCode: http.StatusInternalServerError,
 	}
 }
+
+func NewErrTooManyRequests(err error) *ErrRequest {
+	if vErr, ok := err.(*vearchpb.VearchErr); ok {
+		return &ErrRequest{
+			err:      fmt.Errorf(vErr.Error()),
+			msg:      vErr.Error(),
+			code:     int(vErr.GetError().Code),
+			httpCode: http.StatusTooManyRequests,
+		}
+	}
+	return &ErrRequest{
+		err:      err,
+		msg:      err.Error(),
+		code:     int(vearchpb.ErrorEnum_INTERNAL_ERROR),
+		httpCode: http.StatusTooManyRequests,
+	}
+}
+
+func NewErrUnauthorized(err error) *ErrRequest {
+	if vErr, ok := err.(*vearchpb.VearchErr); ok {
+		return &ErrRequest{
+			err:      fmt.Errorf(vErr.Error()),
+			msg:      vErr.Error(),
+			code:     int(vErr.GetError().Code),
+			httpCode: http.StatusUnauthorized,
+		}
+	}
+	return &ErrRequest{
+		err:      err,
+		msg:      err.Error(),
+		code:     int(vearchpb.ErrorEnum_AUTHENTICATION_FAILED),
+		httpCode: http.StatusUnauthorized,
+	}
+}
+
+func NewErrForbidden(err error) *ErrRequest {
+	if vErr, ok := err.(*vearchpb.VearchErr); ok {
+		return &ErrRequest{
+			err:      fmt.Errorf(vErr.Error()),
+			msg:      vErr.Error(),
+			code:     int(vErr.GetError().Code),
+			httpCode: http.StatusForbidden,
+		}
+	}
+	return &ErrRequest{
+		err:      err,
+		msg:      err.Error(),
+		code:     int(vearchpb.ErrorEnum_AUTHENTICATION_FAILED),
+		httpCode: http.StatusForbidden,
+	}
+}
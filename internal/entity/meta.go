@@ -65,6 +65,26 @@ func AliasKey(aliasName string) string {
 	return fmt.Sprintf("%s%s", PrefixAlias, aliasName)
 }
 
+func RoleKey(roleName string) string {
+	return fmt.Sprintf("%s%s", PrefixRole, roleName)
+}
+
+func ApiKeyKey(id string) string {
+	return fmt.Sprintf("%s%s", PrefixApiKey, id)
+}
+
+func ReindexJobKey(id string) string {
+	return fmt.Sprintf("%s%s", PrefixReindex, id)
+}
+
+func ReindexCancelKey(id string) string {
+	return fmt.Sprintf("%s%s", PrefixReindexCancel, id)
+}
+
+func RateLimitKey(scope, name string) string {
+	return fmt.Sprintf("%s%s/%s", PrefixRateLimit, scope, name)
+}
+
 func LockAliasKey(aliasName string) string {
 	return fmt.Sprintf("%s%s", PrefixLock, aliasName)
 }
@@ -90,6 +110,11 @@ func SetPrefixAndSequence(cluster_id string) {
 	PrefixDataBaseBody = PrefixEtcdClusterID + PrefixDataBaseBody
 	PrefixFailServer = PrefixEtcdClusterID + PrefixFailServer
 	PrefixRouter = PrefixEtcdClusterID + PrefixRouter
+	PrefixRateLimit = PrefixEtcdClusterID + PrefixRateLimit
+	PrefixRole = PrefixEtcdClusterID + PrefixRole
+	PrefixApiKey = PrefixEtcdClusterID + PrefixApiKey
+	PrefixReindex = PrefixEtcdClusterID + PrefixReindex
+	PrefixReindexCancel = PrefixEtcdClusterID + PrefixReindexCancel
 }
 
 // sids sequence key for etcd
@@ -101,22 +126,27 @@ var (
 )
 
 var (
-	Prefix             = "/"
-	PrefixUser         = "/user/"
-	PrefixLock         = "/lock/"
-	PrefixLockCluster  = "/lock/cluster"
-	PrefixServer       = "/server/"
-	PrefixSpace        = "/space/"
-	PrefixPartition    = "/partition/"
-	PrefixDataBase     = "/db/"
-	PrefixDataBaseBody = "/db/body/"
-	PrefixFailServer   = "/fail/server/"
-	PrefixRouter       = "/router/"
-	PrefixNodeId       = "/id/node"
-	PrefixSpaceId      = "/id/space"
-	PrefixDBId         = "/id/db"
-	PrefixPartitionId  = "/id/partition"
-	PrefixAlias        = "/alias/"
+	Prefix              = "/"
+	PrefixUser          = "/user/"
+	PrefixLock          = "/lock/"
+	PrefixLockCluster   = "/lock/cluster"
+	PrefixServer        = "/server/"
+	PrefixSpace         = "/space/"
+	PrefixPartition     = "/partition/"
+	PrefixDataBase      = "/db/"
+	PrefixDataBaseBody  = "/db/body/"
+	PrefixFailServer    = "/fail/server/"
+	PrefixRouter        = "/router/"
+	PrefixNodeId        = "/id/node"
+	PrefixSpaceId       = "/id/space"
+	PrefixDBId          = "/id/db"
+	PrefixPartitionId   = "/id/partition"
+	PrefixAlias         = "/alias/"
+	PrefixRateLimit     = "/ratelimit/"
+	PrefixRole          = "/role/"
+	PrefixApiKey        = "/apikey/"
+	PrefixReindex       = "/reindex/"
+	PrefixReindexCancel = "/reindex_cancel/"
 )
 
 var PrefixEtcdClusterID = "/vearch/default/"
@@ -88,5 +88,25 @@ type PartitionInfo struct {
 	IndexStatus int               `json:"index_status"`
 	IndexNum    int               `json:"index_num"`
 	MaxDocid    int               `json:"max_docid"`
+	TTLExpired  uint64            `json:"ttl_expired_num,omitempty"`
+	Rebuild     *RebuildProgress  `json:"rebuild,omitempty"`
 	Error       string            `json:"error,omitempty"`
 }
+
+// stages of partition engine rebuild
+const (
+	RebuildStageCopy    = "copy"
+	RebuildStageCatchUp = "catch_up"
+	// the partition keeps the old engine and space, until the space updated again
+	RebuildStageFailed = "failed"
+)
+
+// RebuildProgress is the progress of partition engine rebuild for schema change
+type RebuildProgress struct {
+	Version   Version `json:"version"` // space version rebuild to
+	Stage     string  `json:"stage"`
+	Done      int64   `json:"done"`
+	Total     int64   `json:"total"`
+	StartTime int64   `json:"start_time"`
+	Error     string  `json:"error,omitempty"` // why the rebuild failed
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	RateLimitScopeUser  = "user"
+	RateLimitScopeDB    = "db"
+	RateLimitScopeSpace = "space"
+)
+
+// RateLimit is the quota of document requests of a user, a db or a space,
+// the name of space scope is db_name/space_name. Every router enforces it on
+// its own requests, zero is unlimited.
+type RateLimit struct {
+	Scope string `json:"scope"`
+	Name  string `json:"name"`
+	// search, query, upsert and delete requests per second
+	QPS   float64 `json:"qps,omitempty"`
+	Burst int     `json:"burst,omitempty"`
+	// upserted and deleted documents per second
+	WriteDocs      float64 `json:"write_docs,omitempty"`
+	WriteBurst     int     `json:"write_burst,omitempty"`
+	MaxConcurrency int     `json:"max_concurrency,omitempty"`
+}
+
+// RateLimitName is the name of limit of scope
+func RateLimitName(scope, userName, dbName, spaceName string) string {
+	switch scope {
+	case RateLimitScopeUser:
+		return userName
+	case RateLimitScopeDB:
+		return dbName
+	}
+	return dbName + "/" + spaceName
+}
+
+func (limit *RateLimit) Validate() error {
+	switch limit.Scope {
+	case RateLimitScopeUser, RateLimitScopeDB, RateLimitScopeSpace:
+	default:
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rate limit scope should be %s, %s or %s", RateLimitScopeUser, RateLimitScopeDB, RateLimitScopeSpace))
+	}
+	if limit.Name == "" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rate limit name can not be empty"))
+	}
+	if limit.QPS < 0 || limit.WriteDocs < 0 || limit.Burst < 0 || limit.WriteBurst < 0 || limit.MaxConcurrency < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rate limit of %s %s can not be negative", limit.Scope, limit.Name))
+	}
+	if limit.QPS == 0 && limit.WriteDocs == 0 && limit.MaxConcurrency == 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rate limit of %s %s should set one of qps, write_docs and max_concurrency", limit.Scope, limit.Name))
+	}
+	// a burst less than one second of rate rejects more than it should
+	if limit.QPS > 0 && limit.Burst < int(math.Ceil(limit.QPS)) {
+		limit.Burst = int(math.Ceil(limit.QPS))
+	}
+	if limit.WriteDocs > 0 && limit.WriteBurst < int(math.Ceil(limit.WriteDocs)) {
+		limit.WriteBurst = int(math.Ceil(limit.WriteDocs))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import "testing"
+
+func TestRateLimit_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      RateLimit
+		wantErr    bool
+		burst      int
+		writeBurst int
+	}{
+		{
+			name:  "Valid qps limit of user with default burst",
+			limit: RateLimit{Scope: RateLimitScopeUser, Name: "root", QPS: 10.5},
+			burst: 11,
+		},
+		{
+			name:       "Valid write limit of space keeps larger burst",
+			limit:      RateLimit{Scope: RateLimitScopeSpace, Name: "db/space", WriteDocs: 100, WriteBurst: 500},
+			writeBurst: 500,
+		},
+		{
+			name:  "Valid concurrency limit of db",
+			limit: RateLimit{Scope: RateLimitScopeDB, Name: "db", MaxConcurrency: 4},
+		},
+		{
+			name:    "Invalid scope",
+			limit:   RateLimit{Scope: "cluster", Name: "db", QPS: 1},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid empty name",
+			limit:   RateLimit{Scope: RateLimitScopeDB, QPS: 1},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid negative qps",
+			limit:   RateLimit{Scope: RateLimitScopeDB, Name: "db", QPS: -1},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid limit without any quota",
+			limit:   RateLimit{Scope: RateLimitScopeDB, Name: "db"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := tt.limit
+			err := limit.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RateLimit.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (limit.Burst != tt.burst || limit.WriteBurst != tt.writeBurst) {
+				t.Fatalf("RateLimit.Validate() burst = %d/%d, want %d/%d", limit.Burst, limit.WriteBurst, tt.burst, tt.writeBurst)
+			}
+		})
+	}
+}
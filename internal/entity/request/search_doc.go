@@ -57,6 +57,49 @@ type Ranker struct {
 	Params json.RawMessage `json:"params,omitempty"`
 }
 
+// Rerank reorders the merged top_k hits of a search and truncates them to
+// limit, type http calls the cross-encoder endpoint of router config named
+// name with query and the text of text_field, type expression scores hits by
+// a scalar expression of _score and numeric fields, the higher score ranks
+// first
+type Rerank struct {
+	Type       string `json:"type"`
+	TopK       int32  `json:"top_k,omitempty"`
+	TimeoutMs  int64  `json:"timeout_ms,omitempty"`
+	Name       string `json:"name,omitempty"`
+	URL        string `json:"url,omitempty"`     // rejected, use name
+	APIKey     string `json:"api_key,omitempty"` // rejected, use name
+	Query      string `json:"query,omitempty"`
+	TextField  string `json:"text_field,omitempty"`
+	Expression string `json:"expression,omitempty"`
+}
+
+// FunctionScore replaces the score of the merged top_k hits by expression
+// of _score, _now and numeric fields. A date field is seconds since epoch
+// with fraction and _now is whole seconds, so _now - date is the age in
+// seconds. Missing gives the value of fields a hit has no value of.
+type FunctionScore struct {
+	Expression string             `json:"expression"`
+	TopK       int32              `json:"top_k,omitempty"`
+	Missing    map[string]float64 `json:"missing,omitempty"`
+}
+
+// ReindexRequest copies documents of source space into dest space
+type ReindexRequest struct {
+	Source     ReindexSpace      `json:"source"`
+	Dest       ReindexSpace      `json:"dest"`
+	Filters    *Filter           `json:"filters,omitempty"`
+	FieldsMap  map[string]string `json:"fields_map,omitempty"` // source field name -> dest field name
+	DropFields []string          `json:"drop_fields,omitempty"`
+	Rate       int               `json:"rate,omitempty"` // documents per second, 0 is unlimited
+	BatchSize  int               `json:"batch_size,omitempty"`
+}
+
+type ReindexSpace struct {
+	DbName    string `json:"db_name"`
+	SpaceName string `json:"space_name"`
+}
+
 type SearchDocumentRequest struct {
 	Limit         int32             `json:"limit,omitempty"`
 	Fields        []string          `json:"fields,omitempty"`
@@ -74,7 +117,18 @@ type SearchDocumentRequest struct {
 	PartitionId   *uint32           `json:"partition_id,omitempty"`
 	Next          *bool             `json:"next,omitempty"`
 	Ranker        json.RawMessage   `json:"ranker,omitempty"`
-	sortOrder     sortorder.SortOrder
+	Rerank        *Rerank           `json:"rerank,omitempty"`
+	FunctionScore *FunctionScore    `json:"function_score,omitempty"`
+	Explain       bool              `json:"explain,omitempty"`
+	// token of writes the search or query should see, see
+	// entity.ConsistencyToken
+	ConsistencyToken string `json:"consistency_token,omitempty"`
+	// linearizable reads the leader after it confirms its leadership
+	Consistency string `json:"consistency,omitempty"`
+	// return the results of the partitions answered when others failed,
+	// the router config is used if it is nil
+	AllowPartialResults *bool `json:"allow_partial_results,omitempty"`
+	sortOrder           sortorder.SortOrder
 }
 
 func (s *SearchDocumentRequest) SortOrder() (sortorder.SortOrder, error) {
@@ -23,6 +23,29 @@ type SearchDocResult struct {
 	PartitionData *vearchpb.PartitionData
 	SortValueMap  map[string][]sortorder.SortValue
 	TopSizes      []int32
+	Explain       *PartitionExplain
+	Failure       *PartitionFailure
+}
+
+// PartitionFailure is a partition not answered a search or query, node id
+// is 0 if no replica of it is called
+type PartitionFailure struct {
+	PartitionID uint32 `json:"partition_id"`
+	NodeID      uint64 `json:"node_id"`
+	Code        string `json:"code"`
+	Msg         string `json:"msg"`
+}
+
+// PartitionExplain is what a partition did for a search or query in
+// explain mode, candidates and total hits are by query
+type PartitionExplain struct {
+	PartitionID uint32  `json:"partition_id"`
+	NodeID      uint64  `json:"node_id"`
+	Took        float64 `json:"took_ms"`
+	Candidates  []int   `json:"candidates"`
+	TotalHits   []int32 `json:"total_hits"`
+	Timeout     bool    `json:"timeout,omitempty"`
+	Error       string  `json:"error,omitempty"`
 }
 
 type SearchItemsSort struct {
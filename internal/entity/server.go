@@ -39,6 +39,10 @@ type Server struct {
 	Size              uint64        `json:"size,omitempty"`
 	Private           bool          `json:"private"`
 	Version           *BuildVersion `json:"version"`
+	// locality of server, the replicas of a partition are spread over zones
+	// and racks
+	Zone string `json:"zone,omitempty"`
+	Rack string `json:"rack,omitempty"`
 }
 
 // FailServer /fail/server/id:[body] ttl 3m 3s
@@ -15,10 +15,16 @@
 package entity
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
+	"net/url"
+	"strings"
 	"unicode"
 
+	"github.com/spf13/cast"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 )
@@ -29,6 +35,9 @@ const (
 	FieldOption_Index_False vearchpb.FieldOption = 2
 )
 
+// NullStringValue is stored for null string fields
+const NullStringValue = "\x00"
+
 type Index struct {
 	Name   string          `json:"name"`
 	Type   string          `json:"type,omitempty"`
@@ -78,6 +87,33 @@ type Space struct {
 	Fields          json.RawMessage             `json:"fields"`
 	Index           *Index                      `json:"index,omitempty"`
 	SpaceProperties map[string]*SpaceProperties `json:"space_properties"`
+	TTL             *SpaceTTL                   `json:"ttl,omitempty"`
+	Embedders       []*Embedder                 `json:"embedders,omitempty"`
+}
+
+// SpaceTTL documents whose ttl field is earlier than now are expired and
+// deleted by the partition leader
+type SpaceTTL struct {
+	Field   string `json:"field"`             // indexed date field, the expire time of document
+	Default int64  `json:"default,omitempty"` // seconds, set expire time for document without ttl field
+}
+
+// EmbedderKeyEnvPrefix is the prefix of api_key_env of embedders, a space can
+// not send other environment variables of routers to its endpoint
+const EmbedderKeyEnvPrefix = "VEARCH_EMBEDDER_"
+
+// Embedder vectorizes text of a vector field by an OpenAI compatible
+// embeddings endpoint, the router calls it at upsert and search time. The key
+// of endpoint is read from an environment variable of routers named with
+// EmbedderKeyEnvPrefix, it is not kept in space meta.
+type Embedder struct {
+	Field     string `json:"field"`                 // vector field
+	URL       string `json:"url"`                   // embeddings endpoint
+	Model     string `json:"model,omitempty"`       // model name sent to endpoint
+	APIKeyEnv string `json:"api_key_env,omitempty"` // env of key sent as bearer token
+	APIKey    string `json:"api_key,omitempty"`     // rejected, use api_key_env
+	TimeoutMs int64  `json:"timeout_ms,omitempty"`  // timeout of each call
+	BatchSize int    `json:"batch_size,omitempty"`  // max texts of each call
 }
 
 type SpaceSchema struct {
@@ -93,10 +129,19 @@ type SpaceInfo struct {
 	ReplicaNum   uint8            `json:"replica_num"`
 	Schema       *SpaceSchema     `json:"schema"`
 	Status       string           `json:"status,omitempty"`
+	Rebuild      *SpaceRebuild    `json:"rebuild,omitempty"`
 	Partitions   []*PartitionInfo `json:"partitions"`
 	Errors       *[]string        `json:"errors,omitempty"`
 }
 
+// SpaceRebuild is the engine rebuild status of partitions in space
+type SpaceRebuild struct {
+	Partitions int   `json:"partitions"`       // number of partitions rebuilding
+	Failed     int   `json:"failed,omitempty"` // number of partitions failed to rebuild
+	Done       int64 `json:"done"`
+	Total      int64 `json:"total"`
+}
+
 type SpaceDescribeRequest struct {
 	SpaceName string `json:"space_name"`
 	DbName    string `json:"db_name"`
@@ -122,6 +167,36 @@ type SpaceProperties struct {
 	StoreType  *string              `json:"store_type,omitempty"`
 	StoreParam json.RawMessage      `json:"store_param,omitempty"`
 	Option     vearchpb.FieldOption `json:"option,omitempty"`
+	Default    json.RawMessage      `json:"default,omitempty"`
+	Nullable   bool                 `json:"nullable,omitempty"`
+	Required   bool                 `json:"required,omitempty"`
+}
+
+// NullValue returns the sentinel stored in the engine for a null value of
+// this field, the engine itself has no notion of null
+func (sp *SpaceProperties) NullValue() []byte {
+	switch sp.FieldType {
+	case vearchpb.FieldType_STRING, vearchpb.FieldType_STRINGARRAY:
+		return []byte(NullStringValue)
+	case vearchpb.FieldType_INT:
+		return cbbytes.Int32ToByte(math.MinInt32)
+	case vearchpb.FieldType_LONG, vearchpb.FieldType_DATE:
+		return cbbytes.Int64ToByte(math.MinInt64)
+	case vearchpb.FieldType_FLOAT:
+		return cbbytes.Float32ToByte(-math.MaxFloat32)
+	case vearchpb.FieldType_DOUBLE:
+		return cbbytes.Float64ToByteNew(-math.MaxFloat64)
+	}
+	return nil
+}
+
+// IsNull reports whether value is the null sentinel of a nullable field
+func (sp *SpaceProperties) IsNull(value []byte) bool {
+	if !sp.Nullable {
+		return false
+	}
+	null := sp.NullValue()
+	return null != nil && bytes.Equal(null, value)
 }
 
 func (s *Space) String() string {
@@ -269,6 +344,79 @@ func (space *Space) Validate() error {
 	return nil
 }
 
+// Embedder returns the embedder of field, nil if not set
+func (space *Space) Embedder(field string) *Embedder {
+	for _, e := range space.Embedders {
+		if e.Field == field {
+			return e
+		}
+	}
+	return nil
+}
+
+// CheckKeyEnv checks api_key_env is named with EmbedderKeyEnvPrefix
+func (e *Embedder) CheckKeyEnv() error {
+	if e.APIKeyEnv != "" && (!strings.HasPrefix(e.APIKeyEnv, EmbedderKeyEnvPrefix) || len(e.APIKeyEnv) == len(EmbedderKeyEnvPrefix)) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedder api_key_env:[%s] of field:[%s] should start with %s", e.APIKeyEnv, e.Field, EmbedderKeyEnvPrefix))
+	}
+	return nil
+}
+
+// ValidateEmbedders check the embedders are bound to float vector fields
+func (space *Space) ValidateEmbedders() error {
+	fields := make(map[string]bool, len(space.Embedders))
+	for _, e := range space.Embedders {
+		if fields[e.Field] {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedder field:[%s] is duplicated", e.Field))
+		}
+		fields[e.Field] = true
+		pro := space.SpaceProperties[e.Field]
+		if pro == nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedder field:[%s] not found in space fields", e.Field))
+		}
+		if pro.FieldType != vearchpb.FieldType_VECTOR {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedder field:[%s] should be vector type", e.Field))
+		}
+		if space.Index != nil && space.Index.Type == "BINARYIVF" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedder field:[%s] can not be binary vector", e.Field))
+		}
+		if u, err := url.Parse(e.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedder url:[%s] of field:[%s] should be http or https url", e.URL, e.Field))
+		}
+		if e.APIKey != "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedder api_key of field:[%s] can not be stored in space, set api_key_env to the environment variable of routers holding it", e.Field))
+		}
+		if err := e.CheckKeyEnv(); err != nil {
+			return err
+		}
+		if e.TimeoutMs < 0 || e.BatchSize < 0 {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedder timeout_ms and batch_size of field:[%s] can not be negative", e.Field))
+		}
+	}
+	return nil
+}
+
+// ValidateTTL check the ttl field is an indexed date field of space
+func (space *Space) ValidateTTL() error {
+	if space.TTL == nil {
+		return nil
+	}
+	if space.TTL.Default < 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space ttl default:[%d] can not be negative", space.TTL.Default))
+	}
+	pro := space.SpaceProperties[space.TTL.Field]
+	if pro == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space ttl field:[%s] not found in space fields", space.TTL.Field))
+	}
+	if pro.FieldType != vearchpb.FieldType_DATE {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space ttl field:[%s] should be date type", space.TTL.Field))
+	}
+	if pro.Option&FieldOption_Index != FieldOption_Index {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("space ttl field:[%s] not set index", space.TTL.Field))
+	}
+	return nil
+}
+
 type Field struct {
 	Name       string  `json:"name"`
 	Type       string  `json:"type"`
@@ -279,6 +427,9 @@ type Field struct {
 	StoreParam *struct {
 		CacheSize int `json:"cache_size,omitempty"`
 	} `json:"store_param,omitempty"`
+	Default  json.RawMessage `json:"default,omitempty"`
+	Nullable bool            `json:"nullable,omitempty"`
+	Required bool            `json:"required,omitempty"`
 }
 
 func UnmarshalPropertyJSON(propertity []byte) (map[string]*SpaceProperties, error) {
@@ -355,7 +506,70 @@ func UnmarshalPropertyJSON(propertity []byte) (map[string]*SpaceProperties, erro
 			}
 		}
 
+		sp.Default = data.Default
+		sp.Nullable = data.Nullable
+		sp.Required = data.Required
+		if err := checkFieldConstraint(data.Name, sp); err != nil {
+			return nil, err
+		}
+
 		tmpPro[data.Name] = sp
 	}
 	return tmpPro, nil
 }
+
+// checkFieldConstraint validates default, nullable and required of a field
+func checkFieldConstraint(name string, sp *SpaceProperties) error {
+	if sp.Nullable && sp.NullValue() == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] type:[%s] can not be nullable", name, sp.Type))
+	}
+	if sp.Default == nil || string(sp.Default) == "null" {
+		sp.Default = nil
+		return nil
+	}
+	if sp.Required {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] is required and can not set default", name))
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(sp.Default, &v); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] default value %s err: %v", name, string(sp.Default), err))
+	}
+	var err error
+	switch sp.FieldType {
+	case vearchpb.FieldType_STRING:
+		if _, ok := v.(string); !ok {
+			err = fmt.Errorf("should be string")
+		}
+	case vearchpb.FieldType_STRINGARRAY:
+		arr, ok := v.([]interface{})
+		for i := 0; ok && i < len(arr); i++ {
+			_, ok = arr[i].(string)
+		}
+		if !ok {
+			err = fmt.Errorf("should be string array")
+		}
+	case vearchpb.FieldType_INT:
+		_, err = cast.ToInt32E(v)
+	case vearchpb.FieldType_LONG:
+		_, err = cast.ToInt64E(v)
+	case vearchpb.FieldType_FLOAT, vearchpb.FieldType_DOUBLE:
+		_, err = cast.ToFloat64E(v)
+	case vearchpb.FieldType_BOOL:
+		if _, ok := v.(bool); !ok {
+			err = fmt.Errorf("should be bool")
+		}
+	case vearchpb.FieldType_DATE:
+		if _, ok := v.(string); ok {
+			_, err = cast.ToTimeE(v)
+		} else {
+			_, err = cast.ToInt64E(v)
+		}
+	default:
+		err = fmt.Errorf("type:[%s] not support default value", sp.Type)
+	}
+	if err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] default value %s err: %v", name, string(sp.Default), err))
+	}
+	return nil
+}
@@ -173,3 +173,120 @@ func TestSpace_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalPropertyJSON_FieldConstraint(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		wantErr bool
+	}{
+		{
+			name:    "Nullable integer with default",
+			fields:  `[{"name": "age", "type": "integer", "nullable": true, "default": 18}]`,
+			wantErr: false,
+		},
+		{
+			name:    "Date default in string",
+			fields:  `[{"name": "ts", "type": "date", "default": "2024-01-01"}]`,
+			wantErr: false,
+		},
+		{
+			name:    "Required with default",
+			fields:  `[{"name": "age", "type": "integer", "required": true, "default": 18}]`,
+			wantErr: true,
+		},
+		{
+			name:    "String default mismatch type",
+			fields:  `[{"name": "tag", "type": "string", "default": 1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Nullable bool",
+			fields:  `[{"name": "flag", "type": "bool", "nullable": true}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Nullable vector",
+			fields:  `[{"name": "vec", "type": "vector", "dimension": 8, "nullable": true}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := entity.UnmarshalPropertyJSON([]byte(tt.fields)); (err != nil) != tt.wantErr {
+				t.Errorf("UnmarshalPropertyJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpaceProperties_IsNull(t *testing.T) {
+	pros, err := entity.UnmarshalPropertyJSON([]byte(`[{"name": "age", "type": "integer", "nullable": true}, {"name": "count", "type": "integer"}]`))
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if !pros["age"].IsNull(pros["age"].NullValue()) {
+		t.Errorf("null value of nullable field should be null")
+	}
+	if pros["count"].IsNull(pros["count"].NullValue()) {
+		t.Errorf("field not nullable should never be null")
+	}
+}
+
+func TestSpace_ValidateTTL(t *testing.T) {
+	pros, err := entity.UnmarshalPropertyJSON([]byte(`[
+		{"name": "expire_at", "type": "date", "index": {"name": "expire_at", "type": "SCALAR"}},
+		{"name": "created_at", "type": "date"},
+		{"name": "age", "type": "integer", "index": {"name": "age", "type": "SCALAR"}}
+	]`))
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	tests := []struct {
+		name    string
+		ttl     *entity.SpaceTTL
+		wantErr bool
+	}{
+		{name: "No ttl", ttl: nil, wantErr: false},
+		{name: "Indexed date field", ttl: &entity.SpaceTTL{Field: "expire_at", Default: 3600}, wantErr: false},
+		{name: "Field not exist", ttl: &entity.SpaceTTL{Field: "not_exist"}, wantErr: true},
+		{name: "Field not indexed", ttl: &entity.SpaceTTL{Field: "created_at"}, wantErr: true},
+		{name: "Field not date", ttl: &entity.SpaceTTL{Field: "age"}, wantErr: true},
+		{name: "Negative default", ttl: &entity.SpaceTTL{Field: "expire_at", Default: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			space := &entity.Space{SpaceProperties: pros, TTL: tt.ttl}
+			if err := space.ValidateTTL(); (err != nil) != tt.wantErr {
+				t.Errorf("Space.ValidateTTL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpace_ValidateEmbedders(t *testing.T) {
+	pros, err := entity.UnmarshalPropertyJSON([]byte(`[{"name": "vec", "type": "vector", "dimension": 2}]`))
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	tests := []struct {
+		name     string
+		embedder *entity.Embedder
+		wantErr  bool
+	}{
+		{name: "No key", embedder: &entity.Embedder{Field: "vec", URL: "http://embed"}, wantErr: false},
+		{name: "Key env with prefix", embedder: &entity.Embedder{Field: "vec", URL: "http://embed", APIKeyEnv: "VEARCH_EMBEDDER_OPENAI"}, wantErr: false},
+		{name: "Key env of other secret", embedder: &entity.Embedder{Field: "vec", URL: "http://embed", APIKeyEnv: "AWS_SECRET_ACCESS_KEY"}, wantErr: true},
+		{name: "Key env of prefix only", embedder: &entity.Embedder{Field: "vec", URL: "http://embed", APIKeyEnv: entity.EmbedderKeyEnvPrefix}, wantErr: true},
+		{name: "Key in space", embedder: &entity.Embedder{Field: "vec", URL: "http://embed", APIKey: "key"}, wantErr: true},
+		{name: "Not http url", embedder: &entity.Embedder{Field: "vec", URL: "file:///etc/passwd"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			space := &entity.Space{SpaceProperties: pros, Embedders: []*entity.Embedder{tt.embedder}}
+			if err := space.ValidateEmbedders(); (err != nil) != tt.wantErr {
+				t.Errorf("Space.ValidateEmbedders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -14,15 +14,234 @@
 
 package entity
 
-import "bytes"
+import (
+	"crypto/subtle"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RootUser is the user of signkey in config, it can not be managed by the
+// user api
+const RootUser = "root"
 
 type User struct {
-	Name        string
-	Password    string
-	AllowedHost string
-	Privi       UserPrivi
-	UserDB      map[string]struct{}
-	HeadKey     string
+	Name        string              `json:"name"`
+	Password    string              `json:"password,omitempty"`
+	AllowedHost string              `json:"allowed_host,omitempty"`
+	Privi       UserPrivi           `json:"privi,omitempty"`
+	UserDB      map[string]struct{} `json:"user_db,omitempty"`
+	HeadKey     string              `json:"head_key,omitempty"`
+	// names of roles granted to the user
+	Roles []string `json:"roles,omitempty"`
+}
+
+func (user *User) Validate() error {
+	if user.Name == "" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("user name can not be empty"))
+	}
+	if user.Name == RootUser {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("user %s is reserved", RootUser))
+	}
+	if user.Password == "" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("password of user %s can not be empty", user.Name))
+	}
+	return ValidatePassword(user.Password)
+}
+
+// ValidatePassword checks password can be hashed
+func ValidatePassword(password string) error {
+	if len(password) > maxPasswordLen {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("password can not be longer than %d bytes", maxPasswordLen))
+	}
+	return nil
+}
+
+// bcrypt only uses the first 72 bytes of password
+const maxPasswordLen = 72
+
+// HashPassword returns the salted bcrypt hash of password stored in etcd
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+	}
+	return string(hash), nil
+}
+
+// PasswordHashed reports whether the stored password of user is hashed,
+// users created before hashing keep plaintext until their next login
+func (user *User) PasswordHashed() bool {
+	_, err := bcrypt.Cost([]byte(user.Password))
+	return err == nil
+}
+
+// CheckPassword reports whether password matches the stored one of user
+func (user *User) CheckPassword(password string) bool {
+	if user.PasswordHashed() {
+		return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+	}
+	return user.Password != "" && subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) == 1
+}
+
+const (
+	RoleScopeCluster = "cluster"
+	RoleScopeDB      = "db"
+	RoleScopeSpace   = "space"
+)
+
+// Role is a set of privileges granted to users
+type Role struct {
+	Name       string         `json:"name"`
+	Privileges []*RoleBinding `json:"privileges"`
+}
+
+// RoleBinding grants privileges on the cluster, a db or a space, the names
+// of privileges are the keys of PriviMap
+type RoleBinding struct {
+	Scope      string   `json:"scope"`
+	DbName     string   `json:"db_name,omitempty"`
+	SpaceName  string   `json:"space_name,omitempty"`
+	Privileges []string `json:"privileges"`
+}
+
+// Resource is the cluster, db or space the binding is on
+func (b *RoleBinding) Resource() string {
+	switch b.Scope {
+	case RoleScopeDB:
+		return b.DbName
+	case RoleScopeSpace:
+		return b.DbName + "/" + b.SpaceName
+	}
+	return RoleScopeCluster
+}
+
+// Privi returns the privilege bits of binding
+func (b *RoleBinding) Privi() UserPrivi {
+	privi := PrivilegeNone
+	for _, name := range b.Privileges {
+		privi |= PriviMap[name]
+	}
+	return privi
+}
+
+func (b *RoleBinding) Validate() error {
+	switch b.Scope {
+	case RoleScopeCluster:
+		if b.DbName != "" || b.SpaceName != "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("privileges of cluster scope can not have db_name or space_name"))
+		}
+	case RoleScopeDB:
+		if b.DbName == "" || b.SpaceName != "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("privileges of db scope should only have db_name"))
+		}
+	case RoleScopeSpace:
+		if b.DbName == "" || b.SpaceName == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("privileges of space scope should have db_name and space_name"))
+		}
+	default:
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("privileges scope should be %s, %s or %s", RoleScopeCluster, RoleScopeDB, RoleScopeSpace))
+	}
+	if len(b.Privileges) == 0 {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("privileges of %s %s can not be empty", b.Scope, b.Resource()))
+	}
+	for _, name := range b.Privileges {
+		if _, ok := PriviMap[name]; !ok {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unknown privilege %s", name))
+		}
+	}
+	return nil
+}
+
+func (role *Role) Validate() error {
+	if role.Name == "" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("role name can not be empty"))
+	}
+	for _, b := range role.Privileges {
+		if err := b.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Grant adds the privileges of bindings to role, the bindings of the same
+// resource are merged
+func (role *Role) Grant(bindings []*RoleBinding) {
+	for _, b := range bindings {
+		if old := role.binding(b); old != nil {
+			old.Privileges = privilegeNames(old.Privi() | b.Privi())
+		} else {
+			role.Privileges = append(role.Privileges, &RoleBinding{Scope: b.Scope, DbName: b.DbName, SpaceName: b.SpaceName, Privileges: privilegeNames(b.Privi())})
+		}
+	}
+}
+
+// Revoke removes the privileges of bindings from role, a binding left
+// without privileges is removed
+func (role *Role) Revoke(bindings []*RoleBinding) {
+	for _, b := range bindings {
+		if old := role.binding(b); old != nil {
+			old.Privileges = privilegeNames(old.Privi() &^ b.Privi())
+		}
+	}
+	kept := role.Privileges[:0]
+	for _, b := range role.Privileges {
+		if len(b.Privileges) > 0 {
+			kept = append(kept, b)
+		}
+	}
+	role.Privileges = kept
+}
+
+func (role *Role) binding(b *RoleBinding) *RoleBinding {
+	for _, old := range role.Privileges {
+		if old.Scope == b.Scope && old.Resource() == b.Resource() {
+			return old
+		}
+	}
+	return nil
+}
+
+// PriviOn returns the privileges of roles on the space of db, a binding on
+// the cluster or the db covers all the spaces in it. An empty spaceName is
+// the db itself and an empty dbName is the cluster.
+func PriviOn(roles []*Role, dbName, spaceName string) UserPrivi {
+	privi := PrivilegeNone
+	for _, role := range roles {
+		for _, b := range role.Privileges {
+			switch b.Scope {
+			case RoleScopeCluster:
+			case RoleScopeDB:
+				if dbName == "" || b.DbName != dbName {
+					continue
+				}
+			case RoleScopeSpace:
+				if spaceName == "" || b.DbName != dbName || b.SpaceName != spaceName {
+					continue
+				}
+			default:
+				continue
+			}
+			privi |= b.Privi()
+		}
+	}
+	return privi
+}
+
+// privilegeNames returns the sorted names of privilege bits
+func privilegeNames(privi UserPrivi) []string {
+	names := make([]string, 0, len(PriviMap))
+	for name, p := range PriviMap {
+		if HasPrivi(privi, p) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
 type UserPrivi uint64
@@ -38,6 +257,9 @@ const (
 	PrivilegeDrop     UserPrivi = 1 << 6
 	PrivilegeTruncate UserPrivi = 1 << 7
 	PrivilegeGrant    UserPrivi = 1 << 8
+
+	PrivilegeAll = PrivilegeSelect | PrivilegeInsert | PrivilegeUpdate | PrivilegeDelete |
+		PrivilegeCreate | PrivilegeAlter | PrivilegeDrop | PrivilegeTruncate | PrivilegeGrant
 )
 
 var PriviMap = map[string]UserPrivi{
@@ -63,16 +285,5 @@ func LackPrivi(userPrivi UserPrivi, checkPrivi UserPrivi) UserPrivi {
 }
 
 func (userPrivi UserPrivi) String() string {
-	var prefix string
-	var b bytes.Buffer
-
-	for privDesc, priv := range PriviMap {
-		if HasPrivi(userPrivi, priv) {
-			b.WriteString(prefix)
-			b.WriteString(privDesc)
-			prefix = "|"
-		}
-	}
-
-	return b.String()
+	return strings.Join(privilegeNames(userPrivi), "|")
 }
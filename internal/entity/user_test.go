@@ -0,0 +1,162 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoleBinding_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		binding RoleBinding
+		wantErr bool
+	}{
+		{
+			name:    "Valid cluster binding",
+			binding: RoleBinding{Scope: RoleScopeCluster, Privileges: []string{"create", "drop"}},
+		},
+		{
+			name:    "Valid db binding",
+			binding: RoleBinding{Scope: RoleScopeDB, DbName: "db", Privileges: []string{"select"}},
+		},
+		{
+			name:    "Valid space binding",
+			binding: RoleBinding{Scope: RoleScopeSpace, DbName: "db", SpaceName: "space", Privileges: []string{"insert", "delete"}},
+		},
+		{
+			name:    "Invalid cluster binding with db",
+			binding: RoleBinding{Scope: RoleScopeCluster, DbName: "db", Privileges: []string{"select"}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid space binding without space",
+			binding: RoleBinding{Scope: RoleScopeSpace, DbName: "db", Privileges: []string{"select"}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid scope",
+			binding: RoleBinding{Scope: "partition", Privileges: []string{"select"}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid empty privileges",
+			binding: RoleBinding{Scope: RoleScopeDB, DbName: "db"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid unknown privilege",
+			binding: RoleBinding{Scope: RoleScopeDB, DbName: "db", Privileges: []string{"read"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.binding.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("RoleBinding.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRole_GrantRevoke(t *testing.T) {
+	role := &Role{Name: "reader", Privileges: []*RoleBinding{
+		{Scope: RoleScopeDB, DbName: "db", Privileges: []string{"select"}},
+	}}
+	role.Grant([]*RoleBinding{
+		{Scope: RoleScopeDB, DbName: "db", Privileges: []string{"insert", "select"}},
+		{Scope: RoleScopeSpace, DbName: "db", SpaceName: "space", Privileges: []string{"delete"}},
+	})
+	want := []*RoleBinding{
+		{Scope: RoleScopeDB, DbName: "db", Privileges: []string{"insert", "select"}},
+		{Scope: RoleScopeSpace, DbName: "db", SpaceName: "space", Privileges: []string{"delete"}},
+	}
+	if !reflect.DeepEqual(role.Privileges, want) {
+		t.Fatalf("Role.Grant() = %+v, want %+v", role.Privileges, want)
+	}
+
+	role.Revoke([]*RoleBinding{
+		{Scope: RoleScopeDB, DbName: "db", Privileges: []string{"insert"}},
+		{Scope: RoleScopeSpace, DbName: "db", SpaceName: "space", Privileges: []string{"delete"}},
+	})
+	want = []*RoleBinding{
+		{Scope: RoleScopeDB, DbName: "db", Privileges: []string{"select"}},
+	}
+	if !reflect.DeepEqual(role.Privileges, want) {
+		t.Fatalf("Role.Revoke() = %+v, want %+v", role.Privileges, want)
+	}
+}
+
+func TestPriviOn(t *testing.T) {
+	roles := []*Role{
+		{Name: "viewer", Privileges: []*RoleBinding{
+			{Scope: RoleScopeCluster, Privileges: []string{"select"}},
+		}},
+		{Name: "writer", Privileges: []*RoleBinding{
+			{Scope: RoleScopeDB, DbName: "db", Privileges: []string{"insert"}},
+			{Scope: RoleScopeSpace, DbName: "db", SpaceName: "space", Privileges: []string{"delete"}},
+		}},
+	}
+	tests := []struct {
+		name      string
+		dbName    string
+		spaceName string
+		want      UserPrivi
+	}{
+		{name: "Cluster", want: PrivilegeSelect},
+		{name: "Db", dbName: "db", want: PrivilegeSelect | PrivilegeInsert},
+		{name: "Space", dbName: "db", spaceName: "space", want: PrivilegeSelect | PrivilegeInsert | PrivilegeDelete},
+		{name: "Other space", dbName: "db", spaceName: "other", want: PrivilegeSelect | PrivilegeInsert},
+		{name: "Other db", dbName: "other", spaceName: "space", want: PrivilegeSelect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PriviOn(roles, tt.dbName, tt.spaceName); got != tt.want {
+				t.Fatalf("PriviOn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUser_CheckPassword(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		user     User
+		password string
+		hashed   bool
+		want     bool
+	}{
+		{name: "Hashed", user: User{Password: hash}, password: "secret", hashed: true, want: true},
+		{name: "Hashed wrong password", user: User{Password: hash}, password: "other", hashed: true},
+		{name: "Plaintext", user: User{Password: "secret"}, password: "secret", want: true},
+		{name: "Plaintext wrong password", user: User{Password: "secret"}, password: "other"},
+		{name: "Empty", user: User{}, password: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.PasswordHashed(); got != tt.hashed {
+				t.Fatalf("User.PasswordHashed() = %v, want %v", got, tt.hashed)
+			}
+			if got := tt.user.CheckPassword(tt.password); got != tt.want {
+				t.Fatalf("User.CheckPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/vearch/vearch/v3/internal/pkg/netutil"
 	"github.com/vearch/vearch/v3/internal/pkg/server/vearchhttp"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 )
 
 const (
@@ -43,6 +44,9 @@ const (
 	dbName              = "db_name"
 	spaceName           = "space_name"
 	aliasName           = "alias_name"
+	userName            = "user_name"
+	roleName            = "role_name"
+	apiKeyID            = "key_id"
 	headerAuthKey       = "Authorization"
 	NodeID              = "node_id"
 	DefaultResourceName = "default"
@@ -60,11 +64,10 @@ func ExportToClusterHandler(router *gin.Engine, masterService *masterService, se
 
 	c := &clusterAPI{router: router, masterService: masterService, dh: dh, server: server}
 
+	users := masterService.Master()
 	var group *gin.RouterGroup
 	if !config.Conf().Global.SkipAuth {
-		group = router.Group("", dh.PaincHandler, dh.TimeOutHandler, gin.BasicAuth(gin.Accounts{
-			"root": config.Conf().Global.Signkey,
-		}))
+		group = router.Group("", dh.PaincHandler, dh.TimeOutHandler, vearchhttp.Auth(users))
 	} else {
 		group = router.Group("", dh.PaincHandler, dh.TimeOutHandler)
 	}
@@ -72,57 +75,90 @@ func ExportToClusterHandler(router *gin.Engine, masterService *masterService, se
 	group.GET("/", c.handleClusterInfo, dh.TimeOutEndHandler)
 
 	// cluster handler
-	group.GET("/clean_lock", c.cleanLock, dh.TimeOutEndHandler)
+	group.GET("/clean_lock", vearchhttp.Require(users, entity.PrivilegeAlter), c.cleanLock, dh.TimeOutEndHandler)
 
 	// servers handler
-	group.GET("/servers", c.serverList, dh.TimeOutEndHandler)
+	group.GET("/servers", vearchhttp.Require(users, entity.PrivilegeSelect), c.serverList, dh.TimeOutEndHandler)
 
 	// router  handler
-	group.GET("/routers", c.routerList, dh.TimeOutEndHandler)
+	group.GET("/routers", vearchhttp.Require(users, entity.PrivilegeSelect), c.routerList, dh.TimeOutEndHandler)
 
 	// partition register
-	group.POST("/register", c.register, dh.TimeOutEndHandler)
-	group.POST("/register_partition", c.registerPartition, dh.TimeOutEndHandler)
-	group.POST("/register_router", c.registerRouter, dh.TimeOutEndHandler)
+	group.POST("/register", vearchhttp.Require(users, entity.PrivilegeAll), c.register, dh.TimeOutEndHandler)
+	group.POST("/register_partition", vearchhttp.Require(users, entity.PrivilegeAll), c.registerPartition, dh.TimeOutEndHandler)
+	group.POST("/register_router", vearchhttp.Require(users, entity.PrivilegeAll), c.registerRouter, dh.TimeOutEndHandler)
 
 	// db handler
-	group.POST(fmt.Sprintf("/dbs/:%s", dbName), c.createDB, dh.TimeOutEndHandler)
-	group.GET(fmt.Sprintf("/dbs/:%s", dbName), c.getDB, dh.TimeOutEndHandler)
-	group.GET("/dbs", c.getDB, dh.TimeOutEndHandler)
-	group.DELETE(fmt.Sprintf("/dbs/:%s", dbName), c.deleteDB, dh.TimeOutEndHandler)
-	group.PUT(fmt.Sprintf("/dbs/:%s", dbName), c.modifyDB, dh.TimeOutEndHandler)
+	group.POST(fmt.Sprintf("/dbs/:%s", dbName), vearchhttp.Require(users, entity.PrivilegeCreate), c.createDB, dh.TimeOutEndHandler)
+	group.GET(fmt.Sprintf("/dbs/:%s", dbName), vearchhttp.Require(users, entity.PrivilegeSelect, dbName), c.getDB, dh.TimeOutEndHandler)
+	group.GET("/dbs", vearchhttp.Require(users, entity.PrivilegeSelect), c.getDB, dh.TimeOutEndHandler)
+	group.DELETE(fmt.Sprintf("/dbs/:%s", dbName), vearchhttp.Require(users, entity.PrivilegeDrop, dbName), c.deleteDB, dh.TimeOutEndHandler)
+	group.PUT(fmt.Sprintf("/dbs/:%s", dbName), vearchhttp.Require(users, entity.PrivilegeAlter, dbName), c.modifyDB, dh.TimeOutEndHandler)
 
 	// space handler
-	group.POST(fmt.Sprintf("/dbs/:%s/spaces", dbName), c.createSpace, dh.TimeOutEndHandler)
-	group.GET(fmt.Sprintf("/dbs/:%s/spaces/:%s", dbName, spaceName), c.getSpace, dh.TimeOutEndHandler)
-	group.GET(fmt.Sprintf("/dbs/:%s/spaces", dbName), c.getSpace, dh.TimeOutEndHandler)
-	group.DELETE(fmt.Sprintf("/dbs/:%s/spaces/:%s", dbName, spaceName), c.deleteSpace, dh.TimeOutEndHandler)
-	group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s", dbName, spaceName), c.updateSpace, dh.TimeOutEndHandler)
+	group.POST(fmt.Sprintf("/dbs/:%s/spaces", dbName), vearchhttp.Require(users, entity.PrivilegeCreate, dbName), c.createSpace, dh.TimeOutEndHandler)
+	group.GET(fmt.Sprintf("/dbs/:%s/spaces/:%s", dbName, spaceName), vearchhttp.Require(users, entity.PrivilegeSelect, dbName, spaceName), c.getSpace, dh.TimeOutEndHandler)
+	group.GET(fmt.Sprintf("/dbs/:%s/spaces", dbName), vearchhttp.Require(users, entity.PrivilegeSelect, dbName), c.getSpace, dh.TimeOutEndHandler)
+	group.DELETE(fmt.Sprintf("/dbs/:%s/spaces/:%s", dbName, spaceName), vearchhttp.Require(users, entity.PrivilegeDrop, dbName, spaceName), c.deleteSpace, dh.TimeOutEndHandler)
+	group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s", dbName, spaceName), vearchhttp.Require(users, entity.PrivilegeAlter, dbName, spaceName), c.updateSpace, dh.TimeOutEndHandler)
 
 	// modify engine config handler
-	group.POST("/config/:"+dbName+"/:"+spaceName, c.modifyEngineCfg, dh.TimeOutEndHandler)
-	group.GET("/config/:"+dbName+"/:"+spaceName, c.getEngineCfg, dh.TimeOutEndHandler)
+	group.POST("/config/:"+dbName+"/:"+spaceName, vearchhttp.Require(users, entity.PrivilegeAlter, dbName, spaceName), c.modifyEngineCfg, dh.TimeOutEndHandler)
+	group.GET("/config/:"+dbName+"/:"+spaceName, vearchhttp.Require(users, entity.PrivilegeSelect, dbName, spaceName), c.getEngineCfg, dh.TimeOutEndHandler)
 
 	// partition handler
-	group.GET("/partitions", c.partitionList, dh.TimeOutEndHandler)
-	group.POST("/partitions/change_member", c.changeMember, dh.TimeOutEndHandler)
+	group.GET("/partitions", vearchhttp.Require(users, entity.PrivilegeSelect), c.partitionList, dh.TimeOutEndHandler)
+	group.POST("/partitions/change_member", vearchhttp.Require(users, entity.PrivilegeAlter), c.changeMember, dh.TimeOutEndHandler)
 
 	// schedule
-	group.POST("/schedule/recover_server", c.RecoverFailServer, dh.TimeOutEndHandler)
-	group.POST("/schedule/change_replicas", c.ChangeReplicas, dh.TimeOutEndHandler)
-	group.GET("/schedule/fail_server", c.FailServerList, dh.TimeOutEndHandler)
-	group.DELETE("/schedule/fail_server/:"+NodeID, c.FailServerClear, dh.TimeOutEndHandler)
-	group.GET("/schedule/clean_task", c.CleanTask, dh.TimeOutEndHandler)
+	group.POST("/schedule/recover_server", vearchhttp.Require(users, entity.PrivilegeAlter), c.RecoverFailServer, dh.TimeOutEndHandler)
+	group.POST("/schedule/change_replicas", vearchhttp.Require(users, entity.PrivilegeAlter), c.ChangeReplicas, dh.TimeOutEndHandler)
+	group.GET("/schedule/fail_server", vearchhttp.Require(users, entity.PrivilegeSelect), c.FailServerList, dh.TimeOutEndHandler)
+	group.DELETE("/schedule/fail_server/:"+NodeID, vearchhttp.Require(users, entity.PrivilegeAlter), c.FailServerClear, dh.TimeOutEndHandler)
+	group.GET("/schedule/clean_task", vearchhttp.Require(users, entity.PrivilegeAlter), c.CleanTask, dh.TimeOutEndHandler)
 
 	// remove server metadata
-	group.POST("/meta/remove_server", c.RemoveServerMeta, dh.TimeOutEndHandler)
+	group.POST("/meta/remove_server", vearchhttp.Require(users, entity.PrivilegeAlter), c.RemoveServerMeta, dh.TimeOutEndHandler)
 
 	// alias handler
-	group.POST(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", aliasName, dbName, spaceName), c.createAlias, dh.TimeOutEndHandler)
-	group.GET(fmt.Sprintf("/alias/:%s", aliasName), c.getAlias, dh.TimeOutEndHandler)
-	group.GET("/alias", c.getAlias, dh.TimeOutEndHandler)
+	group.POST(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", aliasName, dbName, spaceName), vearchhttp.Require(users, entity.PrivilegeAlter, dbName, spaceName), c.createAlias, dh.TimeOutEndHandler)
+	group.GET(fmt.Sprintf("/alias/:%s", aliasName), vearchhttp.Require(users, entity.PrivilegeSelect), c.getAlias, dh.TimeOutEndHandler)
+	group.GET("/alias", vearchhttp.Require(users, entity.PrivilegeSelect), c.getAlias, dh.TimeOutEndHandler)
 	group.DELETE(fmt.Sprintf("/alias/:%s", aliasName), c.deleteAlias, dh.TimeOutEndHandler)
-	group.PUT(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", aliasName, dbName, spaceName), c.modifyAlias, dh.TimeOutEndHandler)
+	group.PUT(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", aliasName, dbName, spaceName), vearchhttp.Require(users, entity.PrivilegeAlter, dbName, spaceName), c.modifyAlias, dh.TimeOutEndHandler)
+
+	// rate limit handler
+	group.GET("/rate_limits", vearchhttp.Require(users, entity.PrivilegeSelect), c.getRateLimit, dh.TimeOutEndHandler)
+	for _, path := range []string{
+		fmt.Sprintf("/rate_limits/users/:%s", userName),
+		fmt.Sprintf("/rate_limits/dbs/:%s", dbName),
+		fmt.Sprintf("/rate_limits/dbs/:%s/spaces/:%s", dbName, spaceName),
+	} {
+		group.PUT(path, vearchhttp.Require(users, entity.PrivilegeGrant), c.setRateLimit, dh.TimeOutEndHandler)
+		group.GET(path, vearchhttp.Require(users, entity.PrivilegeSelect), c.getRateLimit, dh.TimeOutEndHandler)
+		group.DELETE(path, vearchhttp.Require(users, entity.PrivilegeGrant), c.deleteRateLimit, dh.TimeOutEndHandler)
+	}
+
+	// user handler
+	group.POST("/users", vearchhttp.Require(users, entity.PrivilegeGrant), c.createUser, dh.TimeOutEndHandler)
+	group.GET("/users", vearchhttp.Require(users, entity.PrivilegeGrant), c.getUser, dh.TimeOutEndHandler)
+	group.GET(fmt.Sprintf("/users/:%s", userName), vearchhttp.Require(users, entity.PrivilegeGrant), c.getUser, dh.TimeOutEndHandler)
+	group.PUT(fmt.Sprintf("/users/:%s", userName), vearchhttp.Require(users, entity.PrivilegeGrant), c.updateUser, dh.TimeOutEndHandler)
+	group.DELETE(fmt.Sprintf("/users/:%s", userName), vearchhttp.Require(users, entity.PrivilegeGrant), c.deleteUser, dh.TimeOutEndHandler)
+
+	// role handler
+	group.POST("/roles", vearchhttp.Require(users, entity.PrivilegeGrant), c.createRole, dh.TimeOutEndHandler)
+	group.GET("/roles", vearchhttp.Require(users, entity.PrivilegeGrant), c.getRole, dh.TimeOutEndHandler)
+	group.GET(fmt.Sprintf("/roles/:%s", roleName), vearchhttp.Require(users, entity.PrivilegeGrant), c.getRole, dh.TimeOutEndHandler)
+	group.PUT(fmt.Sprintf("/roles/:%s", roleName), vearchhttp.Require(users, entity.PrivilegeGrant), c.updateRole, dh.TimeOutEndHandler)
+	group.DELETE(fmt.Sprintf("/roles/:%s", roleName), vearchhttp.Require(users, entity.PrivilegeGrant), c.deleteRole, dh.TimeOutEndHandler)
+
+	// api key handler, users manage their own keys and grant is needed for
+	// the keys of others
+	group.POST("/api_keys", c.createApiKey, dh.TimeOutEndHandler)
+	group.GET("/api_keys", c.getApiKey, dh.TimeOutEndHandler)
+	group.GET(fmt.Sprintf("/api_keys/:%s", apiKeyID), c.getApiKey, dh.TimeOutEndHandler)
+	group.DELETE(fmt.Sprintf("/api_keys/:%s", apiKeyID), c.deleteApiKey, dh.TimeOutEndHandler)
 }
 
 func (ca *clusterAPI) handleClusterInfo(c *gin.Context) {
@@ -406,14 +442,17 @@ func (ca *clusterAPI) updateSpace(c *gin.Context) {
 	dbName := c.Param(dbName)
 	spaceName := c.Param(spaceName)
 
-	space := &entity.Space{Name: spaceName}
+	req := &struct {
+		*entity.Space
+		DropFields []string `json:"drop_fields,omitempty"`
+	}{Space: &entity.Space{Name: spaceName}}
 
-	if err := c.ShouldBindJSON(space); err != nil {
+	if err := c.ShouldBindJSON(req); err != nil {
 		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
 		return
 	}
 
-	if spaceResult, err := ca.masterService.updateSpaceService(c, dbName, spaceName, space); err != nil {
+	if spaceResult, err := ca.masterService.updateSpaceService(c, dbName, spaceName, req.Space, req.DropFields); err != nil {
 		httphelper.New(c).JsonError(errors.NewErrInternal(err))
 
 	} else {
@@ -450,6 +489,15 @@ func (ca *clusterAPI) deleteAlias(c *gin.Context) {
 	log.Debug("delete alias: %s", c.Param(aliasName))
 	aliasName := c.Param(aliasName)
 
+	// the alias is dropped on the space it points to
+	alias, err := ca.masterService.queryAliasService(c, aliasName)
+	if err != nil {
+		httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+		return
+	}
+	if !vearchhttp.Allow(c, ca.masterService.Master(), alias.DbName, alias.SpaceName, entity.PrivilegeAlter) {
+		return
+	}
 	if err := ca.masterService.deleteAliasService(c, aliasName); err != nil {
 		httphelper.New(c).JsonError(errors.NewErrInternal(err))
 	} else {
@@ -488,6 +536,16 @@ func (ca *clusterAPI) modifyAlias(c *gin.Context) {
 		return
 	}
 
+	// moving an alias alters the space it pointed to as well
+	old, err := ca.masterService.queryAliasService(c, aliasName)
+	if err != nil {
+		httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+		return
+	}
+	if !vearchhttp.Allow(c, ca.masterService.Master(), old.DbName, old.SpaceName, entity.PrivilegeAlter) {
+		return
+	}
+
 	alias := &entity.Alias{
 		Name:      aliasName,
 		DbName:    dbName,
@@ -500,6 +558,318 @@ func (ca *clusterAPI) modifyAlias(c *gin.Context) {
 	}
 }
 
+// rateLimitScope returns the scope and name of rate limit in path
+func rateLimitScope(c *gin.Context) (string, string) {
+	if user := c.Param(userName); user != "" {
+		return entity.RateLimitScopeUser, user
+	}
+	if space := c.Param(spaceName); space != "" {
+		return entity.RateLimitScopeSpace, entity.RateLimitName(entity.RateLimitScopeSpace, "", c.Param(dbName), space)
+	}
+	return entity.RateLimitScopeDB, c.Param(dbName)
+}
+
+func (ca *clusterAPI) setRateLimit(c *gin.Context) {
+	limit := &entity.RateLimit{}
+	if err := c.ShouldBindJSON(limit); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	limit.Scope, limit.Name = rateLimitScope(c)
+
+	if limit.Scope != entity.RateLimitScopeUser {
+		dbID, err := ca.masterService.Master().QueryDBName2Id(c, c.Param(dbName))
+		if err != nil {
+			httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+			return
+		}
+		if limit.Scope == entity.RateLimitScopeSpace {
+			if _, err := ca.masterService.Master().QuerySpaceByName(c, dbID, c.Param(spaceName)); err != nil {
+				httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+				return
+			}
+		}
+	}
+
+	log.Debug("set rate limit: %+v", *limit)
+	if err := ca.masterService.setRateLimitService(c, limit); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		httphelper.New(c).JsonSuccess(limit)
+	}
+}
+
+func (ca *clusterAPI) getRateLimit(c *gin.Context) {
+	if c.Param(userName) == "" && c.Param(dbName) == "" {
+		if limits, err := ca.masterService.queryRateLimits(c); err != nil {
+			httphelper.New(c).JsonError(errors.NewErrInternal(err))
+		} else {
+			httphelper.New(c).JsonSuccess(limits)
+		}
+		return
+	}
+	scope, name := rateLimitScope(c)
+	if limit, err := ca.masterService.queryRateLimitService(c, scope, name); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+	} else {
+		httphelper.New(c).JsonSuccess(limit)
+	}
+}
+
+func (ca *clusterAPI) deleteRateLimit(c *gin.Context) {
+	scope, name := rateLimitScope(c)
+	log.Debug("delete rate limit of %s %s", scope, name)
+	if err := ca.masterService.deleteRateLimitService(c, scope, name); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+	} else {
+		httphelper.New(c).SuccessDelete()
+	}
+}
+
+// withoutPassword is the user in responses
+func withoutPassword(user *entity.User) *entity.User {
+	u := *user
+	u.Password = ""
+	return &u
+}
+
+func (ca *clusterAPI) createUser(c *gin.Context) {
+	req := &entity.User{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	user := &entity.User{Name: req.Name, Password: req.Password, Roles: req.Roles}
+	log.Debug("create user: %s, roles: %v", user.Name, user.Roles)
+	if err := ca.masterService.createUserService(c, user); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		httphelper.New(c).JsonSuccess(withoutPassword(user))
+	}
+}
+
+func (ca *clusterAPI) updateUser(c *gin.Context) {
+	req := &entity.User{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	update := &entity.User{Name: c.Param(userName), Password: req.Password, Roles: req.Roles}
+	if update.Name == entity.RootUser {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(fmt.Errorf("user %s is reserved", entity.RootUser)))
+		return
+	}
+	log.Debug("update user: %s, roles: %v", update.Name, update.Roles)
+	if user, err := ca.masterService.updateUserService(c, update); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		httphelper.New(c).JsonSuccess(withoutPassword(user))
+	}
+}
+
+func (ca *clusterAPI) getUser(c *gin.Context) {
+	name := c.Param(userName)
+	if name == "" {
+		users, err := ca.masterService.queryUsers(c)
+		if err != nil {
+			httphelper.New(c).JsonError(errors.NewErrInternal(err))
+			return
+		}
+		for i, user := range users {
+			users[i] = withoutPassword(user)
+		}
+		httphelper.New(c).JsonSuccess(users)
+		return
+	}
+	if user, err := ca.masterService.queryUserService(c, name); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+	} else {
+		httphelper.New(c).JsonSuccess(withoutPassword(user))
+	}
+}
+
+func (ca *clusterAPI) deleteUser(c *gin.Context) {
+	name := c.Param(userName)
+	log.Debug("delete user: %s", name)
+	if err := ca.masterService.deleteUserService(c, name); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+	} else {
+		httphelper.New(c).SuccessDelete()
+	}
+}
+
+func (ca *clusterAPI) createRole(c *gin.Context) {
+	role := &entity.Role{}
+	if err := c.ShouldBindJSON(role); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	log.Debug("create role: %s", role.Name)
+	if err := ca.masterService.createRoleService(c, role); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		httphelper.New(c).JsonSuccess(role)
+	}
+}
+
+// updateRole grants, revokes or replaces the privileges of role
+func (ca *clusterAPI) updateRole(c *gin.Context) {
+	req := &struct {
+		Operator   string                `json:"operator"`
+		Privileges []*entity.RoleBinding `json:"privileges"`
+	}{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	update := &entity.Role{Name: c.Param(roleName), Privileges: req.Privileges}
+	log.Debug("update role: %s, operator: %s", update.Name, req.Operator)
+	if role, err := ca.masterService.updateRoleService(c, req.Operator, update); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		httphelper.New(c).JsonSuccess(role)
+	}
+}
+
+func (ca *clusterAPI) getRole(c *gin.Context) {
+	name := c.Param(roleName)
+	if name == "" {
+		if roles, err := ca.masterService.queryRoles(c); err != nil {
+			httphelper.New(c).JsonError(errors.NewErrInternal(err))
+		} else {
+			httphelper.New(c).JsonSuccess(roles)
+		}
+		return
+	}
+	if role, err := ca.masterService.queryRoleService(c, name); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+	} else {
+		httphelper.New(c).JsonSuccess(role)
+	}
+}
+
+func (ca *clusterAPI) deleteRole(c *gin.Context) {
+	name := c.Param(roleName)
+	log.Debug("delete role: %s", name)
+	if err := ca.masterService.deleteRoleService(c, name); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+	} else {
+		httphelper.New(c).SuccessDelete()
+	}
+}
+
+// apiKeyCaller returns the user of request and whether it can manage the
+// api keys of others, anyone can when auth is skipped
+func (ca *clusterAPI) apiKeyCaller(c *gin.Context) (*entity.User, bool) {
+	get, exists := c.Get(vearchhttp.UserKey)
+	if !exists {
+		return nil, true
+	}
+	user := get.(*entity.User)
+	if _, ok := c.Get(vearchhttp.ApiKeyKey); ok {
+		return user, false
+	}
+	return user, entity.HasPrivi(ca.masterService.Master().PriviOn(c, user, "", ""), entity.PrivilegeGrant)
+}
+
+// allowApiKey checks the caller can manage the api keys of owner
+func (ca *clusterAPI) allowApiKey(c *gin.Context, owner string) bool {
+	user, admin := ca.apiKeyCaller(c)
+	if admin || user.Name == owner {
+		return true
+	}
+	return vearchhttp.Allow(c, ca.masterService.Master(), "", "", entity.PrivilegeGrant)
+}
+
+// createApiKey issues a key to user, the caller by default, the token is
+// only returned here
+func (ca *clusterAPI) createApiKey(c *gin.Context) {
+	req := &struct {
+		Name          string                `json:"name"`
+		User          string                `json:"user"`
+		Scopes        []*entity.RoleBinding `json:"scopes"`
+		ExpireSeconds int64                 `json:"expire_seconds"`
+	}{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	if _, ok := c.Get(vearchhttp.ApiKeyKey); ok {
+		err := vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, fmt.Errorf("api key can not issue api keys"))
+		httphelper.New(c).JsonError(errors.NewErrForbidden(err))
+		return
+	}
+	if req.User == "" {
+		req.User = entity.RootUser
+		if user, _ := ca.apiKeyCaller(c); user != nil {
+			req.User = user.Name
+		}
+	}
+	if !ca.allowApiKey(c, req.User) {
+		return
+	}
+	if req.ExpireSeconds < 0 {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(fmt.Errorf("expire_seconds can not be negative")))
+		return
+	}
+
+	key := &entity.ApiKey{Name: req.Name, User: req.User, Scopes: req.Scopes}
+	if req.ExpireSeconds > 0 {
+		key.ExpireAt = time.Now().Unix() + req.ExpireSeconds
+	}
+	log.Debug("create api key: %s for user: %s", key.Name, key.User)
+	token, err := ca.masterService.createApiKeyService(c, key)
+	if err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	httphelper.New(c).JsonSuccess(map[string]interface{}{"key": key, "token": token})
+}
+
+func (ca *clusterAPI) getApiKey(c *gin.Context) {
+	id := c.Param(apiKeyID)
+	if id == "" {
+		owner := ""
+		if user, admin := ca.apiKeyCaller(c); !admin {
+			owner = user.Name
+		}
+		if keys, err := ca.masterService.queryApiKeys(c, owner); err != nil {
+			httphelper.New(c).JsonError(errors.NewErrInternal(err))
+		} else {
+			httphelper.New(c).JsonSuccess(keys)
+		}
+		return
+	}
+	key, err := ca.masterService.queryApiKeyService(c, id)
+	if err != nil {
+		httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+		return
+	}
+	if ca.allowApiKey(c, key.User) {
+		httphelper.New(c).JsonSuccess(key)
+	}
+}
+
+// deleteApiKey revokes the key, tokens of it are rejected once routers see
+// the deletion
+func (ca *clusterAPI) deleteApiKey(c *gin.Context) {
+	id := c.Param(apiKeyID)
+	log.Debug("delete api key: %s", id)
+	key, err := ca.masterService.queryApiKeyService(c, id)
+	if err != nil {
+		httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+		return
+	}
+	if !ca.allowApiKey(c, key.User) {
+		return
+	}
+	if err := ca.masterService.deleteApiKeyService(c, id); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrInternal(err))
+	} else {
+		httphelper.New(c).SuccessDelete()
+	}
+}
+
 // get engine config
 func (ca *clusterAPI) getEngineCfg(c *gin.Context) {
 	var err error
@@ -17,12 +17,15 @@ package master
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"math"
+	"reflect"
 	"sort"
 	"time"
 
 	"github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
 	"github.com/vearch/vearch/v3/internal/client"
@@ -42,6 +45,9 @@ type masterService struct {
 	*client.Client
 }
 
+// isLive tells if the partition server of addr answers
+var isLive = client.IsLive
+
 func newMasterService(client *client.Client) (*masterService, error) {
 	return &masterService{client}, nil
 }
@@ -370,6 +376,13 @@ func (ms *masterService) createSpaceService(ctx context.Context, dbName string,
 		}
 	}
 
+	if err = space.ValidateTTL(); err != nil {
+		return err
+	}
+	if err = space.ValidateEmbedders(); err != nil {
+		return err
+	}
+
 	marshal, err := vjson.Marshal(space)
 	if err != nil {
 		return err
@@ -473,22 +486,35 @@ func (ms *masterService) generatePartitionsInfo(servers []*entity.Server, server
 		return kvList[i].length < kvList[j].length
 	})
 
-	//find addr for all servers
-	for _, kv := range kvList {
-		addr := servers[kv.index].RpcAddr()
-		ID := servers[kv.index].ID
-
-		if !client.IsLive(addr) {
-			serverPartitions[kv.index] = kv.length
-			continue
-		}
-		serverPartitions[kv.index] = serverPartitions[kv.index] + 1
-		addres = append(addres, addr)
-		partition.Replicas = append(partition.Replicas, ID)
-
-		replicaNum--
-		if replicaNum <= 0 {
-			break
+	//find addr for all servers, spread replicas over zones first, then
+	//racks, then the servers with least partitions
+	zones := make(map[string]bool)
+	racks := make(map[string]bool)
+	skip := make(map[int]bool)
+	for pass := 0; pass < 3 && replicaNum > 0; pass++ {
+		for _, kv := range kvList {
+			if skip[kv.index] {
+				continue
+			}
+			server := servers[kv.index]
+			if (pass == 0 && zones[server.Zone]) || (pass == 1 && racks[server.Zone+"/"+server.Rack]) {
+				continue
+			}
+			addr := server.RpcAddr()
+			skip[kv.index] = true
+			if !isLive(addr) {
+				continue
+			}
+			serverPartitions[kv.index] = serverPartitions[kv.index] + 1
+			addres = append(addres, addr)
+			partition.Replicas = append(partition.Replicas, server.ID)
+			zones[server.Zone] = true
+			racks[server.Zone+"/"+server.Rack] = true
+
+			replicaNum--
+			if replicaNum <= 0 {
+				break
+			}
 		}
 	}
 
@@ -620,6 +646,14 @@ func (ms *masterService) deleteSpaceService(ctx context.Context, dbName string,
 		}
 	}
 
+	// delete rate limit
+	rateLimitKey := entity.RateLimitKey(entity.RateLimitScopeSpace, entity.RateLimitName(entity.RateLimitScopeSpace, "", dbName, spaceName))
+	if bs, _ := ms.Master().Get(ctx, rateLimitKey); bs != nil {
+		if err := ms.Master().Delete(ctx, rateLimitKey); err != nil {
+			log.Error("delete rate limit of space:[%s/%s] err:[%s]", dbName, spaceName, err.Error())
+		}
+	}
+
 	return nil
 }
 
@@ -676,6 +710,11 @@ func (ms *masterService) describeSpaceService(ctx context.Context, space *entity
 			if len(partitionInfo.Unreachable) > 0 {
 				pStatus = 1
 			}
+			// the partition still serves the old schema
+			if partitionInfo.Rebuild != nil && partitionInfo.Rebuild.Stage == entity.RebuildStageFailed {
+				*(spaceInfo.Errors) = append(*(spaceInfo.Errors), fmt.Sprintf("space:[%s] partition:[%d] rebuild to version:[%d] failed: %s", spaceName, spacePartition.Id, partitionInfo.Rebuild.Version, partitionInfo.Rebuild.Error))
+				pStatus = 1
+			}
 		}
 
 		replicasStatus := make(map[entity.NodeID]string)
@@ -704,6 +743,18 @@ func (ms *masterService) describeSpaceService(ctx context.Context, space *entity
 	docNum := uint64(0)
 	for _, p := range spaceInfo.Partitions {
 		docNum += cast.ToUint64(p.DocNum)
+		if p.Rebuild != nil {
+			if spaceInfo.Rebuild == nil {
+				spaceInfo.Rebuild = &entity.SpaceRebuild{}
+			}
+			if p.Rebuild.Stage == entity.RebuildStageFailed {
+				spaceInfo.Rebuild.Failed++
+				continue
+			}
+			spaceInfo.Rebuild.Partitions++
+			spaceInfo.Rebuild.Done += p.Rebuild.Done
+			spaceInfo.Rebuild.Total += p.Rebuild.Total
+		}
 	}
 	spaceInfo.Status = color[spaceStatus]
 	spaceInfo.DocNum = docNum
@@ -842,6 +893,371 @@ func (ms *masterService) queryAliasService(ctx context.Context, alias_name strin
 	return alias, nil
 }
 
+// setRateLimitService keys "/ratelimit/scope/name:limit", the routers watch
+// the prefix
+func (ms *masterService) setRateLimitService(ctx context.Context, limit *entity.RateLimit) (err error) {
+	if err = limit.Validate(); err != nil {
+		return err
+	}
+	marshal, err := vjson.Marshal(limit)
+	if err != nil {
+		return err
+	}
+	return ms.Master().Put(ctx, entity.RateLimitKey(limit.Scope, limit.Name), marshal)
+}
+
+func (ms *masterService) queryRateLimitService(ctx context.Context, scope, name string) (*entity.RateLimit, error) {
+	bs, err := ms.Master().Get(ctx, entity.RateLimitKey(scope, name))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rate limit of %s %s not exist", scope, name))
+	}
+	limit := &entity.RateLimit{}
+	if err = vjson.Unmarshal(bs, limit); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get rate limit of %s %s value:%s, err:%s", scope, name, string(bs), err.Error()))
+	}
+	return limit, nil
+}
+
+func (ms *masterService) queryRateLimits(ctx context.Context) ([]*entity.RateLimit, error) {
+	_, values, err := ms.Master().PrefixScan(ctx, entity.PrefixRateLimit)
+	if err != nil {
+		return nil, err
+	}
+	limits := make([]*entity.RateLimit, 0, len(values))
+	for _, value := range values {
+		limit := &entity.RateLimit{}
+		if err := vjson.Unmarshal(value, limit); err != nil {
+			log.Error("decode rate limit err: %s, and the value is:%s", err.Error(), string(value))
+			continue
+		}
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
+
+func (ms *masterService) deleteRateLimitService(ctx context.Context, scope, name string) error {
+	if _, err := ms.queryRateLimitService(ctx, scope, name); err != nil {
+		return err
+	}
+	return ms.Master().Delete(ctx, entity.RateLimitKey(scope, name))
+}
+
+// createUserService keys "/user/name:user", the roles of user should exist
+func (ms *masterService) createUserService(ctx context.Context, user *entity.User) error {
+	if err := user.Validate(); err != nil {
+		return err
+	}
+	hash, err := entity.HashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+	stored := *user
+	stored.Password = hash
+	return ms.Master().STM(ctx, func(stm concurrency.STM) error {
+		if stm.Get(entity.UserKey(user.Name)) != "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("user %s already exists", user.Name))
+		}
+		if err := rolesExist(stm, user.Roles); err != nil {
+			return err
+		}
+		marshal, err := vjson.Marshal(&stored)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.UserKey(user.Name), string(marshal))
+		return nil
+	})
+}
+
+// updateUserService changes the password and roles of user, an empty
+// password or nil roles are kept
+func (ms *masterService) updateUserService(ctx context.Context, update *entity.User) (user *entity.User, err error) {
+	var hash string
+	if update.Password != "" {
+		if err = entity.ValidatePassword(update.Password); err != nil {
+			return nil, err
+		}
+		if hash, err = entity.HashPassword(update.Password); err != nil {
+			return nil, err
+		}
+	}
+	err = ms.Master().STM(ctx, func(stm concurrency.STM) error {
+		value := stm.Get(entity.UserKey(update.Name))
+		if value == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_USER_NOT_EXIST, fmt.Errorf("user %s not exist", update.Name))
+		}
+		user = &entity.User{}
+		if err := vjson.Unmarshal([]byte(value), user); err != nil {
+			return err
+		}
+		if hash != "" {
+			user.Password = hash
+		}
+		if update.Roles != nil {
+			if err := rolesExist(stm, update.Roles); err != nil {
+				return err
+			}
+			user.Roles = update.Roles
+		}
+		marshal, err := vjson.Marshal(user)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.UserKey(user.Name), string(marshal))
+		return nil
+	})
+	return user, err
+}
+
+func rolesExist(stm concurrency.STM, roles []string) error {
+	for _, role := range roles {
+		if stm.Get(entity.RoleKey(role)) == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("role %s not exist", role))
+		}
+	}
+	return nil
+}
+
+func (ms *masterService) queryUserService(ctx context.Context, name string) (*entity.User, error) {
+	return ms.Master().QueryUser(ctx, name)
+}
+
+func (ms *masterService) queryUsers(ctx context.Context) ([]*entity.User, error) {
+	_, values, err := ms.Master().PrefixScan(ctx, entity.PrefixUser)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*entity.User, 0, len(values))
+	for _, value := range values {
+		user := &entity.User{}
+		if err := vjson.Unmarshal(value, user); err != nil {
+			log.Error("decode user err: %s, and the value is:%s", err.Error(), string(value))
+			continue
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (ms *masterService) deleteUserService(ctx context.Context, name string) error {
+	if _, err := ms.Master().QueryUser(ctx, name); err != nil {
+		return err
+	}
+	return ms.Master().Delete(ctx, entity.UserKey(name))
+}
+
+// checkRole validates role and the dbs and spaces it is granted on
+func (ms *masterService) checkRole(ctx context.Context, role *entity.Role) error {
+	if err := role.Validate(); err != nil {
+		return err
+	}
+	return ms.checkBindings(ctx, role.Privileges)
+}
+
+// checkBindings checks the dbs and spaces of bindings exist
+func (ms *masterService) checkBindings(ctx context.Context, bindings []*entity.RoleBinding) error {
+	for _, b := range bindings {
+		if b.Scope == entity.RoleScopeCluster {
+			continue
+		}
+		dbID, err := ms.Master().QueryDBName2Id(ctx, b.DbName)
+		if err != nil {
+			return err
+		}
+		if b.Scope == entity.RoleScopeSpace {
+			if _, err := ms.Master().QuerySpaceByName(ctx, dbID, b.SpaceName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createRoleService keys "/role/name:role", the routers watch the prefix
+func (ms *masterService) createRoleService(ctx context.Context, role *entity.Role) error {
+	if err := ms.checkRole(ctx, role); err != nil {
+		return err
+	}
+	return ms.Master().STM(ctx, func(stm concurrency.STM) error {
+		if stm.Get(entity.RoleKey(role.Name)) != "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("role %s already exists", role.Name))
+		}
+		marshal, err := vjson.Marshal(role)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.RoleKey(role.Name), string(marshal))
+		return nil
+	})
+}
+
+const (
+	roleOperatorGrant  = "grant"
+	roleOperatorRevoke = "revoke"
+)
+
+// updateRoleService grants or revokes the privileges of update to role, the
+// privileges of role are replaced if operator is empty
+func (ms *masterService) updateRoleService(ctx context.Context, operator string, update *entity.Role) (role *entity.Role, err error) {
+	switch operator {
+	case "", roleOperatorGrant, roleOperatorRevoke:
+	default:
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("operator should be empty, %s or %s", roleOperatorGrant, roleOperatorRevoke))
+	}
+	if err = ms.checkRole(ctx, update); err != nil {
+		return nil, err
+	}
+	err = ms.Master().STM(ctx, func(stm concurrency.STM) error {
+		value := stm.Get(entity.RoleKey(update.Name))
+		if value == "" {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("role %s not exist", update.Name))
+		}
+		role = &entity.Role{}
+		if err := vjson.Unmarshal([]byte(value), role); err != nil {
+			return err
+		}
+		switch operator {
+		case roleOperatorGrant:
+			role.Grant(update.Privileges)
+		case roleOperatorRevoke:
+			role.Revoke(update.Privileges)
+		default:
+			role.Privileges = update.Privileges
+		}
+		marshal, err := vjson.Marshal(role)
+		if err != nil {
+			return err
+		}
+		stm.Put(entity.RoleKey(role.Name), string(marshal))
+		return nil
+	})
+	return role, err
+}
+
+func (ms *masterService) queryRoleService(ctx context.Context, name string) (*entity.Role, error) {
+	bs, err := ms.Master().Get(ctx, entity.RoleKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("role %s not exist", name))
+	}
+	role := &entity.Role{}
+	if err = vjson.Unmarshal(bs, role); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get role %s value:%s, err:%s", name, string(bs), err.Error()))
+	}
+	return role, nil
+}
+
+func (ms *masterService) queryRoles(ctx context.Context) ([]*entity.Role, error) {
+	_, values, err := ms.Master().PrefixScan(ctx, entity.PrefixRole)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]*entity.Role, 0, len(values))
+	for _, value := range values {
+		role := &entity.Role{}
+		if err := vjson.Unmarshal(value, role); err != nil {
+			log.Error("decode role err: %s, and the value is:%s", err.Error(), string(value))
+			continue
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// deleteRoleService deletes a role granted to no user
+func (ms *masterService) deleteRoleService(ctx context.Context, name string) error {
+	if _, err := ms.queryRoleService(ctx, name); err != nil {
+		return err
+	}
+	users, err := ms.queryUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		for _, role := range user.Roles {
+			if role == name {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("role %s is granted to user %s", name, user.Name))
+			}
+		}
+	}
+	return ms.Master().Delete(ctx, entity.RoleKey(name))
+}
+
+// createApiKeyService keys "/apikey/id:key" and returns the key with its
+// signed token, the token is not stored and can not be read again
+func (ms *masterService) createApiKeyService(ctx context.Context, key *entity.ApiKey) (string, error) {
+	if err := key.Validate(); err != nil {
+		return "", err
+	}
+	if err := ms.checkBindings(ctx, key.Scopes); err != nil {
+		return "", err
+	}
+	if key.User != entity.RootUser {
+		if _, err := ms.queryUserService(ctx, key.User); err != nil {
+			return "", err
+		}
+	}
+	key.ID = uuid.NewString()
+	key.CreateAt = time.Now().Unix()
+	token, err := entity.SignToken(key, config.Conf().Global.Signkey)
+	if err != nil {
+		return "", err
+	}
+	marshal, err := vjson.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	if err := ms.Master().Put(ctx, entity.ApiKeyKey(key.ID), marshal); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (ms *masterService) queryApiKeyService(ctx context.Context, id string) (*entity.ApiKey, error) {
+	bs, err := ms.Master().Get(ctx, entity.ApiKeyKey(id))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("api key %s not exist", id))
+	}
+	key := &entity.ApiKey{}
+	if err = vjson.Unmarshal(bs, key); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("get api key %s value:%s, err:%s", id, string(bs), err.Error()))
+	}
+	return key, nil
+}
+
+// queryApiKeys returns the api keys of user, all keys if user is empty
+func (ms *masterService) queryApiKeys(ctx context.Context, user string) ([]*entity.ApiKey, error) {
+	_, values, err := ms.Master().PrefixScan(ctx, entity.PrefixApiKey)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*entity.ApiKey, 0, len(values))
+	for _, value := range values {
+		key := &entity.ApiKey{}
+		if err := vjson.Unmarshal(value, key); err != nil {
+			log.Error("decode api key err: %s, and the value is:%s", err.Error(), string(value))
+			continue
+		}
+		if user == "" || key.User == user {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// deleteApiKeyService revokes the key and every token signed for it
+func (ms *masterService) deleteApiKeyService(ctx context.Context, id string) error {
+	return ms.Master().Delete(ctx, entity.ApiKeyKey(id))
+}
+
 func (ms *masterService) GetEngineCfg(ctx context.Context, dbName, spaceName string) (cfg *entity.EngineCfg, err error) {
 	defer errutil.CatchError(&err)
 	// get space info
@@ -908,7 +1324,26 @@ func (ms *masterService) ModifyEngineCfg(ctx context.Context, dbName,
 	return nil
 }
 
-func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceName string, temp *entity.Space) (*entity.Space, error) {
+// validateUpdatedSpace check the ttl and embedders of space still match its
+// fields after an update
+func validateUpdatedSpace(space *entity.Space) (err error) {
+	if space.TTL == nil && len(space.Embedders) == 0 {
+		return nil
+	}
+	if space.SpaceProperties == nil {
+		if space.SpaceProperties, err = entity.UnmarshalPropertyJSON(space.Fields); err != nil {
+			return err
+		}
+	}
+	if err = space.ValidateTTL(); err != nil {
+		return err
+	}
+	return space.ValidateEmbedders()
+}
+
+// updateSpaceService updates space by temp, fields in dropFields are removed
+// from the schema, partitions rebuild their engine if the schema is changed
+func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceName string, temp *entity.Space, dropFields []string) (*entity.Space, error) {
 	// it will lock cluster ,to create space
 	mutex := ms.Master().NewLock(ctx, entity.LockSpaceKey(dbName, spaceName), time.Second*300)
 	if err := mutex.Lock(); err != nil {
@@ -957,6 +1392,14 @@ func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceNa
 		space.Enabled = temp.Enabled
 	}
 
+	if temp.Embedders != nil {
+		space.Embedders = temp.Embedders
+	}
+
+	if temp.TTL != nil {
+		space.TTL = temp.TTL
+	}
+
 	if err := space.Validate(); err != nil {
 		return nil, err
 	}
@@ -964,39 +1407,57 @@ func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceNa
 	space.Version++
 	space.Partitions = temp.Partitions
 
-	if temp.Fields != nil && len(temp.Fields) > 0 {
+	if (temp.Fields != nil && len(temp.Fields) > 0) || len(dropFields) > 0 {
 		//parse old space
 		oldFieldMap, err := mapping.SchemaMap(space.Fields)
 		if err != nil {
 			return nil, err
 		}
 
-		//parse new space
-		newFieldMap, err := mapping.SchemaMap(temp.Fields)
+		for _, name := range dropFields {
+			fm, ok := oldFieldMap[name]
+			if !ok {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("drop field:[%s] not found in space:[%s]", name, space.Name))
+			}
+			if fm.FieldType() == vearchpb.FieldType_VECTOR {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field:[%s] can not be dropped", name))
+			}
+			if space.TTL != nil && space.TTL.Field == name {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("ttl field:[%s] can not be dropped", name))
+			}
+		}
+
+		changed, indexField, err := diffSpaceFields(space.Fields, temp.Fields)
 		if err != nil {
 			return nil, err
 		}
 
-		for k, v := range oldFieldMap {
-			if fm, ok := newFieldMap[k]; ok {
-				if !mapping.Equals(v, fm) {
-					return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("not equals by field:[%s] old[%v] new[%v]", k, v, fm))
-				}
-				delete(newFieldMap, k)
-			}
-		}
+		if changed || len(dropFields) > 0 {
+			log.Info("change schema for space: %s , fields: [%s], drop fields: %v", space.Name, string(temp.Fields), dropFields)
 
-		if len(newFieldMap) > 0 {
-			log.Info("change schema for space: %s , change fields : %d , value is :[%s]", space.Name, len(newFieldMap), string(temp.Fields))
+			schema, err := mapping.UpdateSchema(space.Fields, temp.Fields, dropFields)
+			if err != nil {
+				return nil, err
+			}
 
-			schema, err := mapping.MergeSchema(space.Fields, temp.Fields)
+			spaceProperties, err := entity.UnmarshalPropertyJSON(schema)
 			if err != nil {
 				return nil, err
 			}
 
+			// partitions build the new index in background and swap it in
+			// when finished
+			if indexField != "" {
+				space.Index = spaceProperties[indexField].Index
+			}
+
 			space.Fields = schema
+			space.SpaceProperties = spaceProperties
 		}
+	}
 
+	if err := validateUpdatedSpace(space); err != nil {
+		return nil, err
 	}
 
 	// notify all partitions
@@ -1045,6 +1506,115 @@ func (ms *masterService) updateSpaceService(ctx context.Context, dbName, spaceNa
 	}
 }
 
+// diffSpaceFields checks the fields of update against the schema of space,
+// only the index of a field can change and only one vector field can name
+// the index of space. It returns whether the schema changed and the vector
+// field whose index is the new index of space.
+func diffSpaceFields(schema, fields []byte) (changed bool, indexField string, err error) {
+	if len(fields) == 0 {
+		return false, "", nil
+	}
+	oldFieldMap, err := mapping.SchemaMap(schema)
+	if err != nil {
+		return false, "", err
+	}
+	newFieldMap, err := mapping.SchemaMap(fields)
+	if err != nil {
+		return false, "", err
+	}
+	oldProperties, err := entity.UnmarshalPropertyJSON(schema)
+	if err != nil {
+		return false, "", err
+	}
+	newProperties, err := entity.UnmarshalPropertyJSON(fields)
+	if err != nil {
+		return false, "", err
+	}
+
+	setIndexField := func(name string) error {
+		if indexField != "" && indexField != name {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("index of vector fields:[%s, %s] can not change together", indexField, name))
+		}
+		if newProperties[name].Index == nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field:[%s] should have index", name))
+		}
+		indexField = name
+		return nil
+	}
+
+	for name, fm := range newFieldMap {
+		v, ok := oldFieldMap[name]
+		if !ok {
+			changed = true
+			if fm.FieldType() == vearchpb.FieldType_VECTOR {
+				if err = setIndexField(name); err != nil {
+					return false, "", err
+				}
+			}
+			continue
+		}
+		if v.FieldType() != fm.FieldType() {
+			return false, "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("not equals by field:[%s] old[%v] new[%v]", name, v, fm))
+		}
+		old, pro := oldProperties[name], newProperties[name]
+		// the documents written are checked by them, so they can not change
+		if old.Nullable != pro.Nullable || old.Required != pro.Required || !jsonEqual(defaultOf(old), defaultOf(pro)) {
+			return false, "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("default, nullable and required of field:[%s] can not change", name))
+		}
+		if fm.FieldType() != vearchpb.FieldType_VECTOR {
+			// type, default, nullable and required are checked above, so
+			// only the index of scalar field is left to change, the engines
+			// are rebuilt by the new schema then
+			if !mapping.Equals(v, fm) || !indexEqual(old.Index, pro.Index) {
+				changed = true
+			}
+			continue
+		}
+
+		if old.Dimension != pro.Dimension {
+			return false, "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("dimension of vector field:[%s] can not change, old[%d] new[%d]", name, old.Dimension, pro.Dimension))
+		}
+		if !mapping.Equals(v, fm) || cast.ToString(old.Format) != cast.ToString(pro.Format) || cast.ToString(old.StoreType) != cast.ToString(pro.StoreType) || !jsonEqual(old.StoreParam, pro.StoreParam) {
+			return false, "", vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("only index of vector field:[%s] can change", name))
+		}
+		if indexEqual(old.Index, pro.Index) {
+			continue
+		}
+		if err = setIndexField(name); err != nil {
+			return false, "", err
+		}
+		changed = true
+	}
+	return changed, indexField, nil
+}
+
+func indexEqual(a, b *entity.Index) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Name == b.Name && a.Type == b.Type && jsonEqual(a.Params, b.Params)
+}
+
+// defaultOf returns the default value of field, nil if it has none
+func defaultOf(pro *entity.SpaceProperties) []byte {
+	if string(pro.Default) == "null" {
+		return nil
+	}
+	return pro.Default
+}
+
+// jsonEqual reports whether a and b are the same json ignoring format
+func jsonEqual(a, b []byte) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
 func (ms *masterService) updateSpace(ctx context.Context, space *entity.Space) error {
 	space.Version++
 	space.PartitionNum = len(space.Partitions)
@@ -1117,7 +1687,7 @@ func (ms *masterService) ChangeMember(ctx context.Context, cm *entity.ChangeMemb
 		return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_SERVER_ERROR, fmt.Errorf("server:[%d] addr:[%s] can not connect ", cm.NodeID, masterNode.RpcAddr()))
 	}
 
-	if _, err := ms.updateSpaceService(ctx, dbName, space.Name, space); err != nil {
+	if _, err := ms.updateSpaceService(ctx, dbName, space.Name, space, nil); err != nil {
 		return err
 	}
 	log.Info("cm is [%v] has update space ", cm)
@@ -1217,6 +1787,25 @@ func (ms *masterService) DBServers(ctx context.Context, dbName string) (servers
 	return servers, nil
 }
 
+// sortReplicaServers sorts servers by partitions, low to high. A new replica
+// of partition goes to a zone the partition is not in yet, so the servers of
+// those zones are first if add.
+func sortReplicaServers(servers []*entity.Server, zoneOf map[entity.NodeID]string, partition *entity.Partition, add bool) {
+	sort.Slice(servers, func(i, j int) bool {
+		return len(servers[i].PartitionIds) < len(servers[j].PartitionIds)
+	})
+	if !add {
+		return
+	}
+	zones := make(map[string]bool)
+	for _, nodeID := range partition.Replicas {
+		zones[zoneOf[nodeID]] = true
+	}
+	sort.SliceStable(servers, func(i, j int) bool {
+		return !zones[servers[i].Zone] && zones[servers[j].Zone]
+	})
+}
+
 // change replicas, add or delete
 func (ms *masterService) ChangeReplica(ctx context.Context, dbModify *entity.DBModify) (e error) {
 	// painc process
@@ -1234,13 +1823,14 @@ func (ms *masterService) ChangeReplica(ctx context.Context, dbModify *entity.DBM
 			int(space.ReplicaNum)+1, len(servers)))
 		return err
 	}
+	zoneOf := make(map[entity.NodeID]string)
+	for _, s := range servers {
+		zoneOf[s.ID] = s.Zone
+	}
 	// change space replicas of partition, add or delete one
 	changeServer := make([]*entity.ChangeMember, 0)
 	for _, partition := range space.Partitions {
-		// sort servers，low to high
-		sort.Slice(servers, func(i, j int) bool {
-			return len(servers[i].PartitionIds) < len(servers[j].PartitionIds)
-		})
+		sortReplicaServers(servers, zoneOf, partition, dbModify.Method == proto.ConfAddNode)
 		// change server
 		for _, s := range servers {
 			if dbModify.Method == proto.ConfAddNode {
@@ -0,0 +1,210 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+func TestDiffSpaceFields(t *testing.T) {
+	schema := `[
+		{"name": "age", "type": "integer"},
+		{"name": "city", "type": "string", "default": "bj"},
+		{"name": "code", "type": "string", "required": true},
+		{"name": "vec", "type": "vector", "dimension": 4, "index": {"name": "vec_idx", "type": "FLAT", "params": {"metric_type": "L2"}}},
+		{"name": "vec2", "type": "vector", "dimension": 4, "index": {"name": "vec2_idx", "type": "FLAT", "params": {"metric_type": "L2"}}}
+	]`
+	tests := []struct {
+		name       string
+		fields     string
+		changed    bool
+		indexField string
+		wantErr    bool
+	}{
+		{name: "no fields", fields: ``},
+		{name: "same fields", fields: `[{"name": "vec", "type": "vector", "dimension": 4, "index": {"name": "vec_idx", "type": "FLAT", "params": {"metric_type":"L2"}}}]`},
+		{name: "add scalar field", fields: `[{"name": "name", "type": "string"}]`, changed: true},
+		{name: "change scalar index", fields: `[{"name": "age", "type": "integer", "index": {"name": "age", "type": "SCALAR"}}]`, changed: true},
+		{name: "change scalar type", fields: `[{"name": "age", "type": "long"}]`, wantErr: true},
+		{name: "same scalar field", fields: `[{"name": "age", "type": "integer"}]`},
+		{name: "same default", fields: `[{"name": "city", "type": "string", "default": "bj"}]`},
+		{name: "change default", fields: `[{"name": "city", "type": "string", "default": "sh"}]`, wantErr: true},
+		{name: "drop default", fields: `[{"name": "city", "type": "string"}]`, wantErr: true},
+		{name: "change required", fields: `[{"name": "code", "type": "string"}]`, wantErr: true},
+		{name: "change nullable", fields: `[{"name": "age", "type": "integer", "nullable": true}]`, wantErr: true},
+		{name: "add field with default", fields: `[{"name": "name", "type": "string", "default": "none", "nullable": true}]`, changed: true},
+		{name: "change vector index", fields: `[{"name": "vec2", "type": "vector", "dimension": 4, "index": {"name": "vec2_idx", "type": "HNSW", "params": {"metric_type": "L2", "nlinks": 32}}}]`, changed: true, indexField: "vec2"},
+		{name: "change vector index params", fields: `[{"name": "vec", "type": "vector", "dimension": 4, "index": {"name": "vec_idx", "type": "FLAT", "params": {"metric_type": "InnerProduct"}}}]`, changed: true, indexField: "vec"},
+		{
+			name: "change two vector indexes",
+			fields: `[
+				{"name": "vec", "type": "vector", "dimension": 4, "index": {"name": "vec_idx", "type": "HNSW"}},
+				{"name": "vec2", "type": "vector", "dimension": 4, "index": {"name": "vec2_idx", "type": "HNSW"}}
+			]`,
+			wantErr: true,
+		},
+		{name: "vector without index", fields: `[{"name": "vec", "type": "vector", "dimension": 4}]`, wantErr: true},
+		{name: "change vector dimension", fields: `[{"name": "vec", "type": "vector", "dimension": 8, "index": {"name": "vec_idx", "type": "FLAT", "params": {"metric_type": "L2"}}}]`, wantErr: true},
+		{name: "change vector format", fields: `[{"name": "vec", "type": "vector", "dimension": 4, "format": "normalization", "index": {"name": "vec_idx", "type": "FLAT", "params": {"metric_type": "L2"}}}]`, wantErr: true},
+		{name: "add vector field", fields: `[{"name": "vec3", "type": "vector", "dimension": 4, "index": {"name": "vec3_idx", "type": "FLAT"}}]`, changed: true, indexField: "vec3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, indexField, err := diffSpaceFields([]byte(schema), []byte(tt.fields))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.changed, changed)
+			assert.Equal(t, tt.indexField, indexField)
+		})
+	}
+}
+
+// testServers makes a server of each "zone/rack", its id is the index plus
+// one and it has as many partitions as its index
+func testServers(locality ...[2]string) ([]*entity.Server, map[int]int) {
+	servers := make([]*entity.Server, 0, len(locality))
+	serverPartitions := make(map[int]int)
+	for i, l := range locality {
+		servers = append(servers, &entity.Server{ID: entity.NodeID(i + 1), Ip: fmt.Sprintf("ps%d", i+1), Zone: l[0], Rack: l[1]})
+		serverPartitions[i] = i
+	}
+	return servers, serverPartitions
+}
+
+func TestGeneratePartitionsInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		locality [][2]string
+		dead     map[string]bool
+		replicas uint8
+		want     []entity.NodeID
+		wantErr  bool
+	}{
+		{
+			name:     "zones fewer than replicas",
+			locality: [][2]string{{"z1", "r1"}, {"z1", "r1"}, {"z1", "r2"}, {"z2", "r1"}},
+			replicas: 3,
+			want:     []entity.NodeID{1, 4, 3},
+		},
+		{
+			name:     "all zones empty",
+			locality: [][2]string{{"", ""}, {"", ""}, {"", ""}, {"", ""}},
+			replicas: 3,
+			want:     []entity.NodeID{1, 2, 3},
+		},
+		{
+			name:     "dead server",
+			locality: [][2]string{{"z1", "r1"}, {"z1", "r2"}, {"z2", "r1"}},
+			dead:     map[string]bool{"ps1:0": true},
+			replicas: 2,
+			want:     []entity.NodeID{2, 3},
+		},
+		{
+			name:     "not enough live servers",
+			locality: [][2]string{{"z1", "r1"}, {"z2", "r1"}},
+			dead:     map[string]bool{"ps2:0": true},
+			replicas: 2,
+			wantErr:  true,
+		},
+	}
+	defer func(f func(string) bool) { isLive = f }(isLive)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isLive = func(addr string) bool { return !tt.dead[addr] }
+			servers, serverPartitions := testServers(tt.locality...)
+			partition := &entity.Partition{Id: 1}
+			addrs, err := (&masterService{}).generatePartitionsInfo(servers, serverPartitions, tt.replicas, partition)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, partition.Replicas)
+			assert.Len(t, addrs, int(tt.replicas))
+			for _, id := range tt.want {
+				assert.Equal(t, int(id), serverPartitions[int(id)-1], "partitions of server %d", id)
+			}
+		})
+	}
+}
+
+func TestSortReplicaServers(t *testing.T) {
+	tests := []struct {
+		name     string
+		locality [][2]string
+		replicas []entity.NodeID
+		add      bool
+		want     []entity.NodeID
+	}{
+		{name: "add to other zone", locality: [][2]string{{"z1", ""}, {"z1", ""}, {"z2", ""}}, replicas: []entity.NodeID{1}, add: true, want: []entity.NodeID{3, 1, 2}},
+		{name: "remove by partitions", locality: [][2]string{{"z1", ""}, {"z1", ""}, {"z2", ""}}, replicas: []entity.NodeID{1}, want: []entity.NodeID{1, 2, 3}},
+		{name: "all zones empty", locality: [][2]string{{"", ""}, {"", ""}, {"", ""}}, replicas: []entity.NodeID{1}, add: true, want: []entity.NodeID{1, 2, 3}},
+		{name: "partition in all zones", locality: [][2]string{{"z1", ""}, {"z2", ""}, {"z2", ""}}, replicas: []entity.NodeID{1, 2}, add: true, want: []entity.NodeID{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers, _ := testServers(tt.locality...)
+			zoneOf := make(map[entity.NodeID]string)
+			for i, s := range servers {
+				zoneOf[s.ID] = s.Zone
+				s.PartitionIds = make([]entity.PartitionID, i)
+			}
+			sortReplicaServers(servers, zoneOf, &entity.Partition{Id: 1, Replicas: tt.replicas}, tt.add)
+			ids := make([]entity.NodeID, 0, len(servers))
+			for _, s := range servers {
+				ids = append(ids, s.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestValidateUpdatedSpace(t *testing.T) {
+	fields := `[
+		{"name": "ts", "type": "date", "index": {"name": "ts", "type": "SCALAR"}},
+		{"name": "created", "type": "date"},
+		{"name": "age", "type": "integer", "index": {"name": "age", "type": "SCALAR"}}
+	]`
+	tests := []struct {
+		name    string
+		ttl     *entity.SpaceTTL
+		wantErr bool
+	}{
+		{name: "no ttl"},
+		{name: "indexed date field", ttl: &entity.SpaceTTL{Field: "ts", Default: 3600}},
+		{name: "date field without index", ttl: &entity.SpaceTTL{Field: "created"}, wantErr: true},
+		{name: "not date field", ttl: &entity.SpaceTTL{Field: "age"}, wantErr: true},
+		{name: "missing field", ttl: &entity.SpaceTTL{Field: "expire"}, wantErr: true},
+		{name: "negative default", ttl: &entity.SpaceTTL{Field: "ts", Default: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			space := &entity.Space{Fields: []byte(fields), TTL: tt.ttl}
+			err := validateUpdatedSpace(space)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -17,6 +17,7 @@ package master
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/entity/errors"
 	"github.com/vearch/vearch/v3/internal/monitor"
 	"github.com/vearch/vearch/v3/internal/pkg/httphelper"
@@ -35,17 +36,16 @@ func ExportToMonitorHandler(router *gin.Engine, monitorService *monitorService)
 	c := &monitorApi{router: router, monitorService: monitorService, dh: dh}
 
 	var group *gin.RouterGroup
+	users := monitorService.Master()
 	if !config.Conf().Global.SkipAuth {
-		group = router.Group("", dh.PaincHandler, dh.TimeOutHandler, gin.BasicAuth(gin.Accounts{
-			"root": config.Conf().Global.Signkey,
-		}))
+		group = router.Group("", dh.PaincHandler, dh.TimeOutHandler, vearchhttp.Auth(users))
 	} else {
 		group = router.Group("", dh.PaincHandler, dh.TimeOutHandler)
 	}
 
 	// cluster handler
-	group.GET("/cluster/health", c.health, dh.TimeOutEndHandler)
-	group.GET("/cluster/stats", c.stats, dh.TimeOutEndHandler)
+	group.GET("/cluster/health", vearchhttp.Require(users, entity.PrivilegeSelect), c.health, dh.TimeOutEndHandler)
+	group.GET("/cluster/stats", vearchhttp.Require(users, entity.PrivilegeSelect), c.stats, dh.TimeOutEndHandler)
 
 	monitor.Register(monitorService.Client, monitorService.etcdServer, config.Conf().Masters.Self().MonitorPort)
 	// monitorService.Register()
@@ -0,0 +1,49 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package expr
+
+import "math"
+
+const defaultDecay = 0.5
+
+// decayArgs returns the distance out of offset and the decay args
+func decayArgs(a []float64) (dist, scale, decay float64) {
+	value, origin, scale := a[0], a[1], a[2]
+	offset, decay := 0.0, defaultDecay
+	if len(a) > 3 {
+		offset = a[3]
+	}
+	if len(a) > 4 {
+		decay = a[4]
+	}
+	dist = math.Max(0, math.Abs(value-origin)-offset)
+	return dist, scale, decay
+}
+
+func decayGauss(a []float64) float64 {
+	dist, scale, decay := decayArgs(a)
+	return math.Exp(math.Log(decay) * dist * dist / (scale * scale))
+}
+
+func decayExp(a []float64) float64 {
+	dist, scale, decay := decayArgs(a)
+	return math.Exp(math.Log(decay) * dist / scale)
+}
+
+func decayLinear(a []float64) float64 {
+	dist, scale, decay := decayArgs(a)
+	s := scale / (1 - decay)
+	return math.Max(0, (s-dist)/s)
+}
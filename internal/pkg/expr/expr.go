@@ -0,0 +1,417 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package expr evaluates small arithmetic expressions over named numeric
+// values, such as `_score * log1p(popularity)`.
+//
+// The grammar supports numbers, variables, + - * / % ^, parentheses and
+// the functions registered in funcs. The decay functions take
+// (value, origin, scale[, offset[, decay]]) and score 1 within offset of
+// origin and decay at scale + offset, offset is 0 and decay is 0.5 by default.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"unicode"
+)
+
+// Vars returns the value of variable name, ok is false if it is not set
+type Vars func(name string) (value float64, ok bool)
+
+type node interface {
+	eval(vars Vars) (float64, error)
+}
+
+type Expression struct {
+	source string
+	root   node
+	vars   []string
+}
+
+type function struct {
+	minArgs, maxArgs int
+	call             func(args []float64) float64
+}
+
+var funcs = map[string]function{
+	"abs":          {1, 1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":         {1, 1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"log":          {1, 1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10":        {1, 1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"log1p":        {1, 1, func(a []float64) float64 { return math.Log1p(a[0]) }},
+	"exp":          {1, 1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"floor":        {1, 1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":         {1, 1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"pow":          {2, 2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"decay_gauss":  {3, 5, decayGauss},
+	"decay_exp":    {3, 5, decayExp},
+	"decay_linear": {3, 5, decayLinear},
+	"min": {1, -1, func(a []float64) float64 {
+		v := a[0]
+		for _, x := range a[1:] {
+			v = math.Min(v, x)
+		}
+		return v
+	}},
+	"max": {1, -1, func(a []float64) float64 {
+		v := a[0]
+		for _, x := range a[1:] {
+			v = math.Max(v, x)
+		}
+		return v
+	}},
+}
+
+// Parse compiles s, the variables are resolved when it is evaluated
+func Parse(s string) (*Expression, error) {
+	p := &parser{src: s}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("expression [%s] unexpected [%s] at %d", s, p.tok.text, p.tok.pos)
+	}
+	return &Expression{source: s, root: root, vars: p.vars}, nil
+}
+
+// Vars returns the distinct variable names used by the expression
+func (e *Expression) Vars() []string {
+	return e.vars
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression, it fails if a variable is not set or
+// the result is not a finite number
+func (e *Expression) Eval(vars Vars) (float64, error) {
+	v, err := e.root.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("expression [%s] result is not a finite number", e.source)
+	}
+	return v, nil
+}
+
+type numberNode float64
+
+func (n numberNode) eval(Vars) (float64, error) {
+	return float64(n), nil
+}
+
+type varNode string
+
+func (n varNode) eval(vars Vars) (float64, error) {
+	if vars != nil {
+		if v, ok := vars(string(n)); ok {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("variable [%s] has no value", string(n))
+}
+
+type unaryNode struct {
+	x node
+}
+
+func (n *unaryNode) eval(vars Vars) (float64, error) {
+	x, err := n.x.eval(vars)
+	return -x, err
+}
+
+type binaryNode struct {
+	op   byte
+	l, r node
+}
+
+func (n *binaryNode) eval(vars Vars) (float64, error) {
+	l, err := n.l.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.r.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	case '/':
+		return l / r, nil
+	case '%':
+		return math.Mod(l, r), nil
+	default:
+		return math.Pow(l, r), nil
+	}
+}
+
+type callNode struct {
+	fn   function
+	args []node
+}
+
+func (n *callNode) eval(vars Vars) (float64, error) {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(vars)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	return n.fn.call(args), nil
+}
+
+const (
+	tokEOF = iota
+	tokNumber
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+type parser struct {
+	src  string
+	pos  int
+	tok  token
+	vars []string
+}
+
+func (p *parser) next() error {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return nil
+	}
+	c := p.src[p.pos]
+	switch {
+	case isDigit(c) || c == '.':
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			p.pos++
+			if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+				p.pos++
+			}
+			for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+				p.pos++
+			}
+		}
+		p.tok = token{kind: tokNumber, text: p.src[start:p.pos], pos: start}
+	case isIdentStart(c):
+		for p.pos < len(p.src) && (isIdentStart(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+	case c == '+' || c == '-' || c == '*' || c == '/' || c == '%' || c == '^' || c == '(' || c == ')' || c == ',':
+		p.pos++
+		p.tok = token{kind: tokOp, text: p.src[start:p.pos], pos: start}
+	default:
+		return fmt.Errorf("expression [%s] invalid character [%c] at %d", p.src, c, start)
+	}
+	return nil
+}
+
+func (p *parser) isOp(ops string) bool {
+	if p.tok.kind != tokOp {
+		return false
+	}
+	for i := 0; i < len(ops); i++ {
+		if p.tok.text[0] == ops[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExpr: term (('+' | '-') term)*
+func (p *parser) parseExpr() (node, error) {
+	l, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+-") {
+		op := p.tok.text[0]
+		if err = p.next(); err != nil {
+			return nil, err
+		}
+		r, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+// parseTerm: unary (('*' | '/' | '%') unary)*
+func (p *parser) parseTerm() (node, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*/%") {
+		op := p.tok.text[0]
+		if err = p.next(); err != nil {
+			return nil, err
+		}
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+// parseUnary: '-' unary | power, power is right associative and binds
+// tighter than the unary minus, so -2^2 is -4
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("+-") {
+		op := p.tok.text[0]
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == '-' {
+			return &unaryNode{x: x}, nil
+		}
+		return x, nil
+	}
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("^") {
+		if err = p.next(); err != nil {
+			return nil, err
+		}
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{op: '^', l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+// parsePrimary: number | ident | ident '(' args ')' | '(' expr ')'
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokNumber:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expression [%s] invalid number [%s] at %d", p.src, tok.text, tok.pos)
+		}
+		return numberNode(v), p.next()
+	case tok.kind == tokIdent:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.isOp("(") {
+			return p.parseCall(tok)
+		}
+		p.addVar(tok.text)
+		return varNode(tok.text), nil
+	case p.isOp("("):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, fmt.Errorf("expression [%s] missing ) at %d", p.src, p.tok.pos)
+		}
+		return x, p.next()
+	case tok.kind == tokEOF:
+		return nil, fmt.Errorf("expression [%s] unexpected end", p.src)
+	default:
+		return nil, fmt.Errorf("expression [%s] unexpected [%s] at %d", p.src, tok.text, tok.pos)
+	}
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := funcs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("expression [%s] unknown function [%s] at %d", p.src, name.text, name.pos)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	args := make([]node, 0, fn.minArgs)
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if !p.isOp(",") {
+				return nil, fmt.Errorf("expression [%s] expect , or ) at %d", p.src, p.tok.pos)
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("expression [%s] function [%s] got %d arguments", p.src, name.text, len(args))
+	}
+	return &callNode{fn: fn, args: args}, p.next()
+}
+
+func (p *parser) addVar(name string) {
+	for _, v := range p.vars {
+		if v == name {
+			return
+		}
+	}
+	p.vars = append(p.vars, name)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
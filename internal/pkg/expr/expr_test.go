@@ -0,0 +1,79 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package expr
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	values := map[string]float64{"_score": 0.5, "popularity": math.E - 1, "a": 2}
+	vars := func(name string) (float64, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+
+	cases := map[string]float64{
+		"1 + 2 * 3":                       7,
+		"(1 + 2) * 3":                     9,
+		"-2 ^ 2":                          -4,
+		"2 ^ 3 ^ 2":                       512,
+		"10 % 4 - 1e1 / 5":                0,
+		"_score * log(1+popularity)":      0.5,
+		"max(a, 3, -1) + min(a, 1)":       4,
+		"pow(a, 3) + abs(-a)":             10,
+		"decay_exp(10, 0, 10)":            0.5,
+		"decay_exp(-3, 0, 10, 5)":         1,
+		"decay_gauss(15, 0, 10, 5)":       0.5,
+		"decay_linear(5, 0, 10)":          0.75,
+		"decay_linear(30, 0, 10, 0, 0.5)": 0,
+	}
+	for s, want := range cases {
+		e, err := Parse(s)
+		if err != nil {
+			t.Fatalf("parse %s: %v", s, err)
+		}
+		got, err := e.Eval(vars)
+		if err != nil {
+			t.Fatalf("eval %s: %v", s, err)
+		}
+		if math.Abs(got-want) > 1e-9 {
+			t.Fatalf("eval %s = %v, want %v", s, got, want)
+		}
+	}
+
+	e, _ := Parse("_score * log1p(popularity) + _score")
+	if vs := e.Vars(); len(vs) != 2 || vs[0] != "_score" || vs[1] != "popularity" {
+		t.Fatalf("vars = %v", vs)
+	}
+	if _, err := e.Eval(func(string) (float64, bool) { return 0, false }); err == nil {
+		t.Fatal("expect missing variable error")
+	}
+	if _, err := Parse("1 / 0"); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := Parse("1 / 0"); e != nil {
+		if _, err := e.Eval(nil); err == nil {
+			t.Fatal("expect not finite error")
+		}
+	}
+
+	for _, s := range []string{"", "1 +", "(1", "foo(1)", "max()", "pow(1)", "1 $ 2", "a b"} {
+		if _, err := Parse(s); err == nil {
+			t.Fatalf("parse %s expect error", s)
+		}
+	}
+}
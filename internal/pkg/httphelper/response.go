@@ -98,3 +98,14 @@ func (r *Response) JsonError(err *errors.ErrRequest) {
 	r.SetHttpStatus(int64(err.HttpCode()))
 	r.SendJson(httpReply)
 }
+
+// JsonErrorWithData replies err with data, the details of what failed
+func (r *Response) JsonErrorWithData(err *errors.ErrRequest, data interface{}) {
+	httpReply := &HttpReply{
+		Code: err.Code(),
+		Msg:  err.Msg(),
+		Data: data,
+	}
+	r.SetHttpStatus(int64(err.HttpCode()))
+	r.SendJson(httpReply)
+}
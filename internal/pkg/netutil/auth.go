@@ -16,36 +16,38 @@ package netutil
 
 import (
 	"encoding/base64"
+	"fmt"
 	"strings"
 
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 )
 
 const (
-	HeaderAuthBasic = "Basic "
+	HeaderAuthBasic  = "Basic "
+	HeaderAuthBearer = "Bearer "
 )
 
 func AuthDecrypt(headerData string) (userName, password string, err error) {
 	var dataByte []byte
 	basicToken := strings.TrimPrefix(headerData, HeaderAuthBasic)
-	dataByte, err = base64.URLEncoding.DecodeString(basicToken)
+	dataByte, err = base64.StdEncoding.DecodeString(basicToken)
 	if err != nil {
 		log.Error("can not decode auth original data. err:%v", err)
 		return "", "", err
 	}
 	dataStr := string(dataByte)
-	dataSegments := strings.Split(dataStr, ":")
-	if dataSegments == nil || len(dataSegments) != 2 {
+	userName, password, ok := strings.Cut(dataStr, ":")
+	if !ok {
 		log.Error("split auth data string error")
-		return "", "", err
+		return "", "", fmt.Errorf("auth data has no ':'")
 	}
 
-	return dataSegments[0], dataSegments[1], nil
+	return userName, password, nil
 }
 
 func AuthEncrypt(userName, password string) string {
 	dataStr := strings.Join([]string{userName, password}, ":")
-	basicToken := base64.URLEncoding.EncodeToString([]byte(dataStr))
+	basicToken := base64.StdEncoding.EncodeToString([]byte(dataStr))
 	headerData := strings.Join([]string{HeaderAuthBasic, basicToken}, "")
 	return headerData
 }
@@ -0,0 +1,136 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package vearchhttp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/pkg/httphelper"
+	"github.com/vearch/vearch/v3/internal/pkg/netutil"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	UserKey   = "__user"
+	ApiKeyKey = "__api_key"
+)
+
+// UserSource authenticates users and resolves their privileges
+type UserSource interface {
+	Authenticate(ctx context.Context, name, password string) (*entity.User, error)
+	AuthenticateToken(ctx context.Context, token string) (*entity.User, *entity.ApiKey, error)
+	PriviOn(ctx context.Context, user *entity.User, dbName, spaceName string) entity.UserPrivi
+}
+
+// Auth authenticates the basic auth user or the bearer token of request by
+// users
+func Auth(users UserSource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, netutil.HeaderAuthBearer) {
+			user, key, err := users.AuthenticateToken(c.Request.Context(), strings.TrimPrefix(auth, netutil.HeaderAuthBearer))
+			if err != nil {
+				c.Header("WWW-Authenticate", `Bearer realm="Authorization Required"`)
+				httphelper.New(c).JsonError(errors.NewErrUnauthorized(err))
+				c.Abort()
+				return
+			}
+			c.Set(gin.AuthUserKey, user.Name)
+			c.Set(UserKey, user)
+			c.Set(ApiKeyKey, key)
+			return
+		}
+		name, password, ok := c.Request.BasicAuth()
+		if !ok {
+			c.Header("WWW-Authenticate", `Basic realm="Authorization Required"`)
+			httphelper.New(c).JsonError(errors.NewErrUnauthorized(vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, fmt.Errorf("missing basic auth"))))
+			c.Abort()
+			return
+		}
+		user, err := users.Authenticate(c.Request.Context(), name, password)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Basic realm="Authorization Required"`)
+			httphelper.New(c).JsonError(errors.NewErrUnauthorized(err))
+			c.Abort()
+			return
+		}
+		c.Set(gin.AuthUserKey, user.Name)
+		c.Set(UserKey, user)
+	}
+}
+
+// Allow checks the user of request has privi on the space of db, an empty
+// spaceName is the db itself and an empty dbName is the cluster. A request
+// of api key is also limited to the scopes of the key. It replies 403 with
+// the lacking privileges and returns false if not. Requests are allowed when
+// auth is skipped.
+func Allow(c *gin.Context, users UserSource, dbName, spaceName string, privi entity.UserPrivi) bool {
+	user, lack := lackOf(c, users, dbName, spaceName, privi)
+	if user == nil || lack == entity.PrivilegeNone {
+		return true
+	}
+	resource := entity.RoleScopeCluster
+	if dbName != "" {
+		resource = dbName
+		if spaceName != "" {
+			resource += "/" + spaceName
+		}
+	}
+	err := vearchpb.NewError(vearchpb.ErrorEnum_AUTHENTICATION_FAILED, fmt.Errorf("user %s lacks privileges %s on %s", user.Name, lack.String(), resource))
+	httphelper.New(c).JsonError(errors.NewErrForbidden(err))
+	c.Abort()
+	return false
+}
+
+// Has reports whether the user of request has privi on the space of db
+// like Allow, but it replies nothing
+func Has(c *gin.Context, users UserSource, dbName, spaceName string, privi entity.UserPrivi) bool {
+	user, lack := lackOf(c, users, dbName, spaceName, privi)
+	return user == nil || lack == entity.PrivilegeNone
+}
+
+// lackOf returns the user of request and the privileges of privi it lacks,
+// the user is nil when auth is skipped
+func lackOf(c *gin.Context, users UserSource, dbName, spaceName string, privi entity.UserPrivi) (*entity.User, entity.UserPrivi) {
+	get, exists := c.Get(UserKey)
+	if !exists {
+		return nil, entity.PrivilegeNone
+	}
+	user := get.(*entity.User)
+	has := users.PriviOn(c.Request.Context(), user, dbName, spaceName)
+	if key, ok := c.Get(ApiKeyKey); ok {
+		has &= key.(*entity.ApiKey).PriviOn(dbName, spaceName)
+	}
+	return user, entity.LackPrivi(has, privi)
+}
+
+// Require checks privi on the db and space named by the path params, no
+// params checks the cluster and one checks the db
+func Require(users UserSource, privi entity.UserPrivi, params ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var dbName, spaceName string
+		if len(params) > 0 {
+			dbName = c.Param(params[0])
+		}
+		if len(params) > 1 {
+			spaceName = c.Param(params[1])
+		}
+		Allow(c, users, dbName, spaceName, privi)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"go.uber.org/atomic"
+)
+
+const (
+	admissionSearch = "search"
+	admissionQuery  = "query"
+	admissionWrite  = "write"
+	admissionAdmin  = "admin"
+
+	defaultAdmissionWaitMs = 100
+)
+
+// gate bounds the running and waiting requests of a class
+type gate struct {
+	class   string
+	slots   chan struct{}
+	waiting *atomic.Int64
+	queue   int64
+}
+
+func (g *gate) release() {
+	<-g.slots
+}
+
+// admission sheds the load of ps before the requests pile up, a request
+// of a saturated class is rejected so router can try another replica
+type admission struct {
+	gates map[string]*gate
+	wait  time.Duration
+}
+
+func newAdmission(cfg *config.PSCfg) *admission {
+	a := &admission{gates: make(map[string]*gate), wait: defaultAdmissionWaitMs * time.Millisecond}
+	if cfg.AdmissionWaitMs > 0 {
+		a.wait = time.Duration(cfg.AdmissionWaitMs) * time.Millisecond
+	}
+	for class, concurrency := range map[string]int{
+		admissionSearch: cfg.SearchConcurrency,
+		admissionQuery:  cfg.QueryConcurrency,
+		admissionWrite:  cfg.WriteConcurrency,
+		admissionAdmin:  cfg.AdminConcurrency,
+	} {
+		if concurrency <= 0 {
+			continue
+		}
+		queue := cfg.AdmissionQueueSize
+		if queue <= 0 {
+			queue = concurrency
+		}
+		a.gates[class] = &gate{class: class, slots: make(chan struct{}, concurrency), waiting: atomic.NewInt64(0), queue: int64(queue)}
+	}
+	return a
+}
+
+func admissionClass(method string) string {
+	switch method {
+	case client.SearchHandler:
+		return admissionSearch
+	case client.QueryHandler, client.GetDocsHandler, client.GetDocsByPartitionHandler, client.GetNextDocsByPartitionHandler:
+		return admissionQuery
+	case client.BatchHandler, client.DeleteDocsHandler, client.DeleteByQueryHandler:
+		return admissionWrite
+	case client.ForceMergeHandler, client.RebuildIndexHandler, client.FlushHandler:
+		return admissionAdmin
+	}
+	return ""
+}
+
+// admit takes a slot of the class of method, it waits in the queue for at
+// most the admission wait. The returned func gives the slot back.
+func (a *admission) admit(ctx context.Context, method string) (func(), error) {
+	g := a.gates[admissionClass(method)]
+	if g == nil {
+		return func() {}, nil
+	}
+	select {
+	case g.slots <- struct{}{}:
+		return g.release, nil
+	default:
+	}
+
+	if g.waiting.Inc() > g.queue {
+		g.waiting.Dec()
+		return nil, fmt.Errorf("ps overloaded, %d %s requests are running and the queue of %d is full", cap(g.slots), g.class, g.queue)
+	}
+	defer g.waiting.Dec()
+
+	timer := time.NewTimer(a.wait)
+	defer timer.Stop()
+	select {
+	case g.slots <- struct{}{}:
+		return g.release, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("ps overloaded, %s request waits for a slot more than %v", g.class, a.wait)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("ps overloaded, %s request waits for a slot: %s", g.class, ctx.Err().Error())
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+)
+
+func TestNewAdmission(t *testing.T) {
+	a := newAdmission(&config.PSCfg{SearchConcurrency: 2, WriteConcurrency: 1, AdmissionQueueSize: 3})
+	assert.Equal(t, defaultAdmissionWaitMs*time.Millisecond, a.wait)
+	assert.Len(t, a.gates, 2)
+	assert.Equal(t, 2, cap(a.gates[admissionSearch].slots))
+	assert.Equal(t, int64(3), a.gates[admissionSearch].queue)
+	assert.Nil(t, a.gates[admissionQuery])
+
+	a = newAdmission(&config.PSCfg{QueryConcurrency: 4, AdmissionWaitMs: 20})
+	assert.Equal(t, 20*time.Millisecond, a.wait)
+	assert.Equal(t, int64(4), a.gates[admissionQuery].queue, "queue defaults to concurrency")
+
+	assert.Equal(t, admissionSearch, admissionClass(client.SearchHandler))
+	assert.Equal(t, admissionQuery, admissionClass(client.GetDocsHandler))
+	assert.Equal(t, admissionWrite, admissionClass(client.BatchHandler))
+	assert.Equal(t, admissionAdmin, admissionClass(client.FlushHandler))
+	assert.Equal(t, "", admissionClass("unknown"))
+}
+
+func TestAdmitUngated(t *testing.T) {
+	a := newAdmission(&config.PSCfg{SearchConcurrency: 1})
+	for i := 0; i < 3; i++ {
+		release, err := a.admit(context.Background(), client.BatchHandler)
+		assert.NoError(t, err)
+		release()
+	}
+}
+
+func TestAdmitQueueFull(t *testing.T) {
+	a := newAdmission(&config.PSCfg{SearchConcurrency: 1, AdmissionQueueSize: 1, AdmissionWaitMs: 10000})
+	g := a.gates[admissionSearch]
+
+	release, err := a.admit(context.Background(), client.SearchHandler)
+	assert.NoError(t, err)
+
+	queued := make(chan func())
+	go func() {
+		release, err := a.admit(context.Background(), client.SearchHandler)
+		assert.NoError(t, err)
+		queued <- release
+	}()
+	for g.waiting.Load() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err = a.admit(context.Background(), client.SearchHandler)
+	assert.ErrorContains(t, err, "queue of 1 is full")
+	assert.Equal(t, int64(1), g.waiting.Load(), "a rejected request does not stay in the queue")
+
+	release()
+	select {
+	case release = <-queued:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued request is not admitted after a slot is released")
+	}
+	assert.Equal(t, int64(0), g.waiting.Load())
+	release()
+	assert.Len(t, g.slots, 0)
+}
+
+func TestAdmitWaitTimeout(t *testing.T) {
+	a := newAdmission(&config.PSCfg{WriteConcurrency: 1, AdmissionWaitMs: 10})
+	release, err := a.admit(context.Background(), client.BatchHandler)
+	assert.NoError(t, err)
+	defer release()
+
+	start := time.Now()
+	_, err = a.admit(context.Background(), client.DeleteDocsHandler)
+	assert.ErrorContains(t, err, "waits for a slot more than 10ms")
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	assert.Equal(t, int64(0), a.gates[admissionWrite].waiting.Load())
+}
+
+func TestAdmitContextCancel(t *testing.T) {
+	a := newAdmission(&config.PSCfg{QueryConcurrency: 1, AdmissionWaitMs: 10000})
+	release, err := a.admit(context.Background(), client.QueryHandler)
+	assert.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for a.gates[admissionQuery].waiting.Load() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	_, err = a.admit(ctx, client.GetDocsHandler)
+	assert.ErrorContains(t, err, context.Canceled.Error())
+	assert.Equal(t, int64(0), a.gates[admissionQuery].waiting.Load())
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smallnest/rpcx/share"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// stages the work of an aborted request is skipped at
+const (
+	skipStageQueued = "queued"
+	skipStageEngine = "engine"
+)
+
+var (
+	cancelMetricsOnce sync.Once
+	cancelledRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vearch_ps_cancelled_requests_total",
+		Help: "ps requests aborted because the caller cancelled them or their deadline passed",
+	}, []string{"method", "reason"})
+	skippedWork = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vearch_ps_skipped_work_total",
+		Help: "work of aborted ps requests skipped before it ran",
+	}, []string{"method", "stage"})
+)
+
+func registerCancelMetrics() {
+	cancelMetricsOnce.Do(func() {
+		for _, c := range []prometheus.Collector{cancelledRequests, skippedWork} {
+			if err := prometheus.Register(c); err != nil {
+				log.Warnf("register ps cancel metrics err: %s", err.Error())
+			}
+		}
+	})
+}
+
+func methodOf(ctx context.Context) string {
+	if md, ok := ctx.Value(share.ReqMetaDataKey).(map[string]string); ok {
+		return md[client.HandlerType]
+	}
+	return ""
+}
+
+// cancelled counts a request of ctx aborted
+func cancelled(ctx context.Context) {
+	registerCancelMetrics()
+	reason := "cancelled"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = "deadline"
+	}
+	cancelledRequests.WithLabelValues(methodOf(ctx), reason).Inc()
+}
+
+// skipped counts the work of an aborted request skipped at stage
+func skipped(ctx context.Context, stage string) {
+	registerCancelMetrics()
+	skippedWork.WithLabelValues(methodOf(ctx), stage).Inc()
+}
+
+// cancelledError is the error replied for a request of ctx aborted
+func cancelledError(ctx context.Context, timeout int) *vearchpb.Error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, fmt.Errorf("This request processing timed out[%dms]", timeout)).GetError()
+	}
+	return vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, errors.New("This request is cancelled by the caller")).GetError()
+}
+
+func inflightKey(req *vearchpb.PartitionData) string {
+	return fmt.Sprintf("%s_%d", req.MessageID, req.PartitionID)
+}
+
+// track keeps the cancel func of a running search or query, router cancels
+// it when the caller is gone or another replica answered first
+func (s *Server) track(req *vearchpb.PartitionData, cancel context.CancelFunc) func() {
+	key := inflightKey(req)
+	s.inflight.Store(key, cancel)
+	return func() {
+		s.inflight.Delete(key)
+	}
+}
+
+// cancelRequest cancels the running request of the message and partition
+// of req, it is a no-op if the request has ended
+func (s *Server) cancelRequest(req *vearchpb.PartitionData) {
+	if cancel, ok := s.inflight.LoadAndDelete(inflightKey(req)); ok {
+		log.Debug("partition:[%d] cancel message:[%s]", req.PartitionID, req.MessageID)
+		cancel.(context.CancelFunc)()
+	}
+}
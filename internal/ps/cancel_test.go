@@ -0,0 +1,148 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/smallnest/rpcx/share"
+	"github.com/stretchr/testify/assert"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func methodContext(method string, params map[string]string) context.Context {
+	md := map[string]string{client.HandlerType: method}
+	for k, v := range params {
+		md[k] = v
+	}
+	return context.WithValue(context.Background(), share.ReqMetaDataKey, md)
+}
+
+// testBusyServer has its only slot taken, so a request waits until it is
+// aborted
+func testBusyServer() *Server {
+	s := &Server{rpcTimeOut: 10, admission: newAdmission(&config.PSCfg{}), concurrent: make(chan bool, 1)}
+	s.concurrent <- true
+	return s
+}
+
+func TestTrackCancel(t *testing.T) {
+	s := &Server{}
+	req := &vearchpb.PartitionData{MessageID: "track", PartitionID: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	untrack := s.track(req, cancel)
+
+	s.cancelRequest(&vearchpb.PartitionData{MessageID: "track", PartitionID: 2})
+	assert.NoError(t, ctx.Err(), "a request of other partition is not cancelled")
+
+	s.cancelRequest(&vearchpb.PartitionData{MessageID: "track", PartitionID: 1})
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	_, ok := s.inflight.Load(inflightKey(req))
+	assert.False(t, ok)
+	untrack()
+
+	// a request ended is not cancelled
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	s.track(req, cancel)()
+	s.cancelRequest(req)
+	assert.NoError(t, ctx.Err())
+}
+
+func TestExecuteSkipQueued(t *testing.T) {
+	handler := &UnaryHandler{server: &Server{}}
+	concurrent := make(chan bool, 1)
+	skippedBefore := testutil.ToFloat64(skippedWork.WithLabelValues(client.QueryHandler, skipStageQueued))
+
+	// the partition is not looked up, the server has none
+	ctx, cancel := context.WithCancel(methodContext(client.QueryHandler, nil))
+	cancel()
+	req := &vearchpb.PartitionData{PartitionID: 1}
+	assert.True(t, handler.execute(ctx, req, concurrent))
+	assert.Nil(t, req.Err)
+	assert.Len(t, concurrent, 0, "the slot is given back")
+
+	// aborted while it waits for a slot
+	concurrent <- true
+	ctx, cancel = context.WithTimeout(methodContext(client.QueryHandler, nil), 5*time.Millisecond)
+	defer cancel()
+	assert.True(t, handler.execute(ctx, req, concurrent))
+	assert.Equal(t, skippedBefore+2, testutil.ToFloat64(skippedWork.WithLabelValues(client.QueryHandler, skipStageQueued)))
+
+	// a request not aborted runs
+	<-concurrent
+	assert.False(t, handler.execute(methodContext(client.QueryHandler, nil), req, concurrent))
+	assert.Equal(t, vearchpb.ErrorEnum_PARTITION_NOT_EXIST, req.Err.Code)
+}
+
+func TestExecuteCancelled(t *testing.T) {
+	s := testBusyServer()
+	handler := &UnaryHandler{server: s}
+	cancelledBefore := testutil.ToFloat64(cancelledRequests.WithLabelValues(client.SearchHandler, "cancelled"))
+	skippedBefore := testutil.ToFloat64(skippedWork.WithLabelValues(client.SearchHandler, skipStageQueued))
+
+	req := &vearchpb.PartitionData{MessageID: "cancelled", PartitionID: 1, SearchRequest: &vearchpb.SearchRequest{}}
+	reply := &vearchpb.PartitionData{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, handler.Execute(methodContext(client.SearchHandler, nil), req, reply))
+	}()
+	assert.Eventually(t, func() bool {
+		_, ok := s.inflight.Load(inflightKey(req))
+		return ok
+	}, 5*time.Second, time.Millisecond)
+
+	cancelReq := &vearchpb.PartitionData{MessageID: "cancelled", PartitionID: 1}
+	assert.NoError(t, handler.Execute(methodContext(client.CancelHandler, nil), cancelReq, &vearchpb.PartitionData{}))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled request is not replied")
+	}
+
+	assert.Equal(t, vearchpb.ErrorEnum_TIMEOUT, reply.Err.Code)
+	assert.Contains(t, reply.Err.Msg, "cancelled by the caller")
+	assert.Equal(t, "cancelled", reply.MessageID)
+	assert.Nil(t, req.SearchResponse, "the search does not run")
+	assert.Equal(t, cancelledBefore+1, testutil.ToFloat64(cancelledRequests.WithLabelValues(client.SearchHandler, "cancelled")))
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(skippedWork.WithLabelValues(client.SearchHandler, skipStageQueued)) == skippedBefore+1
+	}, 5*time.Second, time.Millisecond)
+	_, ok := s.inflight.Load(inflightKey(req))
+	assert.False(t, ok, "the request is not tracked after it is replied")
+}
+
+func TestExecuteDeadline(t *testing.T) {
+	handler := &UnaryHandler{server: testBusyServer()}
+	deadlineBefore := testutil.ToFloat64(cancelledRequests.WithLabelValues(client.QueryHandler, "deadline"))
+
+	req := &vearchpb.PartitionData{MessageID: "deadline", PartitionID: 1, QueryRequest: &vearchpb.QueryRequest{}}
+	reply := &vearchpb.PartitionData{}
+	ctx := methodContext(client.QueryHandler, map[string]string{string(entity.RPC_TIME_OUT): "5"})
+	assert.NoError(t, handler.Execute(ctx, req, reply))
+
+	assert.Equal(t, vearchpb.ErrorEnum_TIMEOUT, reply.Err.Code)
+	assert.Contains(t, reply.Err.Msg, "timed out[5ms]")
+	assert.Nil(t, req.SearchResponse, "the query does not run")
+	assert.Equal(t, deadlineBefore+1, testutil.ToFloat64(cancelledRequests.WithLabelValues(client.QueryHandler, "deadline")))
+}
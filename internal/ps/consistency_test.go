@@ -0,0 +1,65 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cubefs/cubefs/depends/tiglabs/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// followerStore is a replica not the leader, a call other than ReadIndex
+// panics
+type followerStore struct {
+	PartitionStore
+	readIndex int
+}
+
+func (s *followerStore) ReadIndex(ctx context.Context) error {
+	s.readIndex++
+	return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_LEADER, raft.ErrNotLeader)
+}
+
+func TestLinearizableNotLeader(t *testing.T) {
+	store := &followerStore{}
+	s := &Server{}
+	s.partitions.Store(entity.PartitionID(1), store)
+	handler := &UnaryHandler{server: s}
+	linearizableCtx := func(method string) context.Context {
+		return methodContext(method, map[string]string{client.ConsistencyParam: client.ConsistencyLinearizable})
+	}
+
+	for _, method := range []string{client.SearchHandler, client.QueryHandler} {
+		req := &vearchpb.PartitionData{PartitionID: 1}
+		assert.False(t, handler.execute(linearizableCtx(method), req, make(chan bool, 1)))
+		assert.Equal(t, vearchpb.ErrorEnum_PARTITION_NOT_LEADER, req.Err.Code, method)
+		assert.Equal(t, vearchpb.ErrorEnum_PARTITION_NOT_LEADER, req.SearchResponse.Head.Err.Code, method)
+	}
+
+	req := &vearchpb.PartitionData{PartitionID: 1, Items: []*vearchpb.Item{{Doc: &vearchpb.Document{PKey: "1"}}}}
+	assert.False(t, handler.execute(linearizableCtx(client.GetDocsHandler), req, make(chan bool, 1)))
+	assert.Equal(t, vearchpb.ErrorEnum_PARTITION_NOT_LEADER, req.Err.Code)
+	assert.Nil(t, req.SearchResponse)
+	assert.Equal(t, 3, store.readIndex)
+
+	// a write is not a linearizable read, its leadership is checked by raft
+	assert.NoError(t, linearizable(context.Background(), store, client.BatchHandler, req))
+	assert.Equal(t, 3, store.readIndex)
+}
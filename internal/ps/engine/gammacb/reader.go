@@ -148,6 +148,12 @@ func (ri *readerImpl) Search(ctx context.Context, request *vearchpb.SearchReques
 		}
 	}
 
+	// the engine can not be interrupted, a search the caller gave up on or
+	// past its deadline is skipped
+	if err := ctx.Err(); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, fmt.Errorf("search skipped: %w", err))
+	}
+
 	if trace {
 		partitionIDstr := strconv.FormatUint(uint64(ri.engine.partitionID), 10)
 
@@ -217,6 +223,12 @@ func (ri *readerImpl) Query(ctx context.Context, request *vearchpb.QueryRequest,
 		}
 	}
 
+	// the engine can not be interrupted, a query the caller gave up on or
+	// past its deadline is skipped
+	if err := ctx.Err(); err != nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, fmt.Errorf("query skipped: %w", err))
+	}
+
 	if trace {
 		partitionIDstr := strconv.FormatUint(uint64(ri.engine.partitionID), 10)
 
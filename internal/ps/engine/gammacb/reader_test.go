@@ -0,0 +1,43 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gammacb
+
+import (
+	"context"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vearch/vearch/v3/internal/pkg/atomic"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestReaderSkipAborted(t *testing.T) {
+	// the engine is never called, any pointer stands for an open one
+	var engine byte
+	ri := &readerImpl{engine: &gammaEngine{gamma: unsafe.Pointer(&engine), counter: atomic.NewAtomicInt64(0)}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := ri.Search(ctx, &vearchpb.SearchRequest{Head: &vearchpb.RequestHead{}}, nil)
+	if assert.IsType(t, &vearchpb.VearchErr{}, err) {
+		assert.Equal(t, vearchpb.ErrorEnum_TIMEOUT, err.(*vearchpb.VearchErr).GetError().Code)
+	}
+	assert.ErrorContains(t, err, "search skipped")
+
+	err = ri.Query(ctx, &vearchpb.QueryRequest{Head: &vearchpb.RequestHead{}}, nil)
+	assert.ErrorContains(t, err, "query skipped")
+	assert.Equal(t, int64(0), ri.engine.counter.Get())
+}
@@ -45,6 +45,9 @@ func (f *FieldMapping) UnmarshalJSON(data []byte) error {
 		Dimension  int             `json:"dimension,omitempty"`
 		StoreType  *string         `json:"store_type,omitempty"`
 		StoreParam json.RawMessage `json:"store_param,omitempty"`
+		Default    json.RawMessage `json:"default,omitempty"`
+		Nullable   bool            `json:"nullable,omitempty"`
+		Required   bool            `json:"required,omitempty"`
 	}{}
 	err := json.Unmarshal(data, &tmp)
 	if err != nil {
@@ -116,6 +119,25 @@ func (f *FieldMapping) UnmarshalJSON(data []byte) error {
 		}
 	}
 
+	//set default, nullable and required
+	if tmp.Nullable {
+		switch fieldMapping.FieldType() {
+		case vearchpb.FieldType_VECTOR, vearchpb.FieldType_BOOL:
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("type:[%s] can not be nullable", fieldMapping.FieldType().String()))
+		}
+	}
+	if len(tmp.Default) > 0 && string(tmp.Default) != "null" {
+		if fieldMapping.FieldType() == vearchpb.FieldType_VECTOR {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("type:[%s] can not set default", fieldMapping.FieldType().String()))
+		}
+		if tmp.Required {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] is required and can not set default", f.Name))
+		}
+		fieldMapping.Base().Default = tmp.Default
+	}
+	fieldMapping.Base().Nullable = tmp.Nullable
+	fieldMapping.Base().Required = tmp.Required
+
 	fieldMapping.Base().Name = f.Name
 	f.FieldMappingI = fieldMapping
 	return nil
@@ -142,6 +164,10 @@ type BaseFieldMapping struct {
 	Name   string               `json:"_"`
 	Boost  float64              `json:"boost,omitempty"`
 	Option vearchpb.FieldOption `json:"option,omitempty"`
+
+	Default  json.RawMessage `json:"default,omitempty"`
+	Nullable bool            `json:"nullable,omitempty"`
+	Required bool            `json:"required,omitempty"`
 }
 
 func (f *BaseFieldMapping) Base() *BaseFieldMapping {
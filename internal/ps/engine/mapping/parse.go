@@ -130,3 +130,65 @@ func MergeSchema(old, new []byte) ([]byte, error) {
 
 	return bytes, nil
 }
+
+// UpdateSchema applies fields to schema, a field exists in schema is replaced,
+// others are appended, and fields in dropFields are removed from schema
+func UpdateSchema(schema, fields []byte, dropFields []string) ([]byte, error) {
+	oldFields := make([]json.RawMessage, 0)
+	if err := json.Unmarshal(schema, &oldFields); err != nil {
+		return nil, err
+	}
+	newFields := make([]json.RawMessage, 0)
+	if len(fields) > 0 {
+		if err := json.Unmarshal(fields, &newFields); err != nil {
+			return nil, err
+		}
+	}
+
+	fieldName := func(data json.RawMessage) (string, error) {
+		tmp := struct {
+			Name string `json:"name"`
+		}{}
+		if err := json.Unmarshal(data, &tmp); err != nil {
+			return "", err
+		}
+		return tmp.Name, nil
+	}
+
+	drops := make(map[string]bool, len(dropFields))
+	for _, name := range dropFields {
+		drops[name] = true
+	}
+
+	result := make([]json.RawMessage, 0, len(oldFields)+len(newFields))
+	index := make(map[string]int, len(oldFields))
+	for _, data := range oldFields {
+		name, err := fieldName(data)
+		if err != nil {
+			return nil, err
+		}
+		if drops[name] {
+			continue
+		}
+		index[name] = len(result)
+		result = append(result, data)
+	}
+
+	for _, data := range newFields {
+		name, err := fieldName(data)
+		if err != nil {
+			return nil, err
+		}
+		if drops[name] {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] can not be updated and dropped at the same time", name))
+		}
+		if i, ok := index[name]; ok {
+			result[i] = data
+		} else {
+			index[name] = len(result)
+			result = append(result, data)
+		}
+	}
+
+	return json.Marshal(result)
+}
@@ -0,0 +1,36 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mapping
+
+import (
+	"testing"
+)
+
+func TestUpdateSchema(t *testing.T) {
+	schema := `[{"name":"a","type":"string"},{"name":"b","type":"integer"},{"name":"c","type":"long"}]`
+
+	result, err := UpdateSchema([]byte(schema), []byte(`[{"name":"a","type":"string","index":{"name":"a","type":"SCALAR"}},{"name":"d","type":"float"}]`), []string{"b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"name":"a","type":"string","index":{"name":"a","type":"SCALAR"}},{"name":"c","type":"long"},{"name":"d","type":"float"}]`
+	if string(result) != expected {
+		t.Errorf("UpdateSchema() = %s, want %s", string(result), expected)
+	}
+
+	if _, err = UpdateSchema([]byte(schema), []byte(`[{"name":"b","type":"integer"}]`), []string{"b"}); err == nil {
+		t.Errorf("UpdateSchema() should fail when a field is updated and dropped")
+	}
+}
@@ -190,7 +190,7 @@ func (pih *PartitionInfoHandler) Execute(ctx context.Context, req *vearchpb.Part
 	pis := make([]*entity.PartitionInfo, 0, 1)
 	for _, store := range stores {
 		status := &engine.EngineStatus{}
-		err := store.GetEngine().EngineStatus(status)
+		err := store.WithEngine(func(e engine.Engine) error { return e.EngineStatus(status) })
 		if err != nil {
 			return err
 		}
@@ -203,6 +203,8 @@ func (pih *PartitionInfoHandler) Execute(ctx context.Context, req *vearchpb.Part
 		value.IndexStatus = int(status.IndexStatus)
 		value.IndexNum = int(status.MinIndexedNum)
 		value.MaxDocid = int(status.MaxDocid)
+		value.TTLExpired = store.TTLExpiredNum()
+		value.Rebuild = store.RebuildProgress()
 		if req.Type == vearchpb.OpType_GET {
 			value.Path = store.GetPartition().Path
 			value.RaftStatus = store.Status()
@@ -242,7 +244,15 @@ func (sh *StatsHandler) Execute(ctx context.Context, req *vearchpb.PartitionData
 		pi := &entity.PartitionInfo{PartitionID: pid}
 		stats.PartitionInfos = append(stats.PartitionInfos, pi)
 
-		docNum, err := store.GetEngine().Reader().DocCount(ctx)
+		var docNum uint64
+		var indexStatus, indexNum, maxDocid int
+		err := store.WithEngine(func(e engine.Engine) (err error) {
+			if docNum, err = e.Reader().DocCount(ctx); err != nil {
+				return err
+			}
+			indexStatus, indexNum, maxDocid = e.IndexInfo()
+			return nil
+		})
 		if err != nil {
 			err = fmt.Errorf("got docCount from engine err:[%s]", err.Error())
 			pi.Error = err.Error()
@@ -261,10 +271,11 @@ func (sh *StatsHandler) Execute(ctx context.Context, req *vearchpb.PartitionData
 		pi.Path = store.GetPartition().Path
 		pi.Unreachable = store.GetUnreachable(uint64(pid))
 		pi.Status = store.GetPartition().GetStatus()
-		index_status, index_num, max_docid := store.GetEngine().IndexInfo()
-		pi.IndexStatus = index_status
-		pi.IndexNum = index_num
-		pi.MaxDocid = max_docid
+		pi.IndexStatus = indexStatus
+		pi.IndexNum = indexNum
+		pi.MaxDocid = maxDocid
+		pi.TTLExpired = store.TTLExpiredNum()
+		pi.Rebuild = store.RebuildProgress()
 		pi.RaftStatus = store.Status()
 	})
 
@@ -370,8 +381,7 @@ func (ch *EngineCfgHandler) Execute(ctx context.Context, req *vearchpb.Partition
 		log.Debug("partitonStore is nil.")
 		return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_IS_INVALID, fmt.Errorf("partition (%v), partitonStore is nil ", req.PartitionID))
 	}
-	engine := partitonStore.GetEngine()
-	if engine == nil {
+	if partitonStore.GetEngine() == nil {
 		return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_IS_INVALID, fmt.Errorf("partition (%v), engine is nil ", req.PartitionID))
 	}
 	if req.Type == vearchpb.OpType_CREATE {
@@ -391,7 +401,7 @@ func (ch *EngineCfgHandler) Execute(ctx context.Context, req *vearchpb.Partition
 			}
 		}
 		cfg.CacheInfos = CacheInfos
-		err := engine.SetEngineCfg(cfg)
+		err := partitonStore.WithEngine(func(e engine.Engine) error { return e.SetEngineCfg(cfg) })
 		if err != nil {
 			log.Debug("cache info set error [%+v]", err)
 		}
@@ -399,7 +409,7 @@ func (ch *EngineCfgHandler) Execute(ctx context.Context, req *vearchpb.Partition
 		// invoke c interface
 		log.Debug("invoke cfg info is get")
 		cfg := &gamma.Config{}
-		err := engine.GetEngineCfg(cfg)
+		err := partitonStore.WithEngine(func(e engine.Engine) error { return e.GetEngineCfg(cfg) })
 		if err != nil {
 			log.Debug("cache info set error [%+v]", err)
 		}
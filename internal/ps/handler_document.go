@@ -34,8 +34,10 @@ import (
 	"github.com/vearch/vearch/v3/internal/engine/sdk/go/gamma"
 	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/routine"
 	"github.com/vearch/vearch/v3/internal/pkg/server/rpc/handler"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
 	"github.com/vearch/vearch/v3/internal/ps/engine/mapping"
 	"go.uber.org/atomic"
 )
@@ -113,7 +115,16 @@ func (handler *UnaryHandler) Execute(ctx context.Context, req *vearchpb.Partitio
 		span := opentracing.StartSpan("server-execute", ext.RPCServerOption(spanCtx))
 		defer span.Finish()
 	}
+	if method == client.CancelHandler {
+		handler.server.cancelRequest(req)
+		return nil
+	}
+	// batch searches and queries run in the smaller pool with lower timeout
+	batch := reqMap[client.PriorityParam] == client.PriorityBatch && (method == client.SearchHandler || method == client.QueryHandler)
 	timeout := handler.server.rpcTimeOut * 1000
+	if batch {
+		timeout = handler.server.batchRpcTimeOut * 1000
+	}
 	if s, ok := reqMap[string(entity.RPC_TIME_OUT)]; ok {
 		if t, ok := strconv.Atoi(s); ok == nil {
 			if t > 0 {
@@ -124,36 +135,149 @@ func (handler *UnaryHandler) Execute(ctx context.Context, req *vearchpb.Partitio
 	delayTime := time.Duration(timeout) * time.Millisecond
 	ctx, cancel := context.WithTimeout(ctx, delayTime)
 	defer cancel()
-	stopCh := make(chan struct{})
+	if method == client.SearchHandler || method == client.QueryHandler {
+		defer handler.server.track(req, cancel)()
+	}
+
+	if token, ok := reqMap[client.ConsistencyTokenParam]; ok && (method == client.SearchHandler || method == client.QueryHandler) {
+		if err := handler.waitApplied(ctx, req.PartitionID, token); err != nil {
+			unavailable(req, reply, method, err)
+			return nil
+		}
+	}
 
-	go func(ctx context.Context, req *vearchpb.PartitionData) {
-		handler.execute(ctx, req)
-		close(stopCh)
-	}(ctx, req)
+	release, err := handler.server.admission.admit(ctx, method)
+	if err != nil {
+		unavailable(req, reply, method, err)
+		return nil
+	}
+	stopCh := make(chan struct{})
+	// aborted is read only after stopCh is closed
+	var aborted bool
+
+	if batch {
+		err = routine.RunWorkAsync("batch-"+method, func() {
+			defer release()
+			aborted = handler.execute(ctx, req, handler.server.batchConcurrent)
+			close(stopCh)
+		})
+		if err != nil {
+			release()
+			unavailable(req, reply, method, err)
+			return nil
+		}
+	} else {
+		go func(ctx context.Context, req *vearchpb.PartitionData) {
+			defer release()
+			aborted = handler.execute(ctx, req, handler.server.concurrent)
+			close(stopCh)
+		}(ctx, req)
+	}
 	select {
 	case <-stopCh:
-		reply.PartitionID = req.PartitionID
-		reply.MessageID = req.MessageID
-		reply.Items = req.Items
-		// reply.SearchRequest = req.SearchRequest
-		reply.SearchResponse = req.SearchResponse
-		// reply.SearchRequests = req.SearchRequests
-		reply.SearchResponses = req.SearchResponses
-		reply.DelByQueryResponse = req.DelByQueryResponse
-		reply.Err = req.Err
-		return
-	case <-time.After(delayTime):
-		reply.PartitionID = req.PartitionID
-		reply.MessageID = req.MessageID
-		reply.Items = req.Items
-		msg := fmt.Sprintf("This request processing timed out[%dms]", timeout)
-		reply.Err = vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, errors.New(msg)).GetError()
-		log.Error(msg)
-		return
+		if !aborted {
+			reply.PartitionID = req.PartitionID
+			reply.MessageID = req.MessageID
+			reply.Items = req.Items
+			// reply.SearchRequest = req.SearchRequest
+			reply.SearchResponse = req.SearchResponse
+			// reply.SearchRequests = req.SearchRequests
+			reply.SearchResponses = req.SearchResponses
+			reply.DelByQueryResponse = req.DelByQueryResponse
+			reply.Data = req.Data
+			reply.Err = req.Err
+			return
+		}
+	case <-ctx.Done():
+	}
+	// the deadline passed or router cancelled it, the work left is skipped by
+	// execute and the engine
+	cancelled(ctx)
+	reply.PartitionID = req.PartitionID
+	reply.MessageID = req.MessageID
+	reply.Items = req.Items
+	reply.Err = cancelledError(ctx, timeout)
+	log.Error("partition:[%d] %s aborted: %s", req.PartitionID, method, reply.Err.Msg)
+	return
+}
+
+// unavailable replies a request ps can not serve now, router retries it on
+// other replicas
+func unavailable(req *vearchpb.PartitionData, reply *vearchpb.PartitionData, method string, err error) {
+	reply.PartitionID = req.PartitionID
+	reply.MessageID = req.MessageID
+	reply.Items = req.Items
+	reply.Err = vearchpb.NewError(vearchpb.ErrorEnum_SERVICE_UNAVAILABLE, err).GetError()
+	if method == client.SearchHandler || method == client.QueryHandler {
+		reply.SearchResponse = &vearchpb.SearchResponse{Head: &vearchpb.ResponseHead{Err: reply.Err}}
+	}
+	log.Warn("partition:[%d] reject %s: %s", req.PartitionID, method, err.Error())
+}
+
+// waitApplied waits until the partition has applied the index of token, for
+// at most consistency_wait_ms
+func (handler *UnaryHandler) waitApplied(ctx context.Context, pid entity.PartitionID, token string) error {
+	consistency, err := entity.ParseConsistencyToken(token)
+	if err != nil {
+		return err
 	}
+	index, ok := consistency[pid]
+	store := handler.server.GetPartition(pid)
+	if !ok || store == nil {
+		return nil
+	}
+	wait := time.Duration(defaultConsistencyWaitMs) * time.Millisecond
+	if config.Conf().PS.ConsistencyWaitMs > 0 {
+		wait = time.Duration(config.Conf().PS.ConsistencyWaitMs) * time.Millisecond
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		applied := store.AppliedIndex()
+		if applied >= index {
+			return nil
+		}
+		select {
+		case <-timer.C:
+			return fmt.Errorf("replica applied index %d is behind %d of consistency token", applied, index)
+		case <-ctx.Done():
+			return fmt.Errorf("replica applied index %d is behind %d of consistency token: %s", applied, index, ctx.Err().Error())
+		case <-ticker.C:
+		}
+	}
+}
+
+// linearizable confirms the leadership of store before a read, the error is
+// set in req
+func linearizable(ctx context.Context, store PartitionStore, method string, req *vearchpb.PartitionData) error {
+	switch method {
+	case client.GetDocsHandler, client.GetDocsByPartitionHandler, client.GetNextDocsByPartitionHandler, client.SearchHandler, client.QueryHandler:
+	default:
+		return nil
+	}
+	err := store.ReadIndex(ctx)
+	if err == nil {
+		return nil
+	}
+	log.Warn("partition:[%d] linearizable %s failed: %s", req.PartitionID, method, err.Error())
+	req.Err = vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_LEADER, err).GetError()
+	if method == client.SearchHandler || method == client.QueryHandler {
+		req.SearchResponse = &vearchpb.SearchResponse{Head: &vearchpb.ResponseHead{Err: req.Err}}
+	}
+	return err
+}
+
+// appliedIndex puts the applied index of store in reply of a write, router
+// makes consistency token of it
+func appliedIndex(store PartitionStore, req *vearchpb.PartitionData) {
+	req.Data = []byte(strconv.FormatUint(store.AppliedIndex(), 10))
 }
 
-func (handler *UnaryHandler) execute(ctx context.Context, req *vearchpb.PartitionData) {
+// execute runs req in the pool of concurrent, it reports aborted if ctx was
+// done before the work started
+func (handler *UnaryHandler) execute(ctx context.Context, req *vearchpb.PartitionData, concurrent chan bool) (aborted bool) {
 	defer func() {
 		if r := recover(); r != nil {
 			buf := make([]byte, 2048)
@@ -166,16 +290,24 @@ func (handler *UnaryHandler) execute(ctx context.Context, req *vearchpb.Partitio
 		}
 	}()
 
-	handler.server.concurrent <- true
+	// a request aborted while it waits for a slot does not take one
+	select {
+	case concurrent <- true:
+	case <-ctx.Done():
+		skipped(ctx, skipStageQueued)
+		log.Error("This request waitting aborted, the server can only deal [%d] request at same time: %s", cap(concurrent), ctx.Err().Error())
+		return true
+	}
 	defer func() {
-		<-handler.server.concurrent
+		<-concurrent
 	}()
 	select {
 	case <-ctx.Done():
 		// if this context is timeout, return immediately
-		msg := fmt.Sprintf("This request waitting timed out, the server can only deal [%d] request at same time.", handler.server.concurrentNum)
+		skipped(ctx, skipStageQueued)
+		msg := fmt.Sprintf("This request waitting timed out, the server can only deal [%d] request at same time.", cap(concurrent))
 		log.Error(msg)
-		return
+		return true
 	default:
 		if handler.server == nil {
 			log.Info("%s", "ps server is nil")
@@ -195,6 +327,11 @@ func (handler *UnaryHandler) execute(ctx context.Context, req *vearchpb.Partitio
 			req.Err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError()
 			return
 		}
+		if reqMap[client.ConsistencyParam] == client.ConsistencyLinearizable {
+			if err := linearizable(ctx, store, method, req); err != nil {
+				return
+			}
+		}
 		switch method {
 		case client.GetDocsHandler:
 			getDocuments(ctx, store, req.Items, false, false)
@@ -204,8 +341,10 @@ func (handler *UnaryHandler) execute(ctx context.Context, req *vearchpb.Partitio
 			getDocuments(ctx, store, req.Items, true, true)
 		case client.DeleteDocsHandler:
 			deleteDocs(ctx, store, req.Items)
+			appliedIndex(store, req)
 		case client.BatchHandler:
 			bulk(ctx, store, req.Items)
+			appliedIndex(store, req)
 		case client.SearchHandler:
 			if req.SearchResponse == nil {
 				req.SearchResponse = &vearchpb.SearchResponse{}
@@ -225,6 +364,7 @@ func (handler *UnaryHandler) execute(ctx context.Context, req *vearchpb.Partitio
 				req.DelByQueryResponse = &vearchpb.DelByQueryeResponse{DelNum: 0}
 			}
 			deleteByQuery(ctx, store, req.SearchRequest, req.DelByQueryResponse)
+			appliedIndex(store, req)
 		case client.FlushHandler:
 			req.Err = flush(ctx, store)
 		default:
@@ -233,6 +373,7 @@ func (handler *UnaryHandler) execute(ctx context.Context, req *vearchpb.Partitio
 			return
 		}
 	}
+	return
 }
 
 func getDocuments(ctx context.Context, store PartitionStore, items []*vearchpb.Item, getByDocId bool, next bool) {
@@ -320,10 +461,18 @@ func bulk(ctx context.Context, store PartitionStore, items []*vearchpb.Item) {
 func query(ctx context.Context, store PartitionStore, request *vearchpb.QueryRequest, response *vearchpb.SearchResponse) {
 	startTime := time.Now()
 	if err := store.Query(ctx, request, response); err != nil {
-		log.Error("query doc failed, err: [%s]", err.Error())
-		response.Head.Err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError()
+		if response.Head == nil {
+			response.Head = &vearchpb.ResponseHead{}
+		}
+		if ctx.Err() != nil {
+			skipped(ctx, skipStageEngine)
+			response.Head.Err = vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, err).GetError()
+		} else {
+			log.Error("query doc failed, err: [%s]", err.Error())
+			response.Head.Err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError()
+		}
 	}
-	partitionIDstr := strconv.FormatUint(uint64(store.GetEngine().GetPartitionID()), 10)
+	partitionIDstr := strconv.FormatUint(uint64(store.GetPartition().Id), 10)
 	storeQuery := (time.Since(startTime).Seconds()) * 1000
 	storeQueryStr := strconv.FormatFloat(storeQuery, 'f', 4, 64)
 
@@ -343,10 +492,18 @@ func query(ctx context.Context, store PartitionStore, request *vearchpb.QueryReq
 func search(ctx context.Context, store PartitionStore, request *vearchpb.SearchRequest, response *vearchpb.SearchResponse) {
 	startTime := time.Now()
 	if err := store.Search(ctx, request, response); err != nil {
-		log.Error("search doc failed, err: [%s]", err.Error())
-		response.Head.Err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError()
+		if response.Head == nil {
+			response.Head = &vearchpb.ResponseHead{}
+		}
+		if ctx.Err() != nil {
+			skipped(ctx, skipStageEngine)
+			response.Head.Err = vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, err).GetError()
+		} else {
+			log.Error("search doc failed, err: [%s]", err.Error())
+			response.Head.Err = vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err).GetError()
+		}
 	}
-	partitionIDstr := strconv.FormatUint(uint64(store.GetEngine().GetPartitionID()), 10)
+	partitionIDstr := strconv.FormatUint(uint64(store.GetPartition().Id), 10)
 	storeSearch := (time.Since(startTime).Seconds()) * 1000
 	storeSearchStr := strconv.FormatFloat(storeSearch, 'f', 4, 64)
 
@@ -364,7 +521,7 @@ func search(ctx context.Context, store PartitionStore, request *vearchpb.SearchR
 }
 
 func forceMerge(store PartitionStore) *vearchpb.Error {
-	err := store.GetEngine().Optimize()
+	err := store.WithEngine(func(e engine.Engine) error { return e.Optimize() })
 	if err != nil {
 		partitionID := store.GetPartition().Id
 		pIdStr := strconv.Itoa(int(partitionID))
@@ -374,7 +531,9 @@ func forceMerge(store PartitionStore) *vearchpb.Error {
 }
 
 func rebuildIndex(store PartitionStore, indexRequest *vearchpb.IndexRequest) *vearchpb.Error {
-	err := store.GetEngine().Rebuild(int(indexRequest.DropBeforeRebuild), int(indexRequest.LimitCpu), int(indexRequest.Describe))
+	err := store.WithEngine(func(e engine.Engine) error {
+		return e.Rebuild(int(indexRequest.DropBeforeRebuild), int(indexRequest.LimitCpu), int(indexRequest.Describe))
+	})
 	if err != nil {
 		partitionID := store.GetPartition().Id
 		pIdStr := strconv.Itoa(int(partitionID))
@@ -43,6 +43,9 @@ type Base interface {
 	//GetEngine return engine
 	GetEngine() engine.Engine
 
+	// WithEngine calls f with the engine, which is not closed until f returns
+	WithEngine(f func(e engine.Engine) error) error
+
 	//space change API
 	GetSpace() entity.Space
 
@@ -59,6 +62,10 @@ type Raft interface {
 
 	Status() *raft.Status
 
+	AppliedIndex() uint64
+
+	ReadIndex(ctx context.Context) error
+
 	GetVersion() uint64
 
 	GetUnreachable(id uint64) []uint64
@@ -82,6 +89,13 @@ type PartitionStore interface {
 	Search(ctx context.Context, query *vearchpb.SearchRequest, response *vearchpb.SearchResponse) error
 
 	Query(ctx context.Context, query *vearchpb.QueryRequest, response *vearchpb.SearchResponse) error
+
+	// TTLExpiredNum returns the number of documents deleted by ttl
+	TTLExpiredNum() uint64
+
+	// RebuildProgress returns the progress of engine rebuild, the failed one
+	// if the last rebuild failed, nil if not rebuilding
+	RebuildProgress() *entity.RebuildProgress
 }
 
 func (s *Server) GetPartition(id entity.PartitionID) (partition PartitionStore) {
@@ -42,6 +42,8 @@ func (s *Server) StartHeartbeatJob() {
 			PartitionIds:      make([]entity.PartitionID, 0, 10),
 			Spaces:            make([]*entity.Space, 0, 10),
 			Private:           config.Conf().PS.Private,
+			Zone:              config.Conf().PS.Zone,
+			Rack:              config.Conf().PS.Rack,
 			Version: &entity.BuildVersion{
 				BuildVersion: config.GetBuildVersion(),
 				BuildTime:    config.GetBuildTime(),
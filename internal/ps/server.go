@@ -26,6 +26,7 @@ import (
 	"github.com/vearch/vearch/v3/internal/client"
 	"github.com/vearch/vearch/v3/internal/config"
 	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/monitor"
 	"github.com/vearch/vearch/v3/internal/pkg/errutil"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/metrics/mserver"
@@ -41,6 +42,10 @@ const maxTryTime = 5
 var (
 	defaultConcurrentNum = 32
 	defaultRpcTimeOut    = 10 // 10 second
+	// batch priority requests
+	defaultBatchRpcTimeOut = 3 // 3 second
+	// reads with consistency token wait for the replica to catch up
+	defaultConsistencyWaitMs = 50
 )
 
 // Server partition server
@@ -62,6 +67,13 @@ type Server struct {
 	concurrent      chan bool
 	concurrentNum   int
 	rpcTimeOut      int
+	admission       *admission
+	// batch priority searches and queries
+	batchConcurrent    chan bool
+	batchConcurrentNum int
+	batchRpcTimeOut    int
+	// message id and partition -> cancel func of running searches and queries
+	inflight sync.Map
 }
 
 // NewServer create server instance
@@ -83,11 +95,21 @@ func NewServer(ctx context.Context) *Server {
 		s.concurrentNum = config.Conf().PS.ConcurrentNum
 	}
 	s.concurrent = make(chan bool, s.concurrentNum)
+	s.admission = newAdmission(config.Conf().PS)
 
 	s.rpcTimeOut = defaultRpcTimeOut
 	if config.Conf().PS.RpcTimeOut > 0 {
 		s.rpcTimeOut = config.Conf().PS.RpcTimeOut
 	}
+	s.batchConcurrentNum = max(s.concurrentNum/4, 1)
+	if config.Conf().PS.BatchConcurrentNum > 0 {
+		s.batchConcurrentNum = config.Conf().PS.BatchConcurrentNum
+	}
+	s.batchConcurrent = make(chan bool, s.batchConcurrentNum)
+	s.batchRpcTimeOut = defaultBatchRpcTimeOut
+	if config.Conf().PS.BatchRpcTimeOut > 0 {
+		s.batchRpcTimeOut = config.Conf().PS.BatchRpcTimeOut
+	}
 	s.ctx, s.ctxCancel = context.WithCancel(ctx)
 
 	s.rpcServer = rpc.NewRpcServer(config.LocalCastAddr, config.Conf().PS.RpcPort) // any port ???
@@ -143,6 +165,10 @@ func (s *Server) Start() error {
 	ExportToRpcHandler(s)
 	ExportToRpcAdminHandler(s)
 
+	if port := config.Conf().PS.MonitorPort; port > 0 {
+		monitor.Register(nil, nil, port)
+	}
+
 	log.Info("vearch server successful startup...")
 
 	s.wg.Wait()
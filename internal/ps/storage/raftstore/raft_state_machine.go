@@ -24,6 +24,7 @@ import (
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
 	"github.com/vearch/vearch/v3/internal/ps/psutil"
 )
 
@@ -115,13 +116,14 @@ func (s *Store) innerApply(index uint64, raftCmd *vearchpb.RaftCommand) interfac
 	resp := new(RaftApplyResponse)
 	switch raftCmd.Type {
 	case vearchpb.CmdType_WRITE:
-		resp.Err = s.Engine.Writer().Write(s.Ctx, raftCmd.WriteCommand)
+		resp.Err = s.write(raftCmd.WriteCommand, int64(index))
 	case vearchpb.CmdType_UPDATESPACE:
-		resp = s.updateSchemaBySpace(raftCmd.UpdateSpace.Space)
+		resp = s.updateSchemaBySpace(raftCmd.UpdateSpace.Space, int64(index))
 	case vearchpb.CmdType_FLUSH:
-		flushC, err := s.Engine.Writer().Commit(s.Ctx, int64(index))
-		resp.FlushC = flushC
-		resp.Err = err
+		resp.Err = s.WithEngine(func(e engine.Engine) (err error) {
+			resp.FlushC, err = e.Writer().Commit(s.Ctx, int64(index))
+			return err
+		})
 	default:
 		log.Error("unsupported command[%s]", raftCmd.Type)
 		resp.SetErr(fmt.Errorf("unsupported command[%s]", raftCmd.Type))
@@ -133,8 +135,8 @@ func (s *Store) innerApply(index uint64, raftCmd *vearchpb.RaftCommand) interfac
 	return resp
 }
 
-// changeSchema for add schema field
-func (s *Store) updateSchemaBySpace(spaceBytes []byte) (rap *RaftApplyResponse) {
+// changeSchema for add, drop fields or change index of fields
+func (s *Store) updateSchemaBySpace(spaceBytes []byte, index int64) (rap *RaftApplyResponse) {
 	rap = new(RaftApplyResponse)
 
 	space := &entity.Space{}
@@ -143,11 +145,33 @@ func (s *Store) updateSchemaBySpace(spaceBytes []byte) (rap *RaftApplyResponse)
 		return rap.SetErr(err)
 	}
 
-	err = s.Engine.UpdateMapping(space)
+	// the fields are same as the running rebuild
+	if updated, err := s.updateRebuild(space); err != nil || updated {
+		return rap.SetErr(err)
+	}
+	s.stopRebuild()
+
+	oldSpace := s.GetSpace()
+	changed, err := fieldsChanged(oldSpace.Fields, space.Fields)
 	if err != nil {
 		return rap.SetErr(err)
 	}
 
+	if changed {
+		// the engine table can not alter, so rebuild engine in background
+		// and swap it in when finished, the space meta is saved then
+		return rap.SetErr(s.startRebuild(space, index))
+	}
+
+	if err = s.removeRebuildTask(); err != nil {
+		return rap.SetErr(err)
+	}
+	// the engine matches the space again
+	s.rebuildFailed.Store((*entity.RebuildProgress)(nil))
+	if err = s.WithEngine(func(e engine.Engine) error { return e.UpdateMapping(space) }); err != nil {
+		return rap.SetErr(err)
+	}
+
 	// save partition meta file
 	err = psutil.SavePartitionMeta(s.GetPartition().Path, s.GetPartition().Id, space)
 	if err != nil {
@@ -19,7 +19,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cubefs/cubefs/depends/tiglabs/raft"
@@ -59,6 +61,11 @@ type Store struct {
 	raftDiffCount uint64
 	RsStatusC     chan *ReplicasStatusEntry
 	RsStatusMap   sync.Map
+	ttlExpiredNum uint64
+	rebuild       atomic.Value // *engineRebuild
+	rebuildFailed atomic.Value // *entity.RebuildProgress of the last failed rebuild
+	// reads hold it while using Engine, the rebuild swaps Engine under it
+	engineMu sync.RWMutex
 }
 
 // CreateStore create an instance of Store.
@@ -95,7 +102,7 @@ func (s *Store) ReBuildEngine() (err error) {
 	log.Debug("begin re build engine")
 	// re create engine
 	s.Engine, err = gammacb.Build(gammacb.EngineConfig{
-		Path:        s.DataPath,
+		Path:        s.enginePath(),
 		Space:       s.Space,
 		PartitionID: s.Partition.Id,
 	})
@@ -117,9 +124,14 @@ func (s *Store) ReBuildEngine() (err error) {
 
 // Start start the store.
 func (s *Store) Start() (err error) {
+	task, err := s.recoverRebuild()
+	if err != nil {
+		return err
+	}
+
 	// todo: gamma engine load need run after snapshot finish
 	s.Engine, err = gammacb.Build(gammacb.EngineConfig{
-		Path:        s.DataPath,
+		Path:        s.enginePath(),
 		Space:       s.Space,
 		PartitionID: s.Partition.Id,
 	})
@@ -172,6 +184,14 @@ func (s *Store) Start() (err error) {
 	s.startFlushJob()
 	// Start Raft Truncate Worker
 	s.startTruncateJob(apply)
+	// Start TTL Expire Worker
+	s.startTTLJob()
+	// Resume the unfinished engine rebuild
+	if task != nil {
+		if err = s.startRebuild(task.Space, apply); err != nil {
+			log.Error("resume rebuild of partition[%d] err: %s", s.Partition.Id, err.Error())
+		}
+	}
 
 	return nil
 }
@@ -183,6 +203,7 @@ func (s *Store) Close() error {
 		return err
 	}
 
+	s.stopRebuild()
 	if s.Engine != nil {
 		s.Engine.Close()
 	}
@@ -205,7 +226,7 @@ func (s *Store) Destroy() (err error) {
 				continue
 			}
 			// delete data and raft log
-			if err = os.RemoveAll(s.DataPath); err != nil {
+			if err = s.RemoveDataPath(); err != nil {
 				return
 			}
 			if err = os.RemoveAll(s.RaftPath); err != nil {
@@ -230,6 +251,29 @@ func (s *Store) Status() *raft.Status {
 	return s.RaftServer.Status(uint64(s.Partition.Id))
 }
 
+// ReadIndex confirms this replica is still the leader by a heartbeat round
+// with a quorum, then waits until the index committed at that time is
+// applied. A read after it sees every write acknowledged before it began.
+func (s *Store) ReadIndex(ctx context.Context) error {
+	respCh, errCh := s.RaftServer.ReadIndex(uint64(s.Partition.Id)).AsyncResponse()
+	select {
+	case <-respCh:
+		return nil
+	case err := <-errCh:
+		if err == raft.ErrNotLeader {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_NOT_LEADER, err)
+		}
+		return vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, err)
+	case <-ctx.Done():
+		return vearchpb.NewError(vearchpb.ErrorEnum_TIMEOUT, fmt.Errorf("read index of partition:[%d]: %s", s.Partition.Id, ctx.Err().Error()))
+	}
+}
+
+// AppliedIndex returns the raft index the partition has applied
+func (s *Store) AppliedIndex() uint64 {
+	return s.RaftServer.AppliedIndex(uint64(s.Partition.Id))
+}
+
 func (s *Store) GetLeader() (entity.NodeID, uint64) {
 	return s.RaftServer.LeaderTerm(uint64(s.Partition.Id))
 }
@@ -253,7 +297,13 @@ func (s *Store) GetPartition() *entity.Partition {
 
 func (s *Store) RemoveDataPath() (err error) {
 	// delete data and raft log
-	return os.RemoveAll(s.DataPath)
+	if err = os.RemoveAll(s.DataPath); err != nil {
+		return err
+	}
+	if err = os.RemoveAll(filepath.Clean(s.DataPath) + rebuildPathSuffix); err != nil {
+		return err
+	}
+	return s.setEnginePath(s.DataPath)
 }
 
 func (s *Store) ChangeMember(changeType proto.ConfChangeType, server *entity.Server) error {
@@ -31,6 +31,9 @@ const (
 	FlushTicket                = 1 * time.Second
 	DefaultFlushTimeInterval   = 600 // 10 minutes
 	DefaultFlushCountThreshold = 200000
+	DefaultTTLCheckInterval    = 60 // 1 minute
+	DefaultTTLDeleteRate       = 1000
+	DefaultTTLDeleteBatch      = 1000
 )
 
 var fti int32 // flush time interval
@@ -69,12 +72,16 @@ func (s *Store) startTruncateJob(initLastFlushIndex int64) {
 			default:
 			}
 			// counts condition
-			if s.Engine == nil {
+			if s.GetEngine() == nil {
 				log.Error("store is empty so stop truncate job, dbID:[%d] space:[%d,%s] partitionID:[%d]", s.Space.DBId, s.Space.Id, s.Space.Name, s.Partition.Id)
 				return
 			}
 
-			flushSn, err := s.Engine.Reader().ReadSN(s.Ctx)
+			var flushSn int64
+			err := s.WithEngine(func(e engine.Engine) (err error) {
+				flushSn, err = e.Reader().ReadSN(s.Ctx)
+				return err
+			})
 			if err != nil {
 				log.Error("truncate getsn: %s", err.Error())
 				continue
@@ -114,7 +121,7 @@ func (s *Store) startFlushJob() {
 
 		// init last min indexed num and doc num
 		var engineStatus engine.EngineStatus
-		s.Engine.EngineStatus(&engineStatus)
+		s.WithEngine(func(e engine.Engine) error { return e.EngineStatus(&engineStatus) })
 		lastIndexNum := engineStatus.MinIndexedNum
 		lastMaxDocid := engineStatus.MaxDocid
 
@@ -124,28 +131,32 @@ func (s *Store) startFlushJob() {
 				return
 			}
 			// counts condition
-			if s.Engine == nil {
+			if s.GetEngine() == nil {
 				log.Error("store is empty so stop flush job, dbID:[%d] space:[%d,%s] partitionID:[%d]",
 					s.Space.DBId, s.Space.Id, s.Space.Name, s.Partition.Id)
 				return
 			}
 
-			var status engine.EngineStatus
-			s.Engine.EngineStatus(&status)
-			t := time.Now()
-			tempSn := s.Sn
-			if t.Sub(s.LastFlushTime).Seconds() > float64(fti) && (tempSn-s.LastFlushSn > int64(fct) || status.MinIndexedNum-lastIndexNum > fct || status.MaxDocid-lastMaxDocid > fct) {
-				log.Info("begin to flush, current time: %s, sn: %d, min indexed num=%d, max docid=%d",
-					t.Format(time.RFC3339), tempSn, status.MinIndexedNum, status.MaxDocid)
-				if err := s.Engine.Writer().Flush(s.Ctx, tempSn); err != nil {
-					log.Error(err.Error())
-					return
+			// the engine is not swapped by rebuild while flushing
+			s.WithEngine(func(e engine.Engine) error {
+				var status engine.EngineStatus
+				e.EngineStatus(&status)
+				t := time.Now()
+				tempSn := s.Sn
+				if t.Sub(s.LastFlushTime).Seconds() > float64(fti) && (tempSn-s.LastFlushSn > int64(fct) || status.MinIndexedNum-lastIndexNum > fct || status.MaxDocid-lastMaxDocid > fct) {
+					log.Info("begin to flush, current time: %s, sn: %d, min indexed num=%d, max docid=%d",
+						t.Format(time.RFC3339), tempSn, status.MinIndexedNum, status.MaxDocid)
+					if err := e.Writer().Flush(s.Ctx, tempSn); err != nil {
+						log.Error(err.Error())
+						return err
+					}
+					s.LastFlushSn = tempSn
+					s.LastFlushTime = t
+					lastIndexNum = status.MinIndexedNum
+					lastMaxDocid = status.MaxDocid
 				}
-				s.LastFlushSn = tempSn
-				s.LastFlushTime = t
-				lastIndexNum = status.MinIndexedNum
-				lastMaxDocid = status.MaxDocid
-			}
+				return nil
+			})
 		}
 
 		ticker := time.NewTicker(FlushTicket)
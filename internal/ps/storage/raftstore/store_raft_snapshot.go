@@ -15,21 +15,31 @@
 package raftstore
 
 import (
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
 	"github.com/vearch/vearch/v3/internal/pkg/errutil"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
 )
 
 // Snapshot implements the raft interface.
-func (s *Store) Snapshot() (proto.Snapshot, error) {
-	return s.GetEngine().NewSnapshot()
+func (s *Store) Snapshot() (snapshot proto.Snapshot, err error) {
+	err = s.WithEngine(func(e engine.Engine) error {
+		snapshot, err = e.NewSnapshot()
+		return err
+	})
+	return snapshot, err
 }
 
 // ApplySnapshot implements the raft interface.
 func (s *Store) ApplySnapshot(peers []proto.Peer, iter proto.SnapIterator) (err error) {
 	defer errutil.CatchError(&err)
+	s.stopRebuild()
+	err = s.removeRebuildTask()
+	errutil.ThrowError(err)
 	s.Engine.Close()
 	log.Debug("close engine")
 	i := 0
@@ -54,6 +64,11 @@ func (s *Store) ApplySnapshot(peers []proto.Peer, iter proto.SnapIterator) (err
 	} else {
 		errutil.ThrowError(err)
 	}
+	// the engine of leader may live in the rebuild path
+	if _, e := os.Stat(filepath.Clean(s.DataPath) + rebuildPathSuffix); e == nil {
+		err = s.setEnginePath(filepath.Clean(s.DataPath) + rebuildPathSuffix)
+		errutil.ThrowError(err)
+	}
 	err = s.ReBuildEngine()
 	log.Debug("rebuild engine after store info is [%+v]", s)
 	errutil.ThrowError(err)
@@ -21,15 +21,34 @@ import (
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/vearchlog"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
 )
 
 func (s *Store) GetDocument(ctx context.Context, readLeader bool, doc *vearchpb.Document, getByDocId bool, next bool) (err error) {
 	if err = s.checkReadable(readLeader); err != nil {
 		return err
 	}
+	s.engineMu.RLock()
+	defer s.engineMu.RUnlock()
 	return s.Engine.Reader().GetDoc(ctx, doc, getByDocId, next)
 }
 
+// GetEngine returns the current engine, it may be swapped and closed by
+// rebuild after returned, so use WithEngine to call the engine
+func (s *Store) GetEngine() engine.Engine {
+	s.engineMu.RLock()
+	defer s.engineMu.RUnlock()
+	return s.Engine
+}
+
+// WithEngine calls f with the current engine, which is not swapped or
+// closed by rebuild until f returns
+func (s *Store) WithEngine(f func(e engine.Engine) error) error {
+	s.engineMu.RLock()
+	defer s.engineMu.RUnlock()
+	return f(s.Engine)
+}
+
 // check this store can read
 func (s *Store) checkReadable(readLeader bool) error {
 	status := s.Partition.GetStatus()
@@ -58,6 +77,8 @@ func (s *Store) Search(ctx context.Context, request *vearchpb.SearchRequest, res
 	if err = s.checkReadable(leader); err != nil {
 		return err
 	}
+	s.engineMu.RLock()
+	defer s.engineMu.RUnlock()
 	err = s.Engine.Reader().Search(ctx, request, response)
 	return err
 }
@@ -70,6 +91,8 @@ func (s *Store) Query(ctx context.Context, request *vearchpb.QueryRequest, respo
 	if err = s.checkReadable(leader); err != nil {
 		return err
 	}
+	s.engineMu.RLock()
+	defer s.engineMu.RUnlock()
 	err = s.Engine.Reader().Query(ctx, request, response)
 	return err
 }
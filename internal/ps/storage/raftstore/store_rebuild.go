@@ -0,0 +1,517 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/engine/sdk/go/gamma"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/fileutil"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
+	"github.com/vearch/vearch/v3/internal/ps/engine/gammacb"
+	"github.com/vearch/vearch/v3/internal/ps/engine/mapping"
+	"github.com/vearch/vearch/v3/internal/ps/psutil"
+)
+
+const (
+	rebuildBatch      = 1000
+	rebuildPathSuffix = ".rebuild"
+	// the engine lives in DataPath+rebuildPathSuffix if this file exists in meta path
+	rebuildSlotFile = "rebuild_slot"
+	// the space and path of the unfinished rebuild
+	rebuildTaskFile = "rebuild_task"
+	// max writes queued for the rebuilding engine, the rebuild fails if exceeded
+	rebuildMaxPending = 100000
+)
+
+var errRebuildPending = fmt.Errorf("more than %d writes queued while rebuilding", rebuildMaxPending)
+
+// newRebuildEngine builds the engine of rebuild, it is replaced in tests
+var newRebuildEngine = gammacb.Build
+
+// rebuildTask is persisted in meta path, so the rebuild is resumed after restart
+type rebuildTask struct {
+	Path  string        `json:"path"`
+	Space *entity.Space `json:"space"`
+}
+
+// engineRebuild builds a new engine for the space in background. Writes are
+// applied to the current engine and queued in pending, the queued writes are
+// replayed to the new engine after all documents copied. The current engine
+// keeps serving until the new one is caught up and swapped in.
+type engineRebuild struct {
+	task   *rebuildTask
+	engine engine.Engine
+	proMap map[string]*entity.SpaceProperties
+	oldMap map[string]*entity.SpaceProperties
+	// whether the new space has fields not in the current engine
+	filterOld bool
+	progress  *entity.RebuildProgress
+	stage     atomic.Value // string
+
+	mu      sync.Mutex
+	pending []*vearchpb.DocCmd
+	sn      int64
+
+	ctx context.Context
+	// canceled with errRebuildPending if writes queued too many
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// fieldsChanged reports whether the schema fields of space are changed
+func fieldsChanged(old, new json.RawMessage) (bool, error) {
+	var oldFields, newFields interface{}
+	if err := json.Unmarshal(old, &oldFields); err != nil {
+		return false, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unmarshal old space fields:[%s] err:[%s]", string(old), err.Error()))
+	}
+	if err := json.Unmarshal(new, &newFields); err != nil {
+		return false, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unmarshal new space fields:[%s] err:[%s]", string(new), err.Error()))
+	}
+	return !reflect.DeepEqual(oldFields, newFields), nil
+}
+
+// filterDocs keeps the fields of docs which are in proMap
+func filterDocs(docs [][]byte, proMap map[string]*entity.SpaceProperties) [][]byte {
+	result := make([][]byte, 0, len(docs))
+	for _, data := range docs {
+		doc := &gamma.Doc{}
+		doc.DeSerialize(data)
+		result = append(result, filterDoc(doc.Fields, proMap))
+	}
+	return result
+}
+
+func filterDoc(fields []*vearchpb.Field, proMap map[string]*entity.SpaceProperties) []byte {
+	result := make([]*vearchpb.Field, 0, len(fields))
+	for _, field := range fields {
+		if field.Name == mapping.IdField || proMap[field.Name] != nil {
+			result = append(result, field)
+		}
+	}
+	return (&gamma.Doc{Fields: result}).Serialize()
+}
+
+// bulkErr ignores the SUCCESS error returned by bulk write
+func bulkErr(err error) error {
+	if vErr, ok := err.(*vearchpb.VearchErr); ok && vErr.GetError().Code == vearchpb.ErrorEnum_SUCCESS {
+		return nil
+	}
+	return err
+}
+
+// enginePath returns the path of current engine, engine is rebuilt alternately
+// in DataPath and DataPath+rebuildPathSuffix, because the snapshot of engine
+// is transferred with absolute file names.
+func (s *Store) enginePath() string {
+	if _, err := os.Stat(filepath.Join(s.MetaPath, rebuildSlotFile)); err == nil {
+		return filepath.Clean(s.DataPath) + rebuildPathSuffix
+	}
+	return s.DataPath
+}
+
+// setEnginePath marks path as the path of current engine
+func (s *Store) setEnginePath(path string) error {
+	slotFile := filepath.Join(s.MetaPath, rebuildSlotFile)
+	if path == s.DataPath {
+		if err := os.Remove(slotFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return fileutil.WriteFileAtomic(slotFile, []byte(path), os.ModePerm)
+}
+
+func (s *Store) rebuildTarget() string {
+	if s.enginePath() == s.DataPath {
+		return filepath.Clean(s.DataPath) + rebuildPathSuffix
+	}
+	return s.DataPath
+}
+
+// recoverRebuild finishes the rebuild which has swapped engine before restart,
+// and returns the unfinished rebuild task which should be started again
+func (s *Store) recoverRebuild() (*rebuildTask, error) {
+	taskFile := filepath.Join(s.MetaPath, rebuildTaskFile)
+	data, err := os.ReadFile(taskFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	task := &rebuildTask{}
+	if err = json.Unmarshal(data, task); err != nil {
+		return nil, err
+	}
+
+	if task.Path != s.enginePath() {
+		log.Info("partition:[%d] resume rebuild to space version:[%d]", s.Partition.Id, task.Space.Version)
+		return task, nil
+	}
+
+	// the engine has been swapped, but the space meta not saved
+	if err = psutil.SavePartitionMeta(s.GetPartition().Path, s.GetPartition().Id, task.Space); err != nil {
+		return nil, err
+	}
+	s.SetSpace(task.Space)
+	return nil, s.removeRebuildTask()
+}
+
+// startRebuild starts to rebuild engine by space in background, sn is the
+// raft index the current engine has applied
+func (s *Store) startRebuild(space *entity.Space, sn int64) error {
+	proMap, err := entity.UnmarshalPropertyJSON(space.Fields)
+	if err != nil {
+		return err
+	}
+	oldSpace := s.GetSpace()
+	oldMap, err := entity.UnmarshalPropertyJSON(oldSpace.Fields)
+	if err != nil {
+		return err
+	}
+
+	filterOld := false
+	for name := range proMap {
+		if oldMap[name] == nil {
+			filterOld = true
+		}
+	}
+
+	task := &rebuildTask{Path: s.rebuildTarget(), Space: space}
+	if err = os.RemoveAll(task.Path); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(task.Path, os.ModePerm); err != nil {
+		return err
+	}
+	if err = s.saveRebuildTask(task); err != nil {
+		return err
+	}
+
+	newEngine, err := newRebuildEngine(gammacb.EngineConfig{
+		Path:        task.Path,
+		Space:       space,
+		PartitionID: s.Partition.Id,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancelCause(s.Ctx)
+	r := &engineRebuild{
+		task:      task,
+		engine:    newEngine,
+		proMap:    proMap,
+		oldMap:    oldMap,
+		filterOld: filterOld,
+		progress:  &entity.RebuildProgress{Version: space.Version, StartTime: time.Now().Unix()},
+		pending:   make([]*vearchpb.DocCmd, 0),
+		sn:        sn,
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	r.stage.Store(entity.RebuildStageCopy)
+	s.rebuild.Store(r)
+	s.rebuildFailed.Store((*entity.RebuildProgress)(nil))
+
+	go s.runRebuild(r)
+	return nil
+}
+
+func (s *Store) saveRebuildTask(task *rebuildTask) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(filepath.Join(s.MetaPath, rebuildTaskFile), data, os.ModePerm)
+}
+
+func (s *Store) removeRebuildTask() error {
+	if err := os.Remove(filepath.Join(s.MetaPath, rebuildTaskFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// updateRebuild replaces the space of the running rebuild if the fields of
+// space are same as it, returns false if not rebuilding or fields changed
+func (s *Store) updateRebuild(space *entity.Space) (bool, error) {
+	r, _ := s.rebuild.Load().(*engineRebuild)
+	if r == nil {
+		return false, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending == nil {
+		// engine has been swapped
+		return false, nil
+	}
+	changed, err := fieldsChanged(r.task.Space.Fields, space.Fields)
+	if err != nil || changed {
+		return false, err
+	}
+	task := &rebuildTask{Path: r.task.Path, Space: space}
+	if err = s.saveRebuildTask(task); err != nil {
+		return false, err
+	}
+	r.task = task
+	return true, nil
+}
+
+// stopRebuild cancels the running rebuild and waits it exit
+func (s *Store) stopRebuild() {
+	r, _ := s.rebuild.Load().(*engineRebuild)
+	if r == nil {
+		return
+	}
+	r.cancel(nil)
+	<-r.done
+}
+
+func (s *Store) runRebuild(r *engineRebuild) {
+	var err error
+	defer func() {
+		if rErr := recover(); rErr != nil {
+			err = fmt.Errorf("rebuild panic: %v", rErr)
+			log.Error("rebuild engine of partitionID:[%d] panic: %v, stack: %s", s.Partition.Id, rErr, string(debug.Stack()))
+		}
+		if err != nil {
+			// canceled by stopRebuild or the store closed
+			stopped := errors.Is(context.Cause(r.ctx), context.Canceled)
+			if cause := context.Cause(r.ctx); cause != nil && !stopped {
+				err = cause
+			}
+			r.engine.Close()
+			waitEngineClosed(r.engine)
+			if e := os.RemoveAll(r.task.Path); e != nil {
+				log.Error("remove rebuild path:[%s] err: %s", r.task.Path, e.Error())
+			}
+			if !stopped {
+				log.Error("rebuild engine of partitionID:[%d] to version:[%d] err: %s", s.Partition.Id, r.task.Space.Version, err.Error())
+				// reported to master, the old engine and space keep serving
+				s.rebuildFailed.Store(&entity.RebuildProgress{
+					Version:   r.progress.Version,
+					Stage:     entity.RebuildStageFailed,
+					Done:      atomic.LoadInt64(&r.progress.Done),
+					Total:     atomic.LoadInt64(&r.progress.Total),
+					StartTime: r.progress.StartTime,
+					Error:     err.Error(),
+				})
+			}
+		}
+		s.rebuild.Store((*engineRebuild)(nil))
+		close(r.done)
+	}()
+
+	startTime := time.Now()
+	var status engine.EngineStatus
+	if err = s.Engine.EngineStatus(&status); err != nil {
+		return
+	}
+	atomic.StoreInt64(&r.progress.Total, int64(status.MaxDocid))
+	log.Info("rebuild engine of partitionID:[%d] to version:[%d] begin, max docid:[%d]", s.Partition.Id, r.task.Space.Version, status.MaxDocid)
+
+	// copy documents exist before rebuild
+	docs := make([][]byte, 0, rebuildBatch)
+	for docID := int32(0); docID < status.MaxDocid; docID++ {
+		if err = r.ctx.Err(); err != nil {
+			return
+		}
+		doc := &vearchpb.Document{PKey: strconv.Itoa(int(docID))}
+		// deleted document
+		if e := s.Engine.Reader().GetDoc(r.ctx, doc, true, false); e == nil {
+			docs = append(docs, filterDoc(doc.Fields, r.proMap))
+		}
+		if len(docs) >= rebuildBatch {
+			if err = bulkErr(r.engine.Writer().Write(r.ctx, &vearchpb.DocCmd{Type: vearchpb.OpType_BULK, Docs: docs})); err != nil {
+				return
+			}
+			docs = make([][]byte, 0, rebuildBatch)
+			// replay after the copied batch written, so the queue keeps short
+			if _, err = r.replayPending(); err != nil {
+				return
+			}
+		}
+		atomic.AddInt64(&r.progress.Done, 1)
+	}
+	if len(docs) > 0 {
+		if err = bulkErr(r.engine.Writer().Write(r.ctx, &vearchpb.DocCmd{Type: vearchpb.OpType_BULK, Docs: docs})); err != nil {
+			return
+		}
+	}
+
+	// replay writes applied during copy, until few writes left
+	r.stage.Store(entity.RebuildStageCatchUp)
+	for {
+		if err = r.ctx.Err(); err != nil {
+			return
+		}
+		var n int
+		if n, err = r.replayPending(); err != nil {
+			return
+		}
+		if n < rebuildBatch {
+			break
+		}
+	}
+
+	// swap engine, writes are blocked until swapped
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err = r.ctx.Err(); err != nil {
+		return
+	}
+	if err = r.replay(r.pending); err != nil {
+		return
+	}
+	r.pending = nil
+	if err = r.engine.Writer().Flush(r.ctx, r.sn); err != nil {
+		return
+	}
+	if err = s.setEnginePath(r.task.Path); err != nil {
+		return
+	}
+
+	space := r.task.Space
+
+	oldEngine := s.swapEngine(r.engine)
+	s.LastFlushSn = r.sn
+	s.LastFlushTime = time.Now()
+	s.SetSpace(space)
+	// the users of engine hold engineMu, none is using the old engine after swapped
+	oldEngine.Close()
+
+	if e := psutil.SavePartitionMeta(s.GetPartition().Path, s.GetPartition().Id, space); e != nil {
+		log.Error("save partition:[%d] meta err: %s", s.Partition.Id, e.Error())
+	} else if e := s.removeRebuildTask(); e != nil {
+		log.Error("remove rebuild task of partition:[%d] err: %s", s.Partition.Id, e.Error())
+	}
+
+	go func(oldEngine engine.Engine, path string) {
+		waitEngineClosed(oldEngine)
+		if e := os.RemoveAll(path); e != nil {
+			log.Error("remove old engine path:[%s] err: %s", path, e.Error())
+		}
+	}(oldEngine, s.rebuildTarget())
+
+	log.Info("rebuild engine of space:[%d,%s] partitionID:[%d] to version:[%d] end, docs:[%d] cost:[%v]", space.Id, space.Name, s.Partition.Id, space.Version, atomic.LoadInt64(&r.progress.Done), time.Since(startTime))
+}
+
+// swapEngine replaces the current engine by e after the running reads finish,
+// and returns the old one
+func (s *Store) swapEngine(e engine.Engine) engine.Engine {
+	s.engineMu.Lock()
+	defer s.engineMu.Unlock()
+	oldEngine := s.Engine
+	s.Engine = e
+	return oldEngine
+}
+
+// replayPending replays the queued writes to the new engine, and returns
+// the number of them
+func (r *engineRebuild) replayPending() (int, error) {
+	r.mu.Lock()
+	cmds := r.pending
+	r.pending = make([]*vearchpb.DocCmd, 0)
+	r.mu.Unlock()
+	return len(cmds), r.replay(cmds)
+}
+
+// replay writes cmds to the new engine, the documents not exist are ignored
+func (r *engineRebuild) replay(cmds []*vearchpb.DocCmd) error {
+	for _, cmd := range cmds {
+		var err error
+		switch cmd.Type {
+		case vearchpb.OpType_BULK:
+			err = bulkErr(r.engine.Writer().Write(r.ctx, &vearchpb.DocCmd{Type: cmd.Type, Docs: filterDocs(cmd.Docs, r.proMap)}))
+		case vearchpb.OpType_DELETE:
+			err = r.engine.Writer().Write(r.ctx, cmd)
+			if vErr, ok := err.(*vearchpb.VearchErr); ok && vErr.GetError().Code == vearchpb.ErrorEnum_DOCUMENT_NOT_EXIST {
+				err = nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write applies cmd to the current engine, and queues it for the rebuilding engine
+func (s *Store) write(cmd *vearchpb.DocCmd, sn int64) error {
+	r, _ := s.rebuild.Load().(*engineRebuild)
+	if r == nil {
+		return s.Engine.Writer().Write(s.Ctx, cmd)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending == nil {
+		// engine has been swapped
+		return s.Engine.Writer().Write(s.Ctx, cmd)
+	}
+	if len(r.pending) >= rebuildMaxPending {
+		// the rebuild can not catch up with writes
+		r.cancel(errRebuildPending)
+	} else {
+		r.pending = append(r.pending, cmd)
+		r.sn = sn
+	}
+	if r.filterOld && cmd.Type == vearchpb.OpType_BULK {
+		// fields added by the new space are not in the current engine
+		return s.Engine.Writer().Write(s.Ctx, &vearchpb.DocCmd{Type: cmd.Type, Docs: filterDocs(cmd.Docs, r.oldMap)})
+	}
+	return s.Engine.Writer().Write(s.Ctx, cmd)
+}
+
+func waitEngineClosed(e engine.Engine) {
+	for !e.HasClosed() {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// RebuildProgress returns the progress of engine rebuild, the failed one if
+// the last rebuild failed, nil if not rebuilding
+func (s *Store) RebuildProgress() *entity.RebuildProgress {
+	r, _ := s.rebuild.Load().(*engineRebuild)
+	if r == nil {
+		failed, _ := s.rebuildFailed.Load().(*entity.RebuildProgress)
+		return failed
+	}
+	stage, _ := r.stage.Load().(string)
+	return &entity.RebuildProgress{
+		Version:   r.progress.Version,
+		Stage:     stage,
+		Done:      atomic.LoadInt64(&r.progress.Done),
+		Total:     atomic.LoadInt64(&r.progress.Total),
+		StartTime: r.progress.StartTime,
+	}
+}
@@ -0,0 +1,439 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vearch/vearch/v3/internal/engine/sdk/go/gamma"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine"
+	"github.com/vearch/vearch/v3/internal/ps/engine/gammacb"
+	"github.com/vearch/vearch/v3/internal/ps/engine/mapping"
+	"github.com/vearch/vearch/v3/internal/ps/psutil"
+	"github.com/vearch/vearch/v3/internal/ps/storage"
+)
+
+// fakeEngine fails reads after closed, like the gamma engine
+type fakeEngine struct {
+	engine.Engine
+	closed atomic.Bool
+	reads  atomic.Int64
+
+	// docs by docid, nil is a deleted document
+	docs [][]*vearchpb.Field
+	// GetDoc blocks until canceled
+	block bool
+	// Write fails with it
+	writeErr error
+
+	mu      sync.Mutex
+	writes  []*vearchpb.DocCmd
+	flushSn int64
+}
+
+func (e *fakeEngine) Reader() engine.Reader { return &fakeReader{engine: e} }
+func (e *fakeEngine) Writer() engine.Writer { return &fakeWriter{engine: e} }
+func (e *fakeEngine) Close()                { e.closed.Store(true) }
+func (e *fakeEngine) HasClosed() bool       { return e.closed.Load() }
+
+func (e *fakeEngine) EngineStatus(status *engine.EngineStatus) error {
+	status.MaxDocid = int32(len(e.docs))
+	return nil
+}
+
+func (e *fakeEngine) written() []*vearchpb.DocCmd {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*vearchpb.DocCmd(nil), e.writes...)
+}
+
+type fakeReader struct {
+	engine.Reader
+	engine *fakeEngine
+}
+
+func (r *fakeReader) GetDoc(ctx context.Context, doc *vearchpb.Document, getByDocId bool, next bool) error {
+	if r.engine.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	docID, err := strconv.Atoi(doc.PKey)
+	if err != nil || docID >= len(r.engine.docs) || r.engine.docs[docID] == nil {
+		return vearchpb.NewError(vearchpb.ErrorEnum_DOCUMENT_NOT_EXIST, nil)
+	}
+	doc.Fields = r.engine.docs[docID]
+	return nil
+}
+
+func (r *fakeReader) Search(ctx context.Context, request *vearchpb.SearchRequest, resp *vearchpb.SearchResponse) error {
+	if r.engine.closed.Load() {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_IS_CLOSED, nil)
+	}
+	// the engine is closed while searching
+	time.Sleep(time.Millisecond)
+	if r.engine.closed.Load() {
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARTITION_IS_CLOSED, nil)
+	}
+	r.engine.reads.Add(1)
+	return nil
+}
+
+type fakeWriter struct {
+	engine.Writer
+	engine *fakeEngine
+}
+
+func (w *fakeWriter) Write(ctx context.Context, cmd *vearchpb.DocCmd) error {
+	w.engine.mu.Lock()
+	defer w.engine.mu.Unlock()
+	if w.engine.writeErr != nil {
+		return w.engine.writeErr
+	}
+	if cmd.Type == vearchpb.OpType_DELETE && string(cmd.Doc) == "missing" {
+		return vearchpb.NewError(vearchpb.ErrorEnum_DOCUMENT_NOT_EXIST, nil)
+	}
+	w.engine.writes = append(w.engine.writes, cmd)
+	return nil
+}
+
+func (w *fakeWriter) Flush(ctx context.Context, sn int64) error {
+	w.engine.mu.Lock()
+	defer w.engine.mu.Unlock()
+	w.engine.flushSn = sn
+	return nil
+}
+
+func newTestStore(e engine.Engine) *Store {
+	partition := &entity.Partition{Id: 1}
+	partition.SetStatus(entity.PA_READWRITE)
+	return &Store{StoreBase: &storage.StoreBase{
+		Ctx:       context.Background(),
+		Partition: partition,
+		Space:     &entity.Space{Id: 1, Name: "space"},
+		Engine:    e,
+	}}
+}
+
+const (
+	oldRebuildFields = `[{"name": "a", "type": "integer"}, {"name": "c", "type": "string"}, {"name": "vec", "type": "vector", "dimension": 4, "index": {"name": "idx", "type": "FLAT"}}]`
+	newRebuildFields = `[{"name": "a", "type": "integer"}, {"name": "b", "type": "string"}, {"name": "vec", "type": "vector", "dimension": 4, "index": {"name": "idx", "type": "HNSW"}}]`
+)
+
+// newRebuildStore returns a store of partition paths in a temp dir, its
+// engine is e and the space has oldRebuildFields
+func newRebuildStore(t *testing.T, e engine.Engine) *Store {
+	s := newTestStore(e)
+	space := &entity.Space{Id: 1, Name: "space", Version: 1, Fields: []byte(oldRebuildFields)}
+	s.Space = space
+	s.Partition.Path = t.TempDir()
+	dataPath, _, metaPath, err := psutil.CreatePartitionPaths(s.Partition.Path, space, s.Partition.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.DataPath, s.MetaPath = dataPath, metaPath
+	return s
+}
+
+func rebuildFields(values ...string) []*vearchpb.Field {
+	fields := make([]*vearchpb.Field, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		fields = append(fields, &vearchpb.Field{Name: values[i], Value: []byte(values[i+1])})
+	}
+	return fields
+}
+
+func fieldNames(doc []byte) []string {
+	d := &gamma.Doc{}
+	d.DeSerialize(doc)
+	names := make([]string, 0, len(d.Fields))
+	for _, f := range d.Fields {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withRebuildEngine makes startRebuild build e
+func withRebuildEngine(t *testing.T, e engine.Engine) {
+	build := newRebuildEngine
+	newRebuildEngine = func(cfg gammacb.EngineConfig) (engine.Engine, error) { return e, nil }
+	t.Cleanup(func() { newRebuildEngine = build })
+}
+
+func startTestRebuild(t *testing.T, s *Store, sn int64) *engineRebuild {
+	space := &entity.Space{Id: 1, Name: "space", Version: 2, Fields: []byte(newRebuildFields)}
+	if err := s.startRebuild(space, sn); err != nil {
+		t.Fatal(err)
+	}
+	r, _ := s.rebuild.Load().(*engineRebuild)
+	if r == nil {
+		t.Fatal("rebuild not started")
+	}
+	return r
+}
+
+func TestSwapEngineConcurrentRead(t *testing.T) {
+	oldEngine, newEngine := &fakeEngine{}, &fakeEngine{}
+	s := newTestStore(oldEngine)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	var failed atomic.Int64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				req := &vearchpb.SearchRequest{Head: &vearchpb.RequestHead{}}
+				if err := s.Search(ctx, req, &vearchpb.SearchResponse{}); err != nil {
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	old := s.swapEngine(newEngine)
+	old.Close()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	assert.Same(t, oldEngine, old)
+	assert.Same(t, newEngine, s.GetEngine())
+	assert.Equal(t, int64(0), failed.Load())
+	assert.Greater(t, oldEngine.reads.Load(), int64(0))
+	assert.Greater(t, newEngine.reads.Load(), int64(0))
+}
+
+func TestWithEngineBlocksSwap(t *testing.T) {
+	oldEngine, newEngine := &fakeEngine{}, &fakeEngine{}
+	s := newTestStore(oldEngine)
+
+	using := make(chan struct{})
+	release := make(chan struct{})
+	go s.WithEngine(func(e engine.Engine) error {
+		close(using)
+		<-release
+		assert.False(t, e.HasClosed())
+		return nil
+	})
+	<-using
+
+	swapped := make(chan engine.Engine)
+	go func() {
+		old := s.swapEngine(newEngine)
+		old.Close()
+		swapped <- old
+	}()
+	select {
+	case <-swapped:
+		t.Fatal("engine swapped while used")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	assert.Same(t, oldEngine, <-swapped)
+	assert.True(t, oldEngine.HasClosed())
+}
+
+func TestStartRebuild(t *testing.T) {
+	oldEngine := &fakeEngine{docs: [][]*vearchpb.Field{
+		rebuildFields(mapping.IdField, "1", "a", "1", "c", "x", "vec", "v"),
+		nil,
+		rebuildFields(mapping.IdField, "3", "a", "3", "vec", "v"),
+	}}
+	newEngine := &fakeEngine{}
+	s := newRebuildStore(t, oldEngine)
+	withRebuildEngine(t, newEngine)
+
+	r := startTestRebuild(t, s, 10)
+	<-r.done
+
+	assert.Same(t, newEngine, s.GetEngine())
+	assert.True(t, oldEngine.HasClosed())
+	assert.False(t, newEngine.HasClosed())
+	assert.Nil(t, s.RebuildProgress())
+	assert.Equal(t, entity.Version(2), s.GetSpace().Version)
+	assert.Equal(t, int64(10), newEngine.flushSn)
+	assert.Equal(t, int64(10), s.LastFlushSn)
+	assert.Equal(t, filepath.Clean(s.DataPath)+rebuildPathSuffix, s.enginePath())
+	_, err := os.Stat(filepath.Join(s.MetaPath, rebuildTaskFile))
+	assert.True(t, os.IsNotExist(err))
+
+	// the deleted document is skipped and the dropped field is filtered
+	writes := newEngine.written()
+	assert.Len(t, writes, 1)
+	assert.Equal(t, vearchpb.OpType_BULK, writes[0].Type)
+	assert.Len(t, writes[0].Docs, 2)
+	assert.Equal(t, []string{mapping.IdField, "a", "vec"}, fieldNames(writes[0].Docs[0]))
+
+	// writes go to the new engine after swapped
+	err = s.write(&vearchpb.DocCmd{Type: vearchpb.OpType_DELETE, Doc: []byte("1")}, 11)
+	assert.NoError(t, err)
+	assert.Len(t, newEngine.written(), 2)
+	assert.Empty(t, oldEngine.written())
+}
+
+func TestRebuildWriteQueued(t *testing.T) {
+	oldEngine, newEngine := &fakeEngine{}, &fakeEngine{}
+	s := newTestStore(oldEngine)
+	proMap, _ := entity.UnmarshalPropertyJSON([]byte(newRebuildFields))
+	oldMap, _ := entity.UnmarshalPropertyJSON([]byte(oldRebuildFields))
+	r := &engineRebuild{engine: newEngine, proMap: proMap, oldMap: oldMap, filterOld: true, pending: make([]*vearchpb.DocCmd, 0)}
+	s.rebuild.Store(r)
+
+	doc := (&gamma.Doc{Fields: rebuildFields(mapping.IdField, "1", "a", "1", "b", "y", "c", "x")}).Serialize()
+	err := s.write(&vearchpb.DocCmd{Type: vearchpb.OpType_BULK, Docs: [][]byte{doc}}, 5)
+	assert.NoError(t, err)
+	assert.Len(t, r.pending, 1)
+	assert.Equal(t, int64(5), r.sn)
+	assert.Empty(t, newEngine.written())
+
+	// the field added by the new space is not written to the current engine
+	writes := oldEngine.written()
+	assert.Len(t, writes, 1)
+	assert.Equal(t, []string{mapping.IdField, "a", "c"}, fieldNames(writes[0].Docs[0]))
+}
+
+func TestRebuildPendingLimit(t *testing.T) {
+	oldEngine, newEngine := &fakeEngine{}, &fakeEngine{}
+	s := newTestStore(oldEngine)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	r := &engineRebuild{engine: newEngine, pending: make([]*vearchpb.DocCmd, rebuildMaxPending), sn: 4, ctx: ctx, cancel: cancel}
+	s.rebuild.Store(r)
+
+	err := s.write(&vearchpb.DocCmd{Type: vearchpb.OpType_DELETE, Doc: []byte("1")}, 5)
+	assert.NoError(t, err)
+	assert.Len(t, r.pending, rebuildMaxPending)
+	assert.Equal(t, int64(4), r.sn)
+	assert.ErrorIs(t, context.Cause(ctx), errRebuildPending)
+	// the current engine is still written
+	assert.Len(t, oldEngine.written(), 1)
+}
+
+func TestRebuildFailed(t *testing.T) {
+	oldEngine := &fakeEngine{docs: [][]*vearchpb.Field{rebuildFields(mapping.IdField, "1", "a", "1")}}
+	newEngine := &fakeEngine{writeErr: vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, nil)}
+	s := newRebuildStore(t, oldEngine)
+	withRebuildEngine(t, newEngine)
+
+	r := startTestRebuild(t, s, 10)
+	<-r.done
+
+	assert.Same(t, oldEngine, s.GetEngine())
+	assert.False(t, oldEngine.HasClosed())
+	assert.True(t, newEngine.HasClosed())
+	assert.Equal(t, entity.Version(1), s.GetSpace().Version)
+
+	// the failure is reported until the next rebuild starts
+	progress := s.RebuildProgress()
+	if assert.NotNil(t, progress) {
+		assert.Equal(t, entity.RebuildStageFailed, progress.Stage)
+		assert.Equal(t, entity.Version(2), progress.Version)
+		assert.NotEmpty(t, progress.Error)
+	}
+	retryEngine := &fakeEngine{}
+	withRebuildEngine(t, retryEngine)
+	r = startTestRebuild(t, s, 11)
+	<-r.done
+	assert.Nil(t, s.RebuildProgress())
+	assert.Same(t, retryEngine, s.GetEngine())
+}
+
+func TestRebuildReplay(t *testing.T) {
+	newEngine := &fakeEngine{}
+	proMap, _ := entity.UnmarshalPropertyJSON([]byte(newRebuildFields))
+	r := &engineRebuild{engine: newEngine, proMap: proMap, ctx: context.Background()}
+
+	doc := (&gamma.Doc{Fields: rebuildFields(mapping.IdField, "1", "a", "1", "b", "y", "c", "x")}).Serialize()
+	err := r.replay([]*vearchpb.DocCmd{
+		{Type: vearchpb.OpType_BULK, Docs: [][]byte{doc}},
+		// deleting a document not copied is ignored
+		{Type: vearchpb.OpType_DELETE, Doc: []byte("missing")},
+		{Type: vearchpb.OpType_DELETE, Doc: []byte("1")},
+	})
+	assert.NoError(t, err)
+	writes := newEngine.written()
+	assert.Len(t, writes, 2)
+	assert.Equal(t, []string{mapping.IdField, "a", "b"}, fieldNames(writes[0].Docs[0]))
+	assert.Equal(t, vearchpb.OpType_DELETE, writes[1].Type)
+}
+
+func TestStopRebuild(t *testing.T) {
+	oldEngine := &fakeEngine{docs: [][]*vearchpb.Field{rebuildFields(mapping.IdField, "1", "a", "1")}, block: true}
+	newEngine := &fakeEngine{}
+	s := newRebuildStore(t, oldEngine)
+	withRebuildEngine(t, newEngine)
+
+	r := startTestRebuild(t, s, 10)
+	assert.NotNil(t, s.RebuildProgress())
+	s.stopRebuild()
+
+	select {
+	case <-r.done:
+	default:
+		t.Fatal("rebuild not exited")
+	}
+	assert.Same(t, oldEngine, s.GetEngine())
+	assert.False(t, oldEngine.HasClosed())
+	assert.True(t, newEngine.HasClosed())
+	assert.Nil(t, s.RebuildProgress())
+	assert.Equal(t, entity.Version(1), s.GetSpace().Version)
+	_, err := os.Stat(r.task.Path)
+	assert.True(t, os.IsNotExist(err))
+
+	// the task is left to resume after restart
+	task, err := s.recoverRebuild()
+	assert.NoError(t, err)
+	assert.NotNil(t, task)
+	assert.Equal(t, entity.Version(2), task.Space.Version)
+}
+
+func TestRecoverRebuild(t *testing.T) {
+	s := newRebuildStore(t, &fakeEngine{})
+
+	// no task
+	task, err := s.recoverRebuild()
+	assert.NoError(t, err)
+	assert.Nil(t, task)
+
+	// the engine is not swapped, the task is resumed
+	space := &entity.Space{Id: 1, Name: "space", Version: 2, Fields: []byte(newRebuildFields)}
+	assert.NoError(t, s.saveRebuildTask(&rebuildTask{Path: s.rebuildTarget(), Space: space}))
+	task, err = s.recoverRebuild()
+	assert.NoError(t, err)
+	assert.NotNil(t, task)
+	assert.Equal(t, s.rebuildTarget(), task.Path)
+
+	// the engine is swapped before restart, only the space meta is saved
+	assert.NoError(t, s.setEnginePath(task.Path))
+	task, err = s.recoverRebuild()
+	assert.NoError(t, err)
+	assert.Nil(t, task)
+	assert.Equal(t, entity.Version(2), s.GetSpace().Version)
+	_, err = os.Stat(filepath.Join(s.MetaPath, rebuildTaskFile))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,220 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"context"
+	"math"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cast"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/engine/sdk/go/gamma"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine/mapping"
+	"golang.org/x/time/rate"
+)
+
+var (
+	ttlMetricsOnce sync.Once
+	ttlExpiredDocs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vearch_ps_ttl_expired_docs_total",
+		Help: "documents deleted by the ttl job of partition leaders",
+	}, []string{"db_id", "space"})
+)
+
+func registerTTLMetrics() {
+	ttlMetricsOnce.Do(func() {
+		if err := prometheus.Register(ttlExpiredDocs); err != nil {
+			log.Warnf("register ttl metrics err: %s", err.Error())
+		}
+	})
+}
+
+// ttlJobConfig returns the check interval, delete rate per second and query
+// batch of ttl job, unset ones are the defaults
+func ttlJobConfig(cfg *config.PSCfg) (time.Duration, int, int) {
+	interval, deleteRate, batch := DefaultTTLCheckInterval, DefaultTTLDeleteRate, DefaultTTLDeleteBatch
+	if cfg != nil {
+		if cfg.TTLCheckInterval > 0 {
+			interval = cfg.TTLCheckInterval
+		}
+		if cfg.TTLDeleteRate > 0 {
+			deleteRate = cfg.TTLDeleteRate
+		}
+		if cfg.TTLDeleteBatch > 0 {
+			batch = cfg.TTLDeleteBatch
+		}
+	}
+	return time.Duration(interval) * time.Second, deleteRate, batch
+}
+
+// start ttl job, the leader deletes expired documents through raft
+func (s *Store) startTTLJob() {
+	registerTTLMetrics()
+	go func() {
+		defer func() {
+			if i := recover(); i != nil {
+				log.Error(string(debug.Stack()))
+				log.Error(cast.ToString(i))
+			}
+		}()
+
+		interval, deleteRate, batch := ttlJobConfig(config.Conf().PS)
+		limiter := rate.NewLimiter(rate.Limit(deleteRate), deleteRate)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.Ctx.Done():
+				return
+			case <-ticker.C:
+				space := s.GetSpace()
+				if space.TTL == nil || space.TTL.Field == "" || !s.IsLeader() {
+					continue
+				}
+				startTime := time.Now()
+				expirer := &ttlExpirer{
+					field:    space.TTL.Field,
+					batch:    batch,
+					limiter:  limiter,
+					query:    s.ttlQuery,
+					delete:   s.ttlDelete,
+					isLeader: s.IsLeader,
+					expired: func() {
+						atomic.AddUint64(&s.ttlExpiredNum, 1)
+						ttlExpiredDocs.WithLabelValues(cast.ToString(space.DBId), space.Name).Inc()
+					},
+				}
+				num, err := expirer.expire(s.Ctx, time.Now())
+				if err != nil {
+					log.Error("ttl expire docs of space:[%d,%s] partitionID:[%d] err: %s", space.Id, space.Name, s.Partition.Id, err.Error())
+				}
+				if num > 0 {
+					log.Info("ttl expire [%d] docs of space:[%d,%s] partitionID:[%d], cost: [%v]", num, space.Id, space.Name, s.Partition.Id, time.Since(startTime))
+				}
+			}
+		}
+	}()
+}
+
+// ttlQuery returns the keys of documents matched by req on the leader
+func (s *Store) ttlQuery(ctx context.Context, req *vearchpb.QueryRequest) ([][]byte, error) {
+	resp := &vearchpb.SearchResponse{}
+	if err := s.Query(ctx, req, resp); err != nil {
+		return nil, err
+	}
+	if resp.FlatBytes != nil {
+		gamma.DeSerialize(resp.FlatBytes, resp)
+	}
+	keys := make([][]byte, 0, req.Limit)
+	for _, result := range resp.Results {
+		if result == nil {
+			continue
+		}
+		for _, item := range result.ResultItems {
+			for _, fv := range item.Fields {
+				if fv.Name == mapping.IdField {
+					keys = append(keys, fv.Value)
+				}
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (s *Store) ttlDelete(ctx context.Context, key []byte) error {
+	return s.Write(ctx, &vearchpb.DocCmd{Type: vearchpb.OpType_DELETE, Doc: key})
+}
+
+// ttlExpirer queries documents whose ttl field is not after now in batches
+// and deletes them, limited by the delete rate
+type ttlExpirer struct {
+	field    string
+	batch    int
+	limiter  *rate.Limiter
+	query    func(ctx context.Context, req *vearchpb.QueryRequest) ([][]byte, error)
+	delete   func(ctx context.Context, key []byte) error
+	isLeader func() bool
+	expired  func()
+}
+
+// request returns the query of expired documents, the ttl range filter is
+// evaluated by the engine
+func (e *ttlExpirer) request(now time.Time) *vearchpb.QueryRequest {
+	return &vearchpb.QueryRequest{
+		Head:   &vearchpb.RequestHead{ClientType: "leader"},
+		Limit:  int32(e.batch),
+		Fields: []string{mapping.IdField},
+		RangeFilters: []*vearchpb.RangeFilter{{
+			Field: e.field,
+			// null value of date is min int64, never expired
+			LowerValue:   cbbytes.Int64ToByte(math.MinInt64),
+			UpperValue:   cbbytes.Int64ToByte(now.UnixNano()),
+			IncludeLower: false,
+			IncludeUpper: true,
+		}},
+	}
+}
+
+func (e *ttlExpirer) expire(ctx context.Context, now time.Time) (int, error) {
+	total := 0
+	deleted := make(map[string]bool)
+	for {
+		keys, err := e.query(ctx, e.request(now))
+		if err != nil {
+			return total, err
+		}
+		progress := false
+		for _, key := range keys {
+			// a key deleted before but queried again is not applied yet,
+			// it is left to the next round
+			if deleted[string(key)] {
+				continue
+			}
+			if err := e.limiter.Wait(ctx); err != nil {
+				return total, err
+			}
+			if !e.isLeader() {
+				return total, nil
+			}
+			if err := e.delete(ctx, key); err != nil {
+				return total, err
+			}
+			deleted[string(key)] = true
+			progress = true
+			total++
+			if e.expired != nil {
+				e.expired()
+			}
+		}
+
+		if len(keys) < e.batch || !progress {
+			return total, nil
+		}
+	}
+}
+
+// TTLExpiredNum returns the number of documents deleted by ttl job
+func (s *Store) TTLExpiredNum() uint64 {
+	return atomic.LoadUint64(&s.ttlExpiredNum)
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine/mapping"
+	"golang.org/x/time/rate"
+)
+
+// fakeTTLDocs serves expired keys in query order and removes deleted ones
+type fakeTTLDocs struct {
+	keys    []string
+	queries []*vearchpb.QueryRequest
+	deletes []string
+	// applied false keeps deleted keys queryable, like a lagging apply
+	applied bool
+}
+
+func (f *fakeTTLDocs) query(ctx context.Context, req *vearchpb.QueryRequest) ([][]byte, error) {
+	f.queries = append(f.queries, req)
+	keys := make([][]byte, 0, req.Limit)
+	for _, k := range f.keys {
+		if len(keys) == int(req.Limit) {
+			break
+		}
+		keys = append(keys, []byte(k))
+	}
+	return keys, nil
+}
+
+func (f *fakeTTLDocs) delete(ctx context.Context, key []byte) error {
+	f.deletes = append(f.deletes, string(key))
+	if f.applied {
+		for i, k := range f.keys {
+			if k == string(key) {
+				f.keys = append(f.keys[:i], f.keys[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func ttlKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("doc-%d", i)
+	}
+	return keys
+}
+
+func TestTTLJobConfig(t *testing.T) {
+	interval, deleteRate, batch := ttlJobConfig(nil)
+	assert.Equal(t, DefaultTTLCheckInterval*time.Second, interval)
+	assert.Equal(t, DefaultTTLDeleteRate, deleteRate)
+	assert.Equal(t, DefaultTTLDeleteBatch, batch)
+
+	interval, deleteRate, batch = ttlJobConfig(&config.PSCfg{TTLCheckInterval: 5, TTLDeleteRate: 10, TTLDeleteBatch: 20})
+	assert.Equal(t, 5*time.Second, interval)
+	assert.Equal(t, 10, deleteRate)
+	assert.Equal(t, 20, batch)
+}
+
+func TestTTLExpirerRequest(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	e := &ttlExpirer{field: "expire_at", batch: 7}
+	req := e.request(now)
+	assert.Equal(t, int32(7), req.Limit)
+	assert.Equal(t, []string{mapping.IdField}, req.Fields)
+	assert.Len(t, req.RangeFilters, 1)
+	filter := req.RangeFilters[0]
+	assert.Equal(t, "expire_at", filter.Field)
+	assert.Equal(t, cbbytes.Int64ToByte(math.MinInt64), filter.LowerValue)
+	assert.Equal(t, cbbytes.Int64ToByte(now.UnixNano()), filter.UpperValue)
+	assert.False(t, filter.IncludeLower)
+	assert.True(t, filter.IncludeUpper)
+}
+
+func TestTTLExpirerExpire(t *testing.T) {
+	tests := []struct {
+		name        string
+		keys        int
+		batch       int
+		applied     bool
+		leaderUntil int
+		wantDeleted int
+		wantQueries int
+	}{
+		{name: "no expired docs", keys: 0, batch: 10, applied: true, leaderUntil: -1, wantDeleted: 0, wantQueries: 1},
+		{name: "single batch", keys: 5, batch: 10, applied: true, leaderUntil: -1, wantDeleted: 5, wantQueries: 1},
+		{name: "multiple batches", keys: 25, batch: 10, applied: true, leaderUntil: -1, wantDeleted: 25, wantQueries: 3},
+		{name: "full last batch", keys: 20, batch: 10, applied: true, leaderUntil: -1, wantDeleted: 20, wantQueries: 3},
+		{name: "deletes not applied yet", keys: 25, batch: 10, applied: false, leaderUntil: -1, wantDeleted: 10, wantQueries: 2},
+		{name: "leadership lost", keys: 25, batch: 10, applied: true, leaderUntil: 12, wantDeleted: 12, wantQueries: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := &fakeTTLDocs{keys: ttlKeys(tt.keys), applied: tt.applied}
+			checks, expired := 0, 0
+			e := &ttlExpirer{
+				field:   "expire_at",
+				batch:   tt.batch,
+				limiter: rate.NewLimiter(rate.Inf, 1),
+				query:   docs.query,
+				delete:  docs.delete,
+				isLeader: func() bool {
+					checks++
+					return tt.leaderUntil < 0 || checks <= tt.leaderUntil
+				},
+				expired: func() { expired++ },
+			}
+			num, err := e.expire(context.Background(), time.Now())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantDeleted, num)
+			assert.Equal(t, tt.wantDeleted, expired)
+			assert.Len(t, docs.deletes, tt.wantDeleted)
+			assert.Len(t, docs.queries, tt.wantQueries)
+		})
+	}
+}
+
+func TestTTLExpirerDeleteRate(t *testing.T) {
+	docs := &fakeTTLDocs{keys: ttlKeys(6), applied: true}
+	e := &ttlExpirer{
+		field:    "expire_at",
+		batch:    10,
+		limiter:  rate.NewLimiter(rate.Limit(50), 1),
+		query:    docs.query,
+		delete:   docs.delete,
+		isLeader: func() bool { return true },
+	}
+	start := time.Now()
+	num, err := e.expire(context.Background(), time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 6, num)
+	// the first token is in the burst, the other five wait 20ms each
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestTTLExpirerCancel(t *testing.T) {
+	docs := &fakeTTLDocs{keys: ttlKeys(6), applied: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &ttlExpirer{
+		field:    "expire_at",
+		batch:    10,
+		limiter:  rate.NewLimiter(rate.Limit(1), 1),
+		query:    docs.query,
+		delete:   docs.delete,
+		isLeader: func() bool { return true },
+		expired:  cancel,
+	}
+	num, err := e.expire(ctx, time.Now())
+	assert.Error(t, err)
+	assert.Equal(t, 1, num)
+}
@@ -0,0 +1,205 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	cacheOpSearch = "search"
+	cacheOpQuery  = "query"
+
+	defaultCacheTTLMs = 5000
+)
+
+var (
+	resultCacheOnce sync.Once
+	resultCache     *searchCache
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vearch_router_cache_requests_total",
+		Help: "router search and query result cache requests",
+	}, []string{"op", "result"})
+)
+
+// cacheEntry keeps the result serialized, so the handlers after the cache,
+// rerank, explain or slow log, never change a cached one
+type cacheEntry struct {
+	result   json.RawMessage
+	expireAt time.Time
+}
+
+// searchCache caches the responses of search and query by the normalized
+// request. Every space has a generation in the keys, the writes through this
+// router bump it so the old entries are never hit again, the writes through
+// other routers are visible after ttl.
+type searchCache struct {
+	lru         *lru.Cache
+	ttl         time.Duration
+	generations sync.Map // entity.SpaceID -> *atomic.Uint64
+}
+
+// getResultCache returns the cache of router, it is nil if cache_size is 0
+func getResultCache() *searchCache {
+	resultCacheOnce.Do(func() {
+		cfg := config.Conf().Router
+		if cfg.CacheSize <= 0 {
+			return
+		}
+		l, err := lru.New(cfg.CacheSize)
+		if err != nil {
+			log.Error("create router result cache err: %s", err.Error())
+			return
+		}
+		ttl := cfg.CacheTTLMs
+		if ttl <= 0 {
+			ttl = defaultCacheTTLMs
+		}
+		resultCache = &searchCache{lru: l, ttl: time.Duration(ttl) * time.Millisecond}
+		if err := prometheus.Register(cacheRequests); err != nil {
+			log.Warnf("register router cache metrics err: %s", err.Error())
+		}
+	})
+	return resultCache
+}
+
+func (sc *searchCache) generation(spaceID entity.SpaceID) *atomic.Uint64 {
+	if g, ok := sc.generations.Load(spaceID); ok {
+		return g.(*atomic.Uint64)
+	}
+	g, _ := sc.generations.LoadOrStore(spaceID, &atomic.Uint64{})
+	return g.(*atomic.Uint64)
+}
+
+// invalidate drops all the cached results of space
+func (sc *searchCache) invalidate(space *entity.Space) {
+	if sc == nil || space == nil {
+		return
+	}
+	sc.generation(space.Id).Add(1)
+}
+
+func (sc *searchCache) get(op, key string) json.RawMessage {
+	if sc == nil || key == "" {
+		return nil
+	}
+	if v, ok := sc.lru.Get(key); ok {
+		entry := v.(*cacheEntry)
+		if time.Now().Before(entry.expireAt) {
+			cacheRequests.WithLabelValues(op, "hit").Inc()
+			return entry.result
+		}
+		sc.lru.Remove(key)
+	}
+	cacheRequests.WithLabelValues(op, "miss").Inc()
+	return nil
+}
+
+func (sc *searchCache) set(key string, result map[string]interface{}) {
+	if sc == nil || key == "" {
+		return
+	}
+	data, err := vjson.Marshal(result)
+	if err != nil {
+		log.Error("marshal cached result err: %s", err.Error())
+		return
+	}
+	sc.lru.Add(key, &cacheEntry{result: data, expireAt: time.Now().Add(sc.ttl)})
+}
+
+// cacheable tells if the result of searchDoc may come from the cache, a cached
+// result may be older than the consistency asked and has no explain
+func cacheable(searchDoc *request.SearchDocumentRequest, ex *explainer) bool {
+	return ex == nil && searchDoc.ConsistencyToken == "" && searchDoc.Consistency == ""
+}
+
+// searchKey is the key of a search, it is computed before the search so a
+// write during the search leaves the result under the old generation
+func (sc *searchCache) searchKey(searchDoc *request.SearchDocumentRequest, space *entity.Space, req *vearchpb.SearchRequest) string {
+	if sc == nil {
+		return ""
+	}
+	head, fields := req.Head, req.Fields
+	req.Head, req.Fields = nil, sortedFields(fields)
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	req.Head, req.Fields = head, fields
+	if err != nil {
+		return ""
+	}
+	return sc.key(cacheOpSearch, space, data, searchDoc.Rerank, searchDoc.FunctionScore)
+}
+
+func (sc *searchCache) queryKey(space *entity.Space, req *vearchpb.QueryRequest) string {
+	if sc == nil {
+		return ""
+	}
+	head, fields := req.Head, req.Fields
+	req.Head, req.Fields = nil, sortedFields(fields)
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	req.Head, req.Fields = head, fields
+	if err != nil {
+		return ""
+	}
+	return sc.key(cacheOpQuery, space, data)
+}
+
+func (sc *searchCache) key(op string, space *entity.Space, data []byte, extras ...interface{}) string {
+	h := sha256.New()
+	var buf [8]byte
+	h.Write([]byte(op))
+	binary.BigEndian.PutUint64(buf[:], uint64(space.Id))
+	h.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], uint64(space.Version))
+	h.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], sc.generation(space.Id).Load())
+	h.Write(buf[:])
+	h.Write(data)
+	for _, extra := range extras {
+		value, err := vjson.Marshal(extra)
+		if err != nil {
+			return ""
+		}
+		h.Write(value)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func sortedFields(fields []string) []string {
+	if len(fields) == 0 {
+		return fields
+	}
+	sorted := make([]string, len(fields))
+	copy(sorted, fields)
+	sort.Strings(sorted)
+	return sorted
+}
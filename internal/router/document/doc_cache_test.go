@@ -0,0 +1,143 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"testing"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func testCache(t *testing.T, ttl time.Duration) *searchCache {
+	t.Helper()
+	l, err := lru.New(16)
+	if err != nil {
+		t.Fatalf("create lru err: %v", err)
+	}
+	return &searchCache{lru: l, ttl: ttl}
+}
+
+func TestCacheKeyNormalized(t *testing.T) {
+	sc := testCache(t, time.Minute)
+	space := &entity.Space{Id: 1, Version: 1}
+	query := func(limit int32, fields ...string) *vearchpb.QueryRequest {
+		return &vearchpb.QueryRequest{
+			Head:        &vearchpb.RequestHead{Params: map[string]string{"trace": "true"}},
+			DocumentIds: []string{"1"},
+			Fields:      fields,
+			Limit:       limit,
+		}
+	}
+
+	req := query(10, "b", "a")
+	key := sc.queryKey(space, req)
+	if req.Head == nil || req.Fields[0] != "b" {
+		t.Fatalf("queryKey() changed the request: %v", req)
+	}
+	other := query(10, "a", "b")
+	other.Head = nil
+	if got := sc.queryKey(space, other); got != key {
+		t.Errorf("queryKey() of reordered fields without head = %s, want %s", got, key)
+	}
+	if got := sc.queryKey(space, query(20, "a", "b")); got == key {
+		t.Errorf("queryKey() of other limit = %s, want other key", got)
+	}
+	if got := sc.queryKey(&entity.Space{Id: 1, Version: 2}, query(10, "a", "b")); got == key {
+		t.Errorf("queryKey() of other space version = %s, want other key", got)
+	}
+
+	search := &vearchpb.SearchRequest{Fields: []string{"a"}, TopN: 10}
+	searchDoc := &request.SearchDocumentRequest{}
+	searchKey := sc.searchKey(searchDoc, space, search)
+	searchDoc.Rerank = &request.Rerank{Type: "expression", Expression: "_score"}
+	if got := sc.searchKey(searchDoc, space, search); got == searchKey {
+		t.Errorf("searchKey() with rerank = %s, want other key", got)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	sc := testCache(t, time.Minute)
+	space, otherSpace := &entity.Space{Id: 1}, &entity.Space{Id: 2}
+	req := &vearchpb.QueryRequest{DocumentIds: []string{"1"}}
+
+	key, otherKey := sc.queryKey(space, req), sc.queryKey(otherSpace, req)
+	sc.set(key, map[string]interface{}{"total": 1})
+	sc.set(otherKey, map[string]interface{}{"total": 2})
+	if sc.get(cacheOpQuery, key) == nil {
+		t.Fatalf("get() of %s missed", key)
+	}
+
+	sc.invalidate(space)
+	newKey := sc.queryKey(space, req)
+	if newKey == key {
+		t.Fatalf("queryKey() after invalidate = %s, want other key", newKey)
+	}
+	if got := sc.get(cacheOpQuery, newKey); got != nil {
+		t.Errorf("get() after invalidate = %s, want miss", got)
+	}
+	if sc.queryKey(otherSpace, req) != otherKey || sc.get(cacheOpQuery, otherKey) == nil {
+		t.Errorf("invalidate() of space 1 dropped the result of space 2")
+	}
+}
+
+func TestCacheGetCopy(t *testing.T) {
+	sc := testCache(t, time.Minute)
+	result := map[string]interface{}{"total": 1}
+	sc.set("key", result)
+	// later handlers change the result they returned
+	result["explain"] = "changed"
+	result["total"] = 2
+
+	if got := string(sc.get(cacheOpQuery, "key")); got != `{"total":1}` {
+		t.Errorf("get() = %s, want %s", got, `{"total":1}`)
+	}
+
+	expired := testCache(t, time.Nanosecond)
+	expired.set("key", result)
+	time.Sleep(time.Millisecond)
+	if got := expired.get(cacheOpQuery, "key"); got != nil {
+		t.Errorf("get() of expired = %s, want miss", got)
+	}
+	var nilCache *searchCache
+	nilCache.set("key", result)
+	if got := nilCache.get(cacheOpQuery, "key"); got != nil || nilCache.queryKey(&entity.Space{}, &vearchpb.QueryRequest{}) != "" {
+		t.Errorf("nil cache get() = %s, want miss", got)
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		name      string
+		searchDoc *request.SearchDocumentRequest
+		ex        *explainer
+		want      bool
+	}{
+		{name: "Default", searchDoc: &request.SearchDocumentRequest{}, want: true},
+		{name: "Consistency token", searchDoc: &request.SearchDocumentRequest{ConsistencyToken: "1:10"}},
+		{name: "Linearizable", searchDoc: &request.SearchDocumentRequest{Consistency: "linearizable"}},
+		{name: "Explain", searchDoc: &request.SearchDocumentRequest{}, ex: &explainer{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheable(tt.searchDoc, tt.ex); got != tt.want {
+				t.Errorf("cacheable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/valyala/fastjson"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	defaultEmbedTimeoutMs = 10000
+	defaultEmbedBatchSize = 32
+	embedCacheExpiration  = 10 * time.Minute
+)
+
+// embedRequest and embedResponse are the OpenAI compatible embeddings protocol
+type embedRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type embedResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+type embedClient struct {
+	httpClient *http.Client
+	cache      *cache.Cache
+}
+
+var embedder = &embedClient{
+	httpClient: &http.Client{},
+	cache:      cache.New(embedCacheExpiration, 2*embedCacheExpiration),
+}
+
+// embed returns the vectors of texts, the cached vectors are not requested
+// again and the others are requested in batches
+func (ec *embedClient) embed(ctx context.Context, e *entity.Embedder, dimension int, texts []string) ([][]float32, error) {
+	defer monitor.Profiler("embed", time.Now())
+
+	vectors := make([][]float32, len(texts))
+	missing := make(map[string][]int)
+	inputs := make([]string, 0)
+	for i, text := range texts {
+		if v, ok := ec.cache.Get(embedCacheKey(e, text)); ok {
+			vectors[i] = v.([]float32)
+			continue
+		}
+		if _, ok := missing[text]; !ok {
+			inputs = append(inputs, text)
+		}
+		missing[text] = append(missing[text], i)
+	}
+
+	batchSize := e.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultEmbedBatchSize
+	}
+	for start := 0; start < len(inputs); start += batchSize {
+		end := start + batchSize
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		result, err := ec.request(ctx, e, inputs[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for i, vector := range result {
+			if dimension > 0 && len(vector) != dimension {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedder of field:[%s] returns vector length:[%d] but dimension of field is:[%d]", e.Field, len(vector), dimension))
+			}
+			text := inputs[start+i]
+			ec.cache.SetDefault(embedCacheKey(e, text), vector)
+			for _, idx := range missing[text] {
+				vectors[idx] = vector
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func (ec *embedClient) request(ctx context.Context, e *entity.Embedder, texts []string) ([][]float32, error) {
+	timeout := e.TimeoutMs
+	if timeout <= 0 {
+		timeout = defaultEmbedTimeoutMs
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+
+	body, err := json.Marshal(&embedRequest{Model: e.Model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKeyEnv != "" {
+		// a space saved before the prefix was checked
+		if err := e.CheckKeyEnv(); err != nil {
+			return nil, err
+		}
+		key := os.Getenv(e.APIKeyEnv)
+		if key == "" {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("environment variable:[%s] of embedder api key of field:[%s] is not set in router", e.APIKeyEnv, e.Field))
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := ec.httpClient.Do(req)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("call embedder of field:[%s] err: %s", e.Field, err.Error()))
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("call embedder of field:[%s] status:[%d] response: %s", e.Field, resp.StatusCode, string(data)))
+	}
+
+	result := &embedResponse{}
+	if err = vjson.Unmarshal(data, result); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("unmarshal embedder response of field:[%s] err: %s", e.Field, err.Error()))
+	}
+	if len(result.Data) != len(texts) {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("embedder of field:[%s] returns %d vectors for %d texts", e.Field, len(result.Data), len(texts)))
+	}
+	vectors := make([][]float32, len(texts))
+	for i, d := range result.Data {
+		idx := d.Index
+		if idx < 0 || idx >= len(texts) {
+			idx = i
+		}
+		vectors[idx] = d.Embedding
+	}
+	return vectors, nil
+}
+
+func embedCacheKey(e *entity.Embedder, text string) string {
+	return e.URL + "\x00" + e.Model + "\x00" + text
+}
+
+// embedDocuments replaces the text of vector fields bound to embedder by
+// its vectors, texts of all documents are embedded together
+func embedDocuments(ctx context.Context, docs []json.RawMessage, space *entity.Space, proMap map[string]*entity.SpaceProperties) error {
+	if len(space.Embedders) == 0 {
+		return nil
+	}
+
+	type textRef struct {
+		doc   int
+		field string
+	}
+	docMaps := make([]map[string]json.RawMessage, len(docs))
+	texts := make(map[*entity.Embedder][]string)
+	refs := make(map[*entity.Embedder][]textRef)
+	var parser fastjson.Parser
+	for i, doc := range docs {
+		v, err := parser.ParseBytes(doc)
+		if err != nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document[%d] format error: %s", i, err.Error()))
+		}
+		for _, e := range space.Embedders {
+			// only the text of field is embedded, a vector is kept
+			value := v.Get(e.Field)
+			if value == nil || value.Type() != fastjson.TypeString {
+				continue
+			}
+			text, err := value.StringBytes()
+			if err != nil {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document[%d] field:[%s] format error: %s", i, e.Field, err.Error()))
+			}
+			if docMaps[i] == nil {
+				docMaps[i] = make(map[string]json.RawMessage)
+				if err := vjson.Unmarshal(doc, &docMaps[i]); err != nil {
+					return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document[%d] format error: %s", i, err.Error()))
+				}
+			}
+			texts[e] = append(texts[e], string(text))
+			refs[e] = append(refs[e], textRef{doc: i, field: e.Field})
+		}
+	}
+
+	changed := make(map[int]bool)
+	for e, ts := range texts {
+		pro := proMap[e.Field]
+		if pro == nil {
+			return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("embedder field:[%s] not found in space fields", e.Field))
+		}
+		vectors, err := embedder.embed(ctx, e, pro.Dimension, ts)
+		if err != nil {
+			return err
+		}
+		for i, ref := range refs[e] {
+			value, err := vjson.Marshal(vectors[i])
+			if err != nil {
+				return err
+			}
+			docMaps[ref.doc][ref.field] = value
+			changed[ref.doc] = true
+		}
+	}
+
+	for i := range changed {
+		doc, err := vjson.Marshal(docMaps[i])
+		if err != nil {
+			return err
+		}
+		docs[i] = doc
+	}
+	return nil
+}
+
+// embedQueryText returns the vectors of query text, text is a string or an
+// array of strings, the vectors are concatenated as feature of query
+func embedQueryText(ctx context.Context, space *entity.Space, field string, dimension int, text json.RawMessage) (json.RawMessage, error) {
+	e := space.Embedder(field)
+	if e == nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field:[%s] has no embedder, text can not be searched", field))
+	}
+	var texts []string
+	if len(text) > 0 && text[0] == '"' {
+		var t string
+		if err := vjson.Unmarshal(text, &t); err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field:[%s] text format error: %s", field, err.Error()))
+		}
+		texts = []string{t}
+	} else if err := vjson.Unmarshal(text, &texts); err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field:[%s] text should be string or array of string", field))
+	}
+	if len(texts) == 0 {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field:[%s] text is empty", field))
+	}
+
+	vectors, err := embedder.embed(ctx, e, dimension, texts)
+	if err != nil {
+		return nil, err
+	}
+	feature := make([]float32, 0, len(vectors)*dimension)
+	for _, vector := range vectors {
+		feature = append(feature, vector...)
+	}
+	return vjson.Marshal(feature)
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+)
+
+// testEmbedServer serves embeddings of dimension 2, the vector of a text is
+// [len(text), index], it fails requests without the bearer key
+func testEmbedServer(t *testing.T, key string, calls *int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if r.Header.Get("Authorization") != "Bearer "+key {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		req := &embedRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := &embedResponse{}
+		for i, text := range req.Input {
+			resp.Data = append(resp.Data, struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}{Index: i, Embedding: []float32{float32(len(text)), float32(i)}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testEmbedSpace(t *testing.T, url string) *entity.Space {
+	t.Helper()
+	fields := `[
+		{"name": "vec", "type": "vector", "dimension": 2},
+		{"name": "title", "type": "string"}
+	]`
+	return &entity.Space{
+		Fields:          []byte(fields),
+		SpaceProperties: testProperties(t, fields),
+		Index:           &entity.Index{Type: "FLAT"},
+		Embedders:       []*entity.Embedder{{Field: "vec", URL: url, APIKeyEnv: "VEARCH_EMBEDDER_TEST_KEY"}},
+	}
+}
+
+func TestEmbedDocuments(t *testing.T) {
+	t.Setenv("VEARCH_EMBEDDER_TEST_KEY", "secret")
+	var calls int32
+	srv := testEmbedServer(t, "secret", &calls)
+	space := testEmbedSpace(t, srv.URL+"/documents")
+
+	docs := []json.RawMessage{
+		json.RawMessage(`{"_id": "1", "vec": "abc"}`),
+		json.RawMessage(`{"_id": "2", "title": "vec", "vec": [1, 2]}`),
+		json.RawMessage(`{"_id": "3", "title": "the \"vec\" field"}`),
+	}
+	if err := embedDocuments(context.Background(), docs, space, space.SpaceProperties); err != nil {
+		t.Fatalf("embedDocuments() error = %v", err)
+	}
+	doc := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(docs[0], &doc); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if string(doc["vec"]) != "[3,0]" {
+		t.Errorf("embedded vec = %s, want [3,0]", doc["vec"])
+	}
+	if string(docs[1]) != `{"_id": "2", "title": "vec", "vec": [1, 2]}` {
+		t.Errorf("vector document changed: %s", docs[1])
+	}
+	if string(docs[2]) != `{"_id": "3", "title": "the \"vec\" field"}` {
+		t.Errorf("document without field changed: %s", docs[2])
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("embedder calls = %d, want 1", calls)
+	}
+
+	if err := embedDocuments(context.Background(), []json.RawMessage{json.RawMessage(`{"vec": `)}, space, space.SpaceProperties); err == nil {
+		t.Errorf("embedDocuments() of malformed document should fail")
+	}
+}
+
+func TestEmbedAPIKeyEnv(t *testing.T) {
+	var calls int32
+	srv := testEmbedServer(t, "secret", &calls)
+	space := testEmbedSpace(t, srv.URL+"/key")
+
+	t.Setenv("VEARCH_EMBEDDER_TEST_KEY", "")
+	docs := []json.RawMessage{json.RawMessage(`{"vec": "unset key"}`)}
+	if err := embedDocuments(context.Background(), docs, space, space.SpaceProperties); err == nil || !strings.Contains(err.Error(), "VEARCH_EMBEDDER_TEST_KEY") {
+		t.Errorf("embedDocuments() error = %v, want unset environment variable", err)
+	}
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Errorf("embedder called %d times without key", calls)
+	}
+
+	t.Setenv("VEARCH_EMBEDDER_TEST_KEY", "wrong")
+	if err := embedDocuments(context.Background(), docs, space, space.SpaceProperties); err == nil {
+		t.Errorf("embedDocuments() with wrong key should fail")
+	}
+}
+
+func TestParseVectorsText(t *testing.T) {
+	t.Setenv("VEARCH_EMBEDDER_TEST_KEY", "secret")
+	var calls int32
+	srv := testEmbedServer(t, "secret", &calls)
+	space := testEmbedSpace(t, srv.URL+"/search")
+
+	tests := []struct {
+		name    string
+		vector  string
+		wantNum int
+		want    []float32
+		wantErr bool
+	}{
+		{name: "Text", vector: `{"field": "vec", "text": "hello"}`, wantNum: 1, want: []float32{5, 0}},
+		{name: "Texts", vector: `{"field": "vec", "text": ["hi", "hey"]}`, wantNum: 2, want: []float32{2, 0, 3, 1}},
+		{name: "Feature before text", vector: `{"field": "vec", "feature": [7, 8], "text": "hello"}`, wantNum: 1, want: []float32{7, 8}},
+		{name: "Text of field without embedder", vector: `{"field": "title", "text": "hello"}`, wantErr: true},
+		{name: "Empty texts", vector: `{"field": "vec", "text": []}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			num, vqs, err := parseVectors(context.Background(), 0, nil, []json.RawMessage{json.RawMessage(tt.vector)}, space)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVectors() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if num != tt.wantNum || len(vqs) != 1 {
+				t.Fatalf("parseVectors() = %d, %d queries, want %d, 1 query", num, len(vqs), tt.wantNum)
+			}
+			feature, err := cbbytes.ByteToFloat32Array(vqs[0].Value)
+			if err != nil {
+				t.Fatalf("ByteToFloat32Array() error = %v", err)
+			}
+			if !reflect.DeepEqual(feature, tt.want) {
+				t.Errorf("feature = %v, want %v", feature, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbedDimensionMismatch(t *testing.T) {
+	t.Setenv("VEARCH_EMBEDDER_TEST_KEY", "secret")
+	var calls int32
+	srv := testEmbedServer(t, "secret", &calls)
+	space := testEmbedSpace(t, srv.URL+"/dimension")
+
+	if _, err := embedder.embed(context.Background(), space.Embedders[0], 3, []string{"dimension"}); err == nil {
+		t.Errorf("embed() of dimension 2 into 3 should fail")
+	}
+}
+
+func TestEmbedKeyEnvPrefix(t *testing.T) {
+	t.Setenv("TEST_OTHER_SECRET", "secret")
+	var calls int32
+	srv := testEmbedServer(t, "secret", &calls)
+	space := testEmbedSpace(t, srv.URL+"/prefix")
+	// a space saved before the prefix was checked
+	space.Embedders[0].APIKeyEnv = "TEST_OTHER_SECRET"
+
+	docs := []json.RawMessage{json.RawMessage(`{"vec": "other env"}`)}
+	if err := embedDocuments(context.Background(), docs, space, space.SpaceProperties); err == nil || !strings.Contains(err.Error(), entity.EmbedderKeyEnvPrefix) {
+		t.Errorf("embedDocuments() error = %v, want api_key_env without prefix rejected", err)
+	}
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Errorf("embedder called %d times with other env", calls)
+	}
+}
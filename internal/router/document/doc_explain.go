@@ -0,0 +1,349 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/number"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const ExplainField = "_explain"
+
+type rangeFilterExplain struct {
+	Field        string      `json:"field"`
+	Lower        interface{} `json:"lower"`
+	Upper        interface{} `json:"upper"`
+	IncludeLower bool        `json:"include_lower"`
+	IncludeUpper bool        `json:"include_upper"`
+}
+
+type termFilterExplain struct {
+	Field    string   `json:"field"`
+	Value    []string `json:"value"`
+	Operator string   `json:"operator"`
+}
+
+type vectorExplain struct {
+	Field    string  `json:"field"`
+	QueryNum int     `json:"query_num"`
+	MinScore float64 `json:"min_score"`
+	MaxScore float64 `json:"max_score"`
+	Weight   float64 `json:"weight"`
+}
+
+// vectorScoreExplain is the raw score of a vector field of a hit and its
+// contribution to the weighted score of multi vector search
+type vectorScoreExplain struct {
+	Field        string  `json:"field"`
+	Score        float64 `json:"score"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
+
+// explainer builds the explain of search and query, the partitions report
+// what they did by the explain param of request head
+type explainer struct {
+	searchDoc  *request.SearchDocumentRequest
+	space      *entity.Space
+	proMap     map[string]*entity.SpaceProperties
+	metricType string
+	weights    []float64
+	// vector fields only fetched to explain the scores
+	added map[string]bool
+}
+
+func newExplainer(searchDoc *request.SearchDocumentRequest, space *entity.Space, head *vearchpb.RequestHead) *explainer {
+	if !searchDoc.Explain && head.Params[client.ExplainParam] != "true" {
+		return nil
+	}
+	head.Params[client.ExplainParam] = "true"
+
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		proMap, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	e := &explainer{searchDoc: searchDoc, space: space, proMap: proMap, added: make(map[string]bool)}
+
+	indexParams := &entity.IndexParams{}
+	if len(searchDoc.IndexParams) > 0 {
+		_ = vjson.Unmarshal(searchDoc.IndexParams, indexParams)
+	}
+	if indexParams.MetricType == "" && space.Index != nil && len(space.Index.Params) > 0 {
+		_ = vjson.Unmarshal(space.Index.Params, indexParams)
+	}
+	e.metricType = indexParams.MetricType
+	return e
+}
+
+// prepare fetches the vector fields of hits to break down the score of a
+// multi vector search
+func (e *explainer) prepare(req *vearchpb.SearchRequest) {
+	n := len(req.VecFields)
+	if n == 0 {
+		return
+	}
+	e.weights = make([]float64, n)
+	for i := range e.weights {
+		e.weights[i] = 1.0 / float64(n)
+	}
+	if n > 1 && req.Ranker != "" {
+		ranker := &struct {
+			Params []float64 `json:"params"`
+		}{}
+		if err := vjson.Unmarshal([]byte(req.Ranker), ranker); err == nil && len(ranker.Params) == n {
+			e.weights = ranker.Params
+		}
+	}
+
+	if n < 2 || e.space.Index.Type == "BINARYIVF" {
+		return
+	}
+	for _, vq := range req.VecFields {
+		found := false
+		for _, f := range req.Fields {
+			if f == vq.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			req.Fields = append(req.Fields, vq.Name)
+			e.added[vq.Name] = true
+		}
+	}
+}
+
+// explainHits adds _explain to the source of every hit, it is called before
+// the hits are rescored so search_score is the score of the engine
+func (e *explainer) explainHits(req *vearchpb.SearchRequest, results []*vearchpb.SearchResult) {
+	queries := make([][]float32, len(req.VecFields))
+	if len(req.VecFields) > 1 && e.space.Index.Type != "BINARYIVF" {
+		for i, vq := range req.VecFields {
+			feature, err := cbbytes.ByteToVectorForFloat32(vq.Value)
+			if err != nil {
+				log.Error("explain decode vector of field:[%s] err: %s", vq.Name, err.Error())
+				return
+			}
+			queries[i] = feature
+		}
+	}
+
+	for qi, result := range results {
+		if result == nil {
+			continue
+		}
+		for _, item := range result.ResultItems {
+			explain := map[string]interface{}{"search_score": item.Score}
+			if len(req.VecFields) > 1 && e.space.Index.Type != "BINARYIVF" {
+				vectors := make([]*vectorScoreExplain, 0, len(req.VecFields))
+				for i, vq := range req.VecFields {
+					score, ok := e.vectorScore(vq.Name, queries[i], qi, item, req.L2Sqrt)
+					if !ok {
+						continue
+					}
+					vectors = append(vectors, &vectorScoreExplain{
+						Field:        vq.Name,
+						Score:        score,
+						Weight:       e.weights[i],
+						Contribution: score * e.weights[i],
+					})
+				}
+				explain["vectors"] = vectors
+			}
+			e.setSource(item, explain)
+		}
+	}
+}
+
+// vectorScore computes the raw score of field between query qi and the
+// vector of item the same way as the engine
+func (e *explainer) vectorScore(field string, query []float32, qi int, item *vearchpb.ResultItem, l2Sqrt bool) (float64, bool) {
+	pro := e.proMap[field]
+	if pro == nil || pro.Dimension <= 0 || len(query) < (qi+1)*pro.Dimension {
+		return 0, false
+	}
+	q := make([]float32, pro.Dimension)
+	copy(q, query[qi*pro.Dimension:(qi+1)*pro.Dimension])
+	if pro.Format != nil && (*pro.Format == "normalization" || *pro.Format == "normal") {
+		if err := number.Normalization(q); err != nil {
+			return 0, false
+		}
+	}
+
+	var v []float32
+	for _, f := range item.Fields {
+		if f.Name == field {
+			vector, err := cbbytes.ByteToVectorForFloat32(f.Value)
+			if err != nil {
+				return 0, false
+			}
+			v = vector
+			break
+		}
+	}
+	if len(v) != len(q) {
+		return 0, false
+	}
+
+	var score float64
+	if strings.EqualFold(e.metricType, "L2") {
+		for i := range q {
+			d := float64(q[i] - v[i])
+			score += d * d
+		}
+		if l2Sqrt {
+			score = math.Sqrt(score)
+		}
+	} else {
+		for i := range q {
+			score += float64(q[i] * v[i])
+		}
+	}
+	return score, true
+}
+
+// setSource adds explain to the source of item and removes the vector
+// fields only fetched for explain
+func (e *explainer) setSource(item *vearchpb.ResultItem, explain map[string]interface{}) {
+	source := make(map[string]json.RawMessage)
+	if len(item.Source) > 0 {
+		if err := vjson.Unmarshal(item.Source, &source); err != nil {
+			log.Error("explain unmarshal source err: %s", err.Error())
+			return
+		}
+	}
+	for field := range e.added {
+		delete(source, field)
+	}
+	value, err := vjson.Marshal(explain)
+	if err != nil {
+		return
+	}
+	source[ExplainField] = value
+	if item.Source, err = vjson.Marshal(source); err != nil {
+		log.Error("explain marshal source err: %s", err.Error())
+	}
+}
+
+// searchExplain is the explain of the whole search in the response
+func (e *explainer) searchExplain(req *vearchpb.SearchRequest, head *vearchpb.ResponseHead) map[string]interface{} {
+	explain := map[string]interface{}{
+		"partitions":      partitionExplains(head),
+		"top_n":           req.TopN,
+		"is_brute_search": req.IsBruteSearch,
+		"metric_type":     e.metricType,
+	}
+	if req.IndexParams != "" {
+		explain["index_params"] = json.RawMessage(req.IndexParams)
+	}
+	vectors := make([]*vectorExplain, 0, len(req.VecFields))
+	for i, vq := range req.VecFields {
+		ve := &vectorExplain{Field: vq.Name, MinScore: vq.MinScore, MaxScore: vq.MaxScore}
+		if i < len(e.weights) {
+			ve.Weight = e.weights[i]
+		}
+		if pro := e.proMap[vq.Name]; pro != nil && pro.Dimension > 0 {
+			if e.space.Index.Type == "BINARYIVF" {
+				ve.QueryNum = len(vq.Value) / (pro.Dimension / 8)
+			} else {
+				ve.QueryNum = len(vq.Value) / 4 / pro.Dimension
+			}
+		}
+		vectors = append(vectors, ve)
+	}
+	explain["vectors"] = vectors
+	e.filtersExplain(explain, req.RangeFilters, req.TermFilters)
+	if e.searchDoc.FunctionScore != nil {
+		explain["function_score"] = e.searchDoc.FunctionScore.Expression
+	}
+	if e.searchDoc.Rerank != nil {
+		explain["rerank"] = e.searchDoc.Rerank.Type
+	}
+	return explain
+}
+
+// queryExplain is the explain of the whole query in the response
+func (e *explainer) queryExplain(req *vearchpb.QueryRequest, head *vearchpb.ResponseHead) map[string]interface{} {
+	explain := map[string]interface{}{
+		"partitions": partitionExplains(head),
+		"limit":      req.Limit,
+	}
+	e.filtersExplain(explain, req.RangeFilters, req.TermFilters)
+	return explain
+}
+
+func (e *explainer) filtersExplain(explain map[string]interface{}, rfs []*vearchpb.RangeFilter, tfs []*vearchpb.TermFilter) {
+	ranges := make([]*rangeFilterExplain, 0, len(rfs))
+	for _, rf := range rfs {
+		re := &rangeFilterExplain{Field: rf.Field, IncludeLower: rf.IncludeLower, IncludeUpper: rf.IncludeUpper}
+		if pro := e.proMap[rf.Field]; pro != nil {
+			re.Lower, re.Upper = rangeBound(pro.FieldType, rf.LowerValue), rangeBound(pro.FieldType, rf.UpperValue)
+		}
+		ranges = append(ranges, re)
+	}
+	terms := make([]*termFilterExplain, 0, len(tfs))
+	for _, tf := range tfs {
+		te := &termFilterExplain{Field: tf.Field, Operator: "in"}
+		switch tf.IsUnion {
+		case 0:
+			te.Operator = "and"
+		case 2:
+			te.Operator = "not in"
+		}
+		for _, v := range bytes.Split(tf.Value, []byte{'\001'}) {
+			te.Value = append(te.Value, string(v))
+		}
+		terms = append(terms, te)
+	}
+	explain["range_filters"] = ranges
+	explain["term_filters"] = terms
+}
+
+func rangeBound(fieldType vearchpb.FieldType, value []byte) interface{} {
+	switch fieldType {
+	case vearchpb.FieldType_INT:
+		return cbbytes.Bytes2Int32(value)
+	case vearchpb.FieldType_LONG, vearchpb.FieldType_DATE:
+		return cbbytes.Bytes2Int(value)
+	case vearchpb.FieldType_FLOAT:
+		return cbbytes.ByteToFloat32(value)
+	case vearchpb.FieldType_DOUBLE:
+		return cbbytes.ByteToFloat64New(value)
+	}
+	return nil
+}
+
+func partitionExplains(head *vearchpb.ResponseHead) []*response.PartitionExplain {
+	explains := make([]*response.PartitionExplain, 0)
+	if head == nil || head.Params == nil || head.Params[client.ExplainParam] == "" {
+		return explains
+	}
+	if err := vjson.Unmarshal([]byte(head.Params[client.ExplainParam]), &explains); err != nil {
+		log.Error("unmarshal partition explain err: %s", err.Error())
+	}
+	return explains
+}
@@ -0,0 +1,276 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func testExplainer(t *testing.T, metricType string) *explainer {
+	t.Helper()
+	fields := `[
+		{"name": "vec1", "type": "vector", "dimension": 2},
+		{"name": "vec2", "type": "vector", "dimension": 2},
+		{"name": "age", "type": "integer"},
+		{"name": "price", "type": "double"}
+	]`
+	space := &entity.Space{
+		Fields:          []byte(fields),
+		SpaceProperties: testProperties(t, fields),
+		Index:           &entity.Index{Type: "FLAT", Params: []byte(`{"metric_type": "` + metricType + `"}`)},
+	}
+	head := &vearchpb.RequestHead{Params: map[string]string{}}
+	e := newExplainer(&request.SearchDocumentRequest{Explain: true}, space, head)
+	if e == nil || head.Params[client.ExplainParam] != "true" {
+		t.Fatalf("newExplainer() = %v, head params %v", e, head.Params)
+	}
+	return e
+}
+
+func testVectorBytes(t *testing.T, vector ...float32) []byte {
+	t.Helper()
+	data, err := cbbytes.VectorToByte(vector)
+	if err != nil {
+		t.Fatalf("vector to bytes err: %v", err)
+	}
+	return data
+}
+
+func TestExplainWeightedScore(t *testing.T) {
+	e := testExplainer(t, "InnerProduct")
+	req := &vearchpb.SearchRequest{
+		VecFields: []*vearchpb.VectorQuery{
+			{Name: "vec1", Value: testVectorBytes(t, 1, 0)},
+			{Name: "vec2", Value: testVectorBytes(t, 0, 2)},
+		},
+		Fields: []string{"age"},
+		Ranker: `{"type": "WeightedRanker", "params": [0.7, 0.3]}`,
+	}
+	e.prepare(req)
+	if !reflect.DeepEqual(e.weights, []float64{0.7, 0.3}) {
+		t.Fatalf("weights = %v, want [0.7 0.3]", e.weights)
+	}
+	if !reflect.DeepEqual(req.Fields, []string{"age", "vec1", "vec2"}) {
+		t.Fatalf("Fields = %v, want the vector fields fetched", req.Fields)
+	}
+
+	item := &vearchpb.ResultItem{
+		PKey:  "1",
+		Score: 5.1,
+		Fields: []*vearchpb.Field{
+			{Name: "vec1", Value: testVectorBytes(t, 3, 4)},
+			{Name: "vec2", Value: testVectorBytes(t, 1, 5)},
+		},
+		Source: []byte(`{"age": 1, "vec1": [3, 4], "vec2": [1, 5]}`),
+	}
+	e.explainHits(req, []*vearchpb.SearchResult{{ResultItems: []*vearchpb.ResultItem{item}}})
+
+	source := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(item.Source, &source); err != nil {
+		t.Fatalf("unmarshal source err: %v", err)
+	}
+	if _, ok := source["vec1"]; ok {
+		t.Errorf("source %s keeps vector field fetched for explain", item.Source)
+	}
+	if _, ok := source["vec2"]; ok {
+		t.Errorf("source %s keeps vector field fetched for explain", item.Source)
+	}
+	if string(source["age"]) != "1" {
+		t.Errorf("source %s lost age", item.Source)
+	}
+	explain := &struct {
+		SearchScore float64               `json:"search_score"`
+		Vectors     []*vectorScoreExplain `json:"vectors"`
+	}{}
+	if err := json.Unmarshal(source[ExplainField], explain); err != nil {
+		t.Fatalf("unmarshal explain err: %v", err)
+	}
+	if explain.SearchScore != 5.1 || len(explain.Vectors) != 2 {
+		t.Fatalf("explain = %s, want search_score 5.1 and 2 vectors", source[ExplainField])
+	}
+	want := []vectorScoreExplain{
+		{Field: "vec1", Score: 3, Weight: 0.7, Contribution: 2.1},
+		{Field: "vec2", Score: 10, Weight: 0.3, Contribution: 3},
+	}
+	var sum float64
+	for i, v := range explain.Vectors {
+		if v.Field != want[i].Field || v.Score != want[i].Score || v.Weight != want[i].Weight || math.Abs(v.Contribution-want[i].Contribution) > 1e-9 {
+			t.Errorf("vectors[%d] = %+v, want %+v", i, *v, want[i])
+		}
+		sum += v.Contribution
+	}
+	if math.Abs(sum-explain.SearchScore) > 1e-9 {
+		t.Errorf("sum of contributions = %v, want search_score %v", sum, explain.SearchScore)
+	}
+}
+
+func TestExplainFieldAskedKept(t *testing.T) {
+	e := testExplainer(t, "InnerProduct")
+	req := &vearchpb.SearchRequest{
+		VecFields: []*vearchpb.VectorQuery{{Name: "vec1"}, {Name: "vec2"}},
+		Fields:    []string{"vec1"},
+	}
+	e.prepare(req)
+	if !reflect.DeepEqual(req.Fields, []string{"vec1", "vec2"}) {
+		t.Fatalf("Fields = %v, want [vec1 vec2]", req.Fields)
+	}
+	item := &vearchpb.ResultItem{Source: []byte(`{"vec1": [3, 4], "vec2": [1, 5]}`)}
+	e.setSource(item, map[string]interface{}{"search_score": 1})
+	source := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(item.Source, &source); err != nil {
+		t.Fatalf("unmarshal source err: %v", err)
+	}
+	if _, ok := source["vec1"]; !ok {
+		t.Errorf("source %s lost vec1 asked by the search", item.Source)
+	}
+	if _, ok := source["vec2"]; ok {
+		t.Errorf("source %s keeps vec2 fetched for explain", item.Source)
+	}
+}
+
+func TestExplainVectorScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		metricType string
+		l2Sqrt     bool
+		want       float64
+	}{
+		{name: "L2", metricType: "L2", want: 25},
+		{name: "L2 sqrt", metricType: "L2", l2Sqrt: true, want: 5},
+		{name: "Inner product", metricType: "InnerProduct", want: 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testExplainer(t, tt.metricType)
+			// the second query of the request is scored
+			query := []float32{9, 9, 0, 2}
+			item := &vearchpb.ResultItem{Fields: []*vearchpb.Field{{Name: "vec1", Value: testVectorBytes(t, 3, 6)}}}
+			got, ok := e.vectorScore("vec1", query, 1, item, tt.l2Sqrt)
+			if !ok || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("vectorScore() = %v, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+
+	e := testExplainer(t, "L2")
+	item := &vearchpb.ResultItem{Fields: []*vearchpb.Field{{Name: "vec1", Value: testVectorBytes(t, 3, 4, 5)}}}
+	if _, ok := e.vectorScore("vec1", []float32{0, 0}, 0, item, false); ok {
+		t.Errorf("vectorScore() of vector not of dimension should fail")
+	}
+	if _, ok := e.vectorScore("vec1", []float32{0, 0}, 1, item, false); ok {
+		t.Errorf("vectorScore() of query out of range should fail")
+	}
+}
+
+func TestExplainFilters(t *testing.T) {
+	e := testExplainer(t, "L2")
+	explain := make(map[string]interface{})
+	e.filtersExplain(explain, []*vearchpb.RangeFilter{
+		{Field: "age", LowerValue: cbbytes.Int32ToByte(10), UpperValue: cbbytes.Int32ToByte(20), IncludeLower: true},
+		{Field: "price", LowerValue: cbbytes.Float64ToByteNew(1.5), UpperValue: cbbytes.Float64ToByteNew(9.5), IncludeUpper: true},
+	}, []*vearchpb.TermFilter{
+		{Field: "city", Value: []byte("bj\001sh"), IsUnion: 0},
+		{Field: "city", Value: []byte("bj"), IsUnion: 1},
+		{Field: "city", Value: []byte("gz\001sz"), IsUnion: 2},
+	})
+
+	ranges := explain["range_filters"].([]*rangeFilterExplain)
+	wantRanges := []*rangeFilterExplain{
+		{Field: "age", Lower: int32(10), Upper: int32(20), IncludeLower: true},
+		{Field: "price", Lower: 1.5, Upper: 9.5, IncludeUpper: true},
+	}
+	if !reflect.DeepEqual(ranges, wantRanges) {
+		t.Errorf("range_filters = %+v, want %+v", ranges, wantRanges)
+	}
+	terms := explain["term_filters"].([]*termFilterExplain)
+	wantTerms := []*termFilterExplain{
+		{Field: "city", Value: []string{"bj", "sh"}, Operator: "and"},
+		{Field: "city", Value: []string{"bj"}, Operator: "in"},
+		{Field: "city", Value: []string{"gz", "sz"}, Operator: "not in"},
+	}
+	if !reflect.DeepEqual(terms, wantTerms) {
+		t.Errorf("term_filters = %+v, want %+v", terms, wantTerms)
+	}
+}
+
+func TestRangeBound(t *testing.T) {
+	tests := []struct {
+		name      string
+		fieldType vearchpb.FieldType
+		value     []byte
+		want      interface{}
+	}{
+		{name: "Int", fieldType: vearchpb.FieldType_INT, value: cbbytes.Int32ToByte(-3), want: int32(-3)},
+		{name: "Long", fieldType: vearchpb.FieldType_LONG, value: cbbytes.Int64ToByte(1 << 40), want: int64(1 << 40)},
+		{name: "Date", fieldType: vearchpb.FieldType_DATE, value: cbbytes.Int64ToByte(7), want: int64(7)},
+		{name: "Float", fieldType: vearchpb.FieldType_FLOAT, value: cbbytes.Float32ToByte(0.5), want: float32(0.5)},
+		{name: "Double", fieldType: vearchpb.FieldType_DOUBLE, value: cbbytes.Float64ToByteNew(0.25), want: 0.25},
+		{name: "String", fieldType: vearchpb.FieldType_STRING, value: []byte("a"), want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rangeBound(tt.fieldType, tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rangeBound() = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionExplains(t *testing.T) {
+	tests := []struct {
+		name string
+		head *vearchpb.ResponseHead
+		want []uint32
+	}{
+		{name: "No head", head: nil},
+		{name: "No explain", head: &vearchpb.ResponseHead{Params: map[string]string{}}},
+		{
+			name: "Two partitions",
+			head: &vearchpb.ResponseHead{Params: map[string]string{client.ExplainParam: `[{"partition_id": 1, "candidates": [10]}, {"partition_id": 2, "timeout": true}]`}},
+			want: []uint32{1, 2},
+		},
+		{name: "Bad json", head: &vearchpb.ResponseHead{Params: map[string]string{client.ExplainParam: "["}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explains := partitionExplains(tt.head)
+			if explains == nil {
+				t.Fatalf("partitionExplains() = nil, want a list")
+			}
+			if len(tt.want) == 0 {
+				if len(explains) != 0 {
+					t.Errorf("partitionExplains() = %v, want empty", explains)
+				}
+				return
+			}
+			ids := make([]uint32, 0, len(explains))
+			for _, pe := range explains {
+				ids = append(ids, pe.PartitionID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) || !explains[1].Timeout || !reflect.DeepEqual(explains[0].Candidates, []int{10}) {
+				t.Errorf("partitionExplains() = %+v, want partitions %v", explains, tt.want)
+			}
+		})
+	}
+}
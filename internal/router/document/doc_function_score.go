@@ -0,0 +1,123 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/expr"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const NowVar = "_now"
+
+// functionScorer blends the vector score of the merged hits with their
+// numeric fields, such as `_score * log1p(popularity)` or a decay of date
+type functionScorer struct {
+	conf   *request.FunctionScore
+	expr   *expr.Expression
+	proMap map[string]*entity.SpaceProperties
+	limit  int32
+	fields []string
+	added  []string // fields not asked by the search
+}
+
+func newFunctionScorer(searchDoc *request.SearchDocumentRequest, space *entity.Space) (*functionScorer, error) {
+	conf := searchDoc.FunctionScore
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		proMap, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	fs := &functionScorer{conf: conf, proMap: proMap, limit: searchDoc.Limit}
+	if fs.limit <= 0 {
+		fs.limit = DefaultSize
+	}
+	if conf.TopK < 0 || conf.TopK > maxRerankTopK {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("function_score top_k should be in [0, %d]", maxRerankTopK))
+	}
+
+	e, err := expr.Parse(conf.Expression)
+	if err != nil {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("function_score %s", err.Error()))
+	}
+	for _, name := range e.Vars() {
+		if name == ScoreVar || name == NowVar {
+			continue
+		}
+		if pro := proMap[name]; pro == nil || !isNumericField(pro.FieldType) {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("function_score expression variable:[%s] should be _score, _now or numeric field of space", name))
+		}
+		fs.fields = append(fs.fields, name)
+	}
+	for name := range conf.Missing {
+		if pro := proMap[name]; pro == nil || !isNumericField(pro.FieldType) {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("function_score missing field:[%s] should be numeric field of space", name))
+		}
+	}
+	fs.expr = e
+	return fs, nil
+}
+
+// prepare fetches top_k candidates with the fields the expression needs
+func (fs *functionScorer) prepare(req *vearchpb.SearchRequest) {
+	fs.added = fetchCandidates(req, fs.conf.TopK, fs.fields)
+}
+
+// score replaces the score of every hit and reorders the hits by it, the
+// results are truncated to limit if truncate is set, the rerank after it
+// truncates them otherwise
+func (fs *functionScorer) score(results []*vearchpb.SearchResult, truncate bool) error {
+	defer monitor.Profiler("functionScore", time.Now())
+
+	now := float64(time.Now().Unix())
+	for _, result := range results {
+		if result == nil || len(result.ResultItems) == 0 {
+			continue
+		}
+		items := result.ResultItems
+		scores := make([]float64, len(items))
+		for i, item := range items {
+			vars := itemVars(item, fs.proMap)
+			score, err := fs.expr.Eval(func(name string) (float64, bool) {
+				if name == NowVar {
+					return now, true
+				}
+				if v, ok := vars(name); ok {
+					return v, true
+				}
+				v, ok := fs.conf.Missing[name]
+				return v, ok
+			})
+			if err != nil {
+				return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("function_score of document [%s] err: %s", item.PKey, err.Error()))
+			}
+			scores[i] = score
+		}
+		for i, item := range items {
+			item.Score = scores[i]
+		}
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Score > items[j].Score })
+		result.MaxScore = items[0].Score
+		if truncate && len(items) > int(fs.limit) {
+			result.ResultItems = items[:fs.limit]
+		}
+	}
+	return nil
+}
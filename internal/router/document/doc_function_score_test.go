@@ -0,0 +1,108 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func TestFunctionScoreDateDecay(t *testing.T) {
+	fields := `[{"name": "published", "type": "date"}]`
+	space := &entity.Space{Fields: []byte(fields), SpaceProperties: testProperties(t, fields)}
+	searchDoc := &request.SearchDocumentRequest{
+		Limit: 10,
+		FunctionScore: &request.FunctionScore{
+			// halves the score every day of age
+			Expression: "_score * exp(-0.6931471805599453 * (_now - published) / 86400)",
+		},
+	}
+	fs, err := newFunctionScorer(searchDoc, space)
+	if err != nil {
+		t.Fatalf("newFunctionScorer() error = %v", err)
+	}
+
+	now := time.Now()
+	ages := map[string]time.Duration{"old": 48 * time.Hour, "new": time.Hour, "day": 24 * time.Hour}
+	items := make([]*vearchpb.ResultItem, 0, len(ages))
+	for key, age := range ages {
+		// the date is parsed as documents are written
+		field, err := processString(space.SpaceProperties["published"], "published", now.Add(-age).UTC().Format(time.RFC3339))
+		if err != nil {
+			t.Fatalf("processString() error = %v", err)
+		}
+		items = append(items, &vearchpb.ResultItem{PKey: key, Score: 1, Fields: []*vearchpb.Field{field}})
+	}
+	results := []*vearchpb.SearchResult{{ResultItems: items}}
+	if err := fs.score(results, true); err != nil {
+		t.Fatalf("score() error = %v", err)
+	}
+
+	want := map[string]float64{"new": math.Pow(0.5, 1.0/24), "day": 0.5, "old": 0.25}
+	for i, key := range []string{"new", "day", "old"} {
+		item := results[0].ResultItems[i]
+		if item.PKey != key {
+			t.Fatalf("hit %d = %s, want %s", i, item.PKey, key)
+		}
+		// _now and RFC3339 are whole seconds apart from now
+		if math.Abs(item.Score-want[key]) > 1e-4 {
+			t.Errorf("score of %s = %v, want %v", key, item.Score, want[key])
+		}
+	}
+}
+
+func TestFunctionScoreNullField(t *testing.T) {
+	fields := `[{"name": "popularity", "type": "integer", "nullable": true}]`
+	space := &entity.Space{Fields: []byte(fields), SpaceProperties: testProperties(t, fields)}
+	searchDoc := &request.SearchDocumentRequest{
+		Limit: 10,
+		FunctionScore: &request.FunctionScore{
+			Expression: "_score + popularity",
+			Missing:    map[string]float64{"popularity": 5},
+		},
+	}
+	fs, err := newFunctionScorer(searchDoc, space)
+	if err != nil {
+		t.Fatalf("newFunctionScorer() error = %v", err)
+	}
+
+	pro := space.SpaceProperties["popularity"]
+	items := []*vearchpb.ResultItem{
+		{PKey: "set", Score: 1, Fields: []*vearchpb.Field{{Name: "popularity", Value: cbbytes.Int32ToByte(3)}}},
+		// the null sentinel takes the missing value
+		{PKey: "null", Score: 1, Fields: []*vearchpb.Field{{Name: "popularity", Value: pro.NullValue()}}},
+	}
+	results := []*vearchpb.SearchResult{{ResultItems: items}}
+	if err := fs.score(results, true); err != nil {
+		t.Fatalf("score() error = %v", err)
+	}
+
+	want := map[string]float64{"null": 6, "set": 4}
+	for i, key := range []string{"null", "set"} {
+		item := results[0].ResultItems[i]
+		if item.PKey != key {
+			t.Fatalf("hit %d = %s, want %s", i, item.PKey, key)
+		}
+		if item.Score != want[key] {
+			t.Errorf("score of %s = %v, want %v", key, item.Score, want[key])
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -34,6 +35,7 @@ import (
 	"github.com/vearch/vearch/v3/internal/pkg/httphelper"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/netutil"
+	"github.com/vearch/vearch/v3/internal/pkg/server/vearchhttp"
 	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
 	"github.com/vearch/vearch/v3/internal/router/document/resp"
 )
@@ -49,28 +51,39 @@ const (
 	QueryIsOnlyID       = "QueryIsOnlyID"
 	URLQueryTimeout     = "timeout"
 	URLAliasName        = "alias_name"
+	URLParamUserName    = "user_name"
+	PriorityHeader      = "X-Vearch-Priority"
 )
 
 type DocumentHandler struct {
-	httpServer *gin.Engine
-	docService docService
-	client     *client.Client
+	httpServer   *gin.Engine
+	docService   docService
+	client       *client.Client
+	reindexJobs  sync.Map // job id -> *reindexJob running in this router
+	reindexStore reindexJobStore
+	rateLimits   func(scope, name string) *entity.RateLimit
+	users        vearchhttp.UserSource
+	aliasOf      func(ctx context.Context, name string) (*entity.Alias, error)
 }
 
 func ExportDocumentHandler(httpServer *gin.Engine, client *client.Client) {
 	docService := newDocService(client)
 
 	documentHandler := &DocumentHandler{
-		httpServer: httpServer,
-		docService: *docService,
-		client:     client,
+		httpServer:   httpServer,
+		docService:   *docService,
+		client:       client,
+		reindexStore: client.Master(),
+		rateLimits:   rateLimitsOf(client),
+		users:        client.Master(),
+		aliasOf: func(ctx context.Context, name string) (*entity.Alias, error) {
+			return client.Master().Cache().AliasByCache(ctx, name)
+		},
 	}
 
 	var group *gin.RouterGroup
 	if !config.Conf().Global.SkipAuth {
-		group = documentHandler.httpServer.Group("", documentHandler.handleTimeout, gin.BasicAuth(gin.Accounts{
-			"root": config.Conf().Global.Signkey,
-		}))
+		group = documentHandler.httpServer.Group("", documentHandler.handleTimeout, vearchhttp.Auth(client.Master()))
 	} else {
 		group = documentHandler.httpServer.Group("", documentHandler.handleTimeout)
 	}
@@ -82,35 +95,65 @@ func ExportDocumentHandler(httpServer *gin.Engine, client *client.Client) {
 }
 
 func (handler *DocumentHandler) proxyMaster(group *gin.RouterGroup) error {
+	// master is called as root, so privileges of the user are checked here
+	users := handler.client.Master()
 	// list/*
-	group.GET("/servers", handler.handleMasterRequest)
-	group.GET("/partitions", handler.handleMasterRequest)
-	group.GET("/routers", handler.handleMasterRequest)
+	group.GET("/servers", vearchhttp.Require(users, entity.PrivilegeSelect), handler.handleMasterRequest)
+	group.GET("/partitions", vearchhttp.Require(users, entity.PrivilegeSelect), handler.handleMasterRequest)
+	group.GET("/routers", vearchhttp.Require(users, entity.PrivilegeSelect), handler.handleMasterRequest)
 	// db handler
-	group.POST(fmt.Sprintf("/dbs/:%s", URLParamDbName), handler.handleMasterRequest)
-	group.GET(fmt.Sprintf("/dbs/:%s", URLParamDbName), handler.handleMasterRequest)
-	group.GET("/dbs", handler.handleMasterRequest)
-	group.DELETE(fmt.Sprintf("/dbs/:%s", URLParamDbName), handler.handleMasterRequest)
-	group.PUT(fmt.Sprintf("/dbs/:%s", URLParamDbName), handler.handleMasterRequest)
+	group.POST(fmt.Sprintf("/dbs/:%s", URLParamDbName), vearchhttp.Require(users, entity.PrivilegeCreate), handler.handleMasterRequest)
+	group.GET(fmt.Sprintf("/dbs/:%s", URLParamDbName), vearchhttp.Require(users, entity.PrivilegeSelect, URLParamDbName), handler.handleMasterRequest)
+	group.GET("/dbs", vearchhttp.Require(users, entity.PrivilegeSelect), handler.handleMasterRequest)
+	group.DELETE(fmt.Sprintf("/dbs/:%s", URLParamDbName), vearchhttp.Require(users, entity.PrivilegeDrop, URLParamDbName), handler.handleMasterRequest)
+	group.PUT(fmt.Sprintf("/dbs/:%s", URLParamDbName), vearchhttp.Require(users, entity.PrivilegeAlter, URLParamDbName), handler.handleMasterRequest)
 	// space handler
-	group.POST(fmt.Sprintf("/dbs/:%s/spaces", URLParamDbName), handler.handleMasterRequest)
-	group.GET(fmt.Sprintf("/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
-	group.GET(fmt.Sprintf("/dbs/:%s/spaces", URLParamDbName), handler.handleMasterRequest)
-	group.DELETE(fmt.Sprintf("/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
-	group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
+	group.POST(fmt.Sprintf("/dbs/:%s/spaces", URLParamDbName), vearchhttp.Require(users, entity.PrivilegeCreate, URLParamDbName), handler.handleMasterRequest)
+	group.GET(fmt.Sprintf("/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), vearchhttp.Require(users, entity.PrivilegeSelect, URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
+	group.GET(fmt.Sprintf("/dbs/:%s/spaces", URLParamDbName), vearchhttp.Require(users, entity.PrivilegeSelect, URLParamDbName), handler.handleMasterRequest)
+	group.DELETE(fmt.Sprintf("/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), vearchhttp.Require(users, entity.PrivilegeDrop, URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
+	group.PUT(fmt.Sprintf("/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName), vearchhttp.Require(users, entity.PrivilegeAlter, URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
 	// alias handler
-	group.POST(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", URLAliasName, URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
-	group.GET(fmt.Sprintf("/alias/:%s", URLAliasName), handler.handleMasterRequest)
-	group.GET("/alias", handler.handleMasterRequest)
-	group.DELETE(fmt.Sprintf("/alias/:%s", URLAliasName), handler.handleMasterRequest)
-	group.PUT(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", URLAliasName, URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
+	group.POST(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", URLAliasName, URLParamDbName, URLParamSpaceName), vearchhttp.Require(users, entity.PrivilegeAlter, URLParamDbName, URLParamSpaceName), handler.handleMasterRequest)
+	group.GET(fmt.Sprintf("/alias/:%s", URLAliasName), vearchhttp.Require(users, entity.PrivilegeSelect), handler.handleMasterRequest)
+	group.GET("/alias", vearchhttp.Require(users, entity.PrivilegeSelect), handler.handleMasterRequest)
+	group.DELETE(fmt.Sprintf("/alias/:%s", URLAliasName), handler.requireAlias, handler.handleMasterRequest)
+	group.PUT(fmt.Sprintf("/alias/:%s/dbs/:%s/spaces/:%s", URLAliasName, URLParamDbName, URLParamSpaceName), vearchhttp.Require(users, entity.PrivilegeAlter, URLParamDbName, URLParamSpaceName), handler.requireAlias, handler.handleMasterRequest)
 	// cluster handler
-	group.GET("/cluster/health", handler.handleMasterRequest)
-	group.GET("/cluster/stats", handler.handleMasterRequest)
+	group.GET("/cluster/health", vearchhttp.Require(users, entity.PrivilegeSelect), handler.handleMasterRequest)
+	group.GET("/cluster/stats", vearchhttp.Require(users, entity.PrivilegeSelect), handler.handleMasterRequest)
+	// rate limit handler
+	group.GET("/rate_limits", vearchhttp.Require(users, entity.PrivilegeSelect), handler.handleMasterRequest)
+	for _, path := range []string{
+		fmt.Sprintf("/rate_limits/users/:%s", URLParamUserName),
+		fmt.Sprintf("/rate_limits/dbs/:%s", URLParamDbName),
+		fmt.Sprintf("/rate_limits/dbs/:%s/spaces/:%s", URLParamDbName, URLParamSpaceName),
+	} {
+		group.PUT(path, vearchhttp.Require(users, entity.PrivilegeGrant), handler.handleMasterRequest)
+		group.GET(path, vearchhttp.Require(users, entity.PrivilegeSelect), handler.handleMasterRequest)
+		group.DELETE(path, vearchhttp.Require(users, entity.PrivilegeGrant), handler.handleMasterRequest)
+	}
 
 	return nil
 }
 
+// requireAlias checks alter on the space the alias in path points to, an
+// alias not found is replied 404 as its space can not be checked
+func (handler *DocumentHandler) requireAlias(c *gin.Context) {
+	alias, err := handler.aliasOf(c.Request.Context(), c.Param(URLAliasName))
+	if err != nil {
+		httphelper.New(c).JsonError(errors.NewErrNotFound(vearchpb.NewError(vearchpb.ErrorEnum_ALIAS_NOT_EXIST, err)))
+		c.Abort()
+		return
+	}
+	handler.allow(c, alias.DbName, alias.SpaceName, entity.PrivilegeAlter)
+}
+
+// allow checks the user of request has privi on the resolved space
+func (handler *DocumentHandler) allow(c *gin.Context, dbName, spaceName string, privi entity.UserPrivi) bool {
+	return vearchhttp.Allow(c, handler.users, dbName, spaceName, privi)
+}
+
 func (handler *DocumentHandler) handleMasterRequest(c *gin.Context) {
 	method := c.Request.Method
 	bodyBytes, err := io.ReadAll(c.Request.Body)
@@ -130,19 +173,25 @@ func (handler *DocumentHandler) handleMasterRequest(c *gin.Context) {
 
 func (handler *DocumentHandler) ExportInterfacesToServer(group *gin.RouterGroup) error {
 	// document
-	group.POST("/document/upsert", handler.handleDocumentUpsert)
-	group.POST("/document/query", handler.handleDocumentQuery)
-	group.POST("/document/search", handler.handleDocumentSearch)
-	group.POST("/document/delete", handler.handleDocumentDelete)
+	group.POST("/document/upsert", slowQuery("upsert"), handler.rateLimit, handler.handleDocumentUpsert)
+	group.POST("/document/query", slowQuery("query"), handler.rateLimit, handler.handleDocumentQuery)
+	group.POST("/document/search", slowQuery("search"), handler.rateLimit, handler.handleDocumentSearch)
+	group.POST("/document/delete", slowQuery("delete"), handler.rateLimit, handler.handleDocumentDelete)
 
 	// index
 	group.POST("/index/flush", handler.handleIndexFlush)
 	group.POST("/index/forcemerge", handler.handleIndexForceMerge)
 	group.POST("/index/rebuild", handler.handleIndexRebuild)
 
+	// reindex
+	group.POST("/reindex", handler.handleReindex)
+	group.GET("/reindex", vearchhttp.Require(handler.client.Master(), entity.PrivilegeSelect), handler.handleReindexList)
+	group.GET(fmt.Sprintf("/reindex/:%s", URLParamJobID), vearchhttp.Require(handler.client.Master(), entity.PrivilegeSelect), handler.handleReindexGet)
+	group.DELETE(fmt.Sprintf("/reindex/:%s", URLParamJobID), vearchhttp.Require(handler.client.Master(), entity.PrivilegeAlter), handler.handleReindexCancel)
+
 	// config
 	// trace: /config/trace
-	group.POST("/config/trace", handler.handleConfigTrace)
+	group.POST("/config/trace", vearchhttp.Require(handler.client.Master(), entity.PrivilegeAlter), handler.handleConfigTrace)
 
 	// cacheInfo
 	// /cache/$dbName/$spaceName
@@ -159,6 +208,9 @@ func (handler *DocumentHandler) handleTimeout(c *gin.Context) {
 func (handler *DocumentHandler) cacheSpaceInfo(c *gin.Context) {
 	dbName := c.Param(URLParamDbName)
 	spaceName := c.Param(URLParamSpaceName)
+	if !handler.allow(c, dbName, spaceName, entity.PrivilegeSelect) {
+		return
+	}
 	if space, err := handler.client.Master().Cache().SpaceByCache(context.Background(), dbName, spaceName); err != nil {
 		httphelper.New(c).JsonError(errors.NewErrInternal(err))
 	} else {
@@ -193,6 +245,9 @@ func setRequestHeadFromGin(c *gin.Context) *vearchpb.RequestHead {
 			head.Params[k] = v[0]
 		}
 	}
+	if priority := c.GetHeader(PriorityHeader); priority != "" && head.Params[client.PriorityParam] == "" {
+		head.Params[client.PriorityParam] = priority
+	}
 
 	if timeout, ok := head.Params["timeout"]; ok {
 		var err error
@@ -248,15 +303,35 @@ func (handler *DocumentHandler) handleDocumentUpsert(c *gin.Context) {
 		httphelper.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	if slow := slowLogOf(c); slow != nil {
+		slow.DbName, slow.SpaceName = args.Head.DbName, args.Head.SpaceName
+		slow.Documents = len(docRequest.Documents)
+	}
+	if !handler.allow(c, args.Head.DbName, args.Head.SpaceName, entity.PrivilegeInsert|entity.PrivilegeUpdate) {
+		return
+	}
+	if !quotaOf(c).allowSpace(c, args.Head.DbName, args.Head.SpaceName, len(docRequest.Documents)) {
+		return
+	}
 
-	err = documentParse(c.Request.Context(), handler, c.Request, docRequest, space, args)
+	rejected, err := documentParse(c.Request.Context(), handler, c.Request, docRequest, space, args)
 	if err != nil {
 		httphelper.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
-	reply := handler.docService.bulk(c.Request.Context(), args)
+	reply := &vearchpb.BulkResponse{Head: newOkHead()}
+	if len(args.Docs) > 0 {
+		reply = handler.docService.bulk(c.Request.Context(), args)
+		getResultCache().invalidate(space)
+	}
+	reply.Items = append(reply.Items, rejected...)
 	result, err := documentUpsertResponse(args, reply)
 	if err != nil {
+		// the documents rejected by parse are reported even if the bulk failed
+		if len(rejected) > 0 {
+			httphelper.New(c).JsonErrorWithData(errors.NewErrUnprocessable(err), rejectedResponse(rejected))
+			return
+		}
 		httphelper.New(c).JsonError(errors.NewErrUnprocessable(err))
 		return
 	}
@@ -295,6 +370,12 @@ func (handler *DocumentHandler) handleDocumentQuery(c *gin.Context) {
 	}
 	// update space name because maybe is alias name
 	searchDoc.SpaceName = args.Head.SpaceName
+	if !handler.allow(c, args.Head.DbName, args.Head.SpaceName, entity.PrivilegeSelect) {
+		return
+	}
+	if !quotaOf(c).allowSpace(c, args.Head.DbName, args.Head.SpaceName, 0) {
+		return
+	}
 
 	err = queryRequestToPb(searchDoc, space, args)
 	if err != nil {
@@ -317,9 +398,24 @@ func (handler *DocumentHandler) handleDocumentQuery(c *gin.Context) {
 			return
 		}
 	}
+	ex := newExplainer(searchDoc, space, args.Head)
+	slow := slowLogOf(c)
+	slow.setSearch(searchDoc, space, args.Head, nil)
+
+	var cacheKey string
+	cache := getResultCache()
+	if cacheable(searchDoc, ex) {
+		cacheKey = cache.queryKey(space, args)
+		if result := cache.get(cacheOpQuery, cacheKey); result != nil {
+			httphelper.New(c).JsonSuccess(result)
+			return
+		}
+	}
+
 	serviceStart := time.Now()
 	searchResp := handler.docService.query(c.Request.Context(), args)
 	serviceCost := time.Since(serviceStart)
+	slow.setPartitions(searchResp.Head)
 
 	result, err := documentQueryResponse(searchResp.Results, searchResp.Head)
 
@@ -327,6 +423,16 @@ func (handler *DocumentHandler) handleDocumentQuery(c *gin.Context) {
 		httphelper.New(c).JsonError(errors.NewErrUnprocessable(err))
 		return
 	}
+	// a partial result is not cached
+	failures := failedPartitions(searchResp.Head)
+	if failures != nil {
+		result["failed_partitions"] = failures
+	}
+	if ex != nil {
+		result["explain"] = ex.queryExplain(args, searchResp.Head)
+	} else if failures == nil && (searchResp.Head == nil || searchResp.Head.Err == nil || searchResp.Head.Err.Code == vearchpb.ErrorEnum_SUCCESS) {
+		cache.set(cacheKey, result)
+	}
 	httphelper.New(c).JsonSuccess(result)
 	if trace {
 		log.Trace("handleDocumentQuery total use :[%.4f] service use :[%.4f] detail use :[%v]", time.Since(startTime).Seconds()*1000, serviceCost.Seconds()*1000, searchResp.Head.Params)
@@ -344,6 +450,10 @@ func (handler *DocumentHandler) handleDocumentGet(c *gin.Context, searchDoc *req
 	args.Head.DbName = searchDoc.DbName
 	args.Head.SpaceName = searchDoc.SpaceName
 	args.PrimaryKeys = *searchDoc.DocumentIds
+	if err := setConsistency(searchDoc, args.Head); err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
 
 	var queryFieldsParam map[string]string
 	if searchDoc.Fields != nil {
@@ -399,8 +509,14 @@ func (handler *DocumentHandler) handleDocumentSearch(c *gin.Context) {
 	// update space name because maybe is alias name
 	searchDoc.SpaceName = args.Head.SpaceName
 	getSpaceCost := time.Since(getSpaceStart)
+	if !handler.allow(c, args.Head.DbName, args.Head.SpaceName, entity.PrivilegeSelect) {
+		return
+	}
+	if !quotaOf(c).allowSpace(c, args.Head.DbName, args.Head.SpaceName, 0) {
+		return
+	}
 
-	err = requestToPb(searchDoc, space, args)
+	err = requestToPb(c.Request.Context(), searchDoc, space, args)
 	if err != nil {
 		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
 		return
@@ -412,9 +528,63 @@ func (handler *DocumentHandler) handleDocumentSearch(c *gin.Context) {
 		return
 	}
 
+	ex := newExplainer(searchDoc, space, args.Head)
+	if ex != nil {
+		ex.prepare(args)
+	}
+	slow := slowLogOf(c)
+	slow.setSearch(searchDoc, space, args.Head, args.VecFields)
+	var fs *functionScorer
+	if searchDoc.FunctionScore != nil {
+		if fs, err = newFunctionScorer(searchDoc, space); err != nil {
+			httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+			return
+		}
+		fs.prepare(args)
+	}
+	var rr *reranker
+	if searchDoc.Rerank != nil {
+		if rr, err = newReranker(searchDoc, space); err != nil {
+			httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+			return
+		}
+		rr.prepare(args)
+	}
+
+	var cacheKey string
+	cache := getResultCache()
+	if cacheable(searchDoc, ex) {
+		cacheKey = cache.searchKey(searchDoc, space, args)
+		if result := cache.get(cacheOpSearch, cacheKey); result != nil {
+			httphelper.New(c).JsonSuccess(result)
+			return
+		}
+	}
+
 	serviceStart := time.Now()
 	searchResp := handler.docService.search(ctx, args)
 	serviceCost := time.Since(serviceStart)
+	slow.setPartitions(searchResp.Head)
+
+	rerankFallback := false
+	if searchResp.Head == nil || searchResp.Head.Err == nil || searchResp.Head.Err.Code == vearchpb.ErrorEnum_SUCCESS {
+		if ex != nil {
+			ex.explainHits(args, searchResp.Results)
+		}
+		var added []string
+		if fs != nil {
+			if err = fs.score(searchResp.Results, rr == nil); err != nil {
+				httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+				return
+			}
+			added = append(added, fs.added...)
+		}
+		if rr != nil {
+			rerankFallback = rr.rerank(ctx, searchResp.Results)
+			added = append(added, rr.added...)
+		}
+		dropSourceFields(searchResp.Results, added)
+	}
 
 	result, err := documentSearchResponse(searchResp.Results, searchResp.Head)
 
@@ -422,6 +592,20 @@ func (handler *DocumentHandler) handleDocumentSearch(c *gin.Context) {
 		httphelper.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	// a partial result is not cached
+	failures := failedPartitions(searchResp.Head)
+	if failures != nil {
+		result["failed_partitions"] = failures
+	}
+	// a result in search order when rerank failed is not cached either
+	if rerankFallback {
+		result["rerank_fallback"] = true
+	}
+	if ex != nil {
+		result["explain"] = ex.searchExplain(args, searchResp.Head)
+	} else if failures == nil && !rerankFallback && (searchResp.Head == nil || searchResp.Head.Err == nil || searchResp.Head.Err.Code == vearchpb.ErrorEnum_SUCCESS) {
+		cache.set(cacheKey, result)
+	}
 	httphelper.New(c).JsonSuccess(result)
 	if trace {
 		log.Trace("handleDocumentSearch total use :[%.4f] getSpace use :[%.4f] service use :[%.4f] detail use :[%v]",
@@ -459,7 +643,20 @@ func (handler *DocumentHandler) handleDocumentDelete(c *gin.Context) {
 	// update space name because maybe is alias name
 	searchDoc.SpaceName = args.Head.SpaceName
 
-	err = requestToPb(searchDoc, space, args)
+	deleteDocs := 1
+	if searchDoc.DocumentIds != nil && len(*searchDoc.DocumentIds) != 0 {
+		deleteDocs = len(*searchDoc.DocumentIds)
+	} else if searchDoc.Limit > 0 {
+		deleteDocs = int(searchDoc.Limit)
+	}
+	if !handler.allow(c, args.Head.DbName, args.Head.SpaceName, entity.PrivilegeDelete) {
+		return
+	}
+	if !quotaOf(c).allowSpace(c, args.Head.DbName, args.Head.SpaceName, deleteDocs) {
+		return
+	}
+
+	err = requestToPb(c.Request.Context(), searchDoc, space, args)
 	if err != nil {
 		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
 		return
@@ -470,6 +667,7 @@ func (handler *DocumentHandler) handleDocumentDelete(c *gin.Context) {
 		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
 		return
 	}
+	slowLogOf(c).setSearch(searchDoc, space, args.Head, nil)
 
 	if searchDoc.DocumentIds != nil && len(*searchDoc.DocumentIds) != 0 {
 		if args.TermFilters != nil || args.RangeFilters != nil {
@@ -489,6 +687,7 @@ func (handler *DocumentHandler) handleDocumentDelete(c *gin.Context) {
 		args.PrimaryKeys = *searchDoc.DocumentIds
 		var resultIds []string
 		reply := handler.docService.deleteDocs(c.Request.Context(), args)
+		getResultCache().invalidate(space)
 		if result, err := documentDeleteResponse(reply.Items, reply.Head, resultIds); err != nil {
 			httphelper.New(c).JsonError(errors.NewErrInternal(err))
 			return
@@ -505,6 +704,7 @@ func (handler *DocumentHandler) handleDocumentDelete(c *gin.Context) {
 	}
 	serviceStart := time.Now()
 	delByQueryResp := handler.docService.deleteByQuery(c.Request.Context(), args)
+	getResultCache().invalidate(space)
 	serviceCost := time.Since(serviceStart)
 
 	result, err := deleteByQueryResult(delByQueryResp)
@@ -542,6 +742,9 @@ func (handler *DocumentHandler) handleIndexFlush(c *gin.Context) {
 		httphelper.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	if !handler.allow(c, args.Head.DbName, args.Head.SpaceName, entity.PrivilegeAlter) {
+		return
+	}
 	flushResponse := handler.docService.flush(c.Request.Context(), args)
 	result := IndexResponseToContent(flushResponse.Shards)
 	httphelper.New(c).JsonSuccess(result)
@@ -568,6 +771,9 @@ func (handler *DocumentHandler) handleIndexForceMerge(c *gin.Context) {
 		httphelper.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	if !handler.allow(c, args.Head.DbName, args.Head.SpaceName, entity.PrivilegeAlter) {
+		return
+	}
 
 	forceMergeResponse := handler.docService.forceMerge(c.Request.Context(), args)
 	result := IndexResponseToContent(forceMergeResponse.Shards)
@@ -603,6 +809,9 @@ func (handler *DocumentHandler) handleIndexRebuild(c *gin.Context) {
 		httphelper.New(c).JsonError(errors.NewErrInternal(err))
 		return
 	}
+	if !handler.allow(c, args.Head.DbName, args.Head.SpaceName, entity.PrivilegeAlter) {
+		return
+	}
 
 	indexResponse := handler.docService.rebuildIndex(c.Request.Context(), args)
 	result := IndexResponseToContent(indexResponse.Shards)
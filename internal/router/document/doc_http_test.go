@@ -0,0 +1,161 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/pkg/server/vearchhttp"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// fakeUsers grants the privileges of "db/space" to every user
+type fakeUsers map[string]entity.UserPrivi
+
+func (u fakeUsers) Authenticate(ctx context.Context, name, password string) (*entity.User, error) {
+	return &entity.User{Name: name}, nil
+}
+
+func (u fakeUsers) AuthenticateToken(ctx context.Context, token string) (*entity.User, *entity.ApiKey, error) {
+	return nil, nil, fmt.Errorf("token not supported")
+}
+
+func (u fakeUsers) PriviOn(ctx context.Context, user *entity.User, dbName, spaceName string) entity.UserPrivi {
+	return u[dbName+"/"+spaceName]
+}
+
+// testUserContext is a request of path by a user authenticated
+func testUserContext(method, path string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, path, nil)
+	c.Set(vearchhttp.UserKey, &entity.User{Name: "user"})
+	return c, w
+}
+
+func TestRequireAlias(t *testing.T) {
+	handler := &DocumentHandler{
+		users: fakeUsers{"db/s1": entity.PrivilegeAlter, "db/s2": entity.PrivilegeSelect},
+		aliasOf: func(ctx context.Context, name string) (*entity.Alias, error) {
+			switch name {
+			case "a1":
+				return &entity.Alias{Name: name, DbName: "db", SpaceName: "s1"}, nil
+			case "a2":
+				return &entity.Alias{Name: name, DbName: "db", SpaceName: "s2"}, nil
+			}
+			return nil, fmt.Errorf("alias_name:[%s] not found", name)
+		},
+	}
+	tests := []struct {
+		name     string
+		alias    string
+		user     bool
+		wantCode int
+	}{
+		{name: "Alter on space", alias: "a1", user: true, wantCode: http.StatusOK},
+		{name: "No alter on space", alias: "a2", user: true, wantCode: http.StatusForbidden},
+		{name: "Alias not found", alias: "none", user: true, wantCode: http.StatusNotFound},
+		{name: "Alias not found without auth", alias: "none", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := testUserContext(http.MethodDelete, "/alias/"+tt.alias)
+			if !tt.user {
+				delete(c.Keys, vearchhttp.UserKey)
+			}
+			c.Params = gin.Params{{Key: URLAliasName, Value: tt.alias}}
+			handler.requireAlias(c)
+			if tt.wantCode == http.StatusOK {
+				if c.IsAborted() {
+					t.Fatalf("requireAlias() aborted with %d, body %s", w.Code, w.Body.String())
+				}
+				return
+			}
+			if !c.IsAborted() || w.Code != tt.wantCode {
+				t.Fatalf("requireAlias() aborted %v code = %d, want %d", c.IsAborted(), w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestFailedPartitions(t *testing.T) {
+	failed := `[{"partition_id":2,"node_id":1,"code":"TIMEOUT","msg":"timeout"}]`
+	tests := []struct {
+		name string
+		head *vearchpb.ResponseHead
+		want []uint32
+	}{
+		{name: "No head", head: nil},
+		{name: "All partitions answered", head: &vearchpb.ResponseHead{Params: map[string]string{}}},
+		{name: "Some partitions failed", head: &vearchpb.ResponseHead{Params: map[string]string{client.FailedPartitionsParam: failed}}, want: []uint32{2}},
+		{name: "Bad json", head: &vearchpb.ResponseHead{Params: map[string]string{client.FailedPartitionsParam: "{"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := failedPartitions(tt.head)
+			if len(failures) != len(tt.want) {
+				t.Fatalf("failedPartitions() = %v, want %v", failures, tt.want)
+			}
+			for i, f := range failures {
+				if f.PartitionID != tt.want[i] || f.Code != "TIMEOUT" {
+					t.Errorf("failedPartitions()[%d] = %+v, want partition %d", i, f, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUpsertRejected(t *testing.T) {
+	rejected := []*vearchpb.Item{{
+		Doc: &vearchpb.Document{PKey: "bad"},
+		Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_PARAM_ERROR, Msg: "field:[age] is required"},
+	}}
+	args := &vearchpb.BulkRequest{Docs: []*vearchpb.Document{{PKey: "good"}}}
+
+	// the bulk of the other documents failed
+	reply := &vearchpb.BulkResponse{Head: &vearchpb.ResponseHead{Err: &vearchpb.Error{Code: vearchpb.ErrorEnum_TIMEOUT, Msg: "ps timeout"}}}
+	reply.Items = append(reply.Items, rejected...)
+	if _, err := documentUpsertResponse(args, reply); err == nil || !strings.Contains(err.Error(), "ps timeout") {
+		t.Fatalf("documentUpsertResponse() err = %v, want the bulk error", err)
+	}
+	result := rejectedResponse(rejected)
+	documentIDs := result["document_ids"].([]interface{})
+	if len(documentIDs) != 1 || documentIDs[0].(map[string]interface{})["_id"] != "bad" {
+		t.Fatalf("rejectedResponse() = %v, want the rejected document", result)
+	}
+	if code := documentIDs[0].(map[string]interface{})["code"]; code != http.StatusBadRequest {
+		t.Errorf("rejectedResponse() code = %v, want %d", code, http.StatusBadRequest)
+	}
+
+	// the bulk succeeded
+	reply = &vearchpb.BulkResponse{Head: newOkHead(), Items: []*vearchpb.Item{{Doc: &vearchpb.Document{PKey: "good"}}}}
+	reply.Items = append(reply.Items, rejected...)
+	result, err := documentUpsertResponse(args, reply)
+	if err != nil {
+		t.Fatalf("documentUpsertResponse() err = %v", err)
+	}
+	if result["total"] != int64(1) || len(result["document_ids"].([]interface{})) != 2 {
+		t.Errorf("documentUpsertResponse() = %v, want 1 of 2 documents written", result)
+	}
+}
@@ -272,6 +272,9 @@ func processProperty(docVal *DocVal, v *fastjson.Value, indexType string, pro *e
 	}
 
 	if v.Type() == fastjson.TypeNull {
+		if pro != nil && pro.Nullable {
+			return processNull(pro, fieldName)
+		}
 		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field name [%s]  type is null", fieldName))
 	}
 
@@ -290,6 +293,11 @@ func processProperty(docVal *DocVal, v *fastjson.Value, indexType string, pro *e
 	case fastjson.TypeArray:
 		field, err = processPropertyArray(v, pathString, pro, fieldName, indexType)
 	}
+	// null is stored as a sentinel, the same value can not be written or it
+	// reads back as null
+	if err == nil && field != nil && pro != nil && pro.IsNull(field.Value) {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("value of field [%s] is reserved for null of nullable field", fieldName))
+	}
 	return field, err
 }
 
@@ -309,6 +317,64 @@ func processField(fieldName string, fieldType vearchpb.FieldType, value []byte,
 	return field, nil
 }
 
+func processNull(pro *entity.SpaceProperties, fieldName string) (*vearchpb.Field, error) {
+	opt := vearchpb.FieldOption_Null
+	if pro.Option == 1 {
+		opt = vearchpb.FieldOption_Index
+	}
+	return processField(fieldName, pro.FieldType, pro.NullValue(), opt)
+}
+
+// fillDocument completes a document with the schema constraints of the space:
+// missing ttl field is set by the default ttl of space, missing required fields
+// are rejected, missing fields with default value are set to the default and
+// other missing nullable fields are set to null. Fields in stored, the stored
+// document a partial update applies to, are not missing.
+func fillDocument(docIdx int, fields []*vearchpb.Field, stored []*vearchpb.Field, space *entity.Space, proMap map[string]*entity.SpaceProperties) ([]*vearchpb.Field, error) {
+	exists := make(map[string]bool, len(fields)+len(stored))
+	for _, field := range append(stored, fields...) {
+		if field != nil {
+			exists[field.Name] = true
+		}
+	}
+	for name, pro := range proMap {
+		if exists[name] {
+			continue
+		}
+		if space.TTL != nil && space.TTL.Default > 0 && name == space.TTL.Field {
+			expireAt := time.Now().Add(time.Duration(space.TTL.Default) * time.Second)
+			field, err := processField(name, vearchpb.FieldType_DATE, cbbytes.Int64ToByte(expireAt.UnixNano()), vearchpb.FieldOption_Index)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, field)
+			continue
+		}
+		if pro.Required {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document[%d] required field [%s] is missing", docIdx, name))
+		}
+		if pro.Default != nil {
+			var fast fastjson.Parser
+			v, err := fast.ParseBytes(pro.Default)
+			if err != nil {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document[%d] field [%s] default value %s err: %v", docIdx, name, string(pro.Default), err))
+			}
+			field, err := processProperty(&DocVal{FieldName: name}, v, space.Index.Type, pro)
+			if err != nil {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document[%d] field [%s] default value %s err: %v", docIdx, name, string(pro.Default), err))
+			}
+			fields = append(fields, field)
+		} else if pro.Nullable {
+			field, err := processNull(pro, name)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
 func processString(pro *entity.SpaceProperties, fieldName, val string) (*vearchpb.Field, error) {
 	opt := vearchpb.FieldOption_Null
 	if pro.Option == 1 {
@@ -553,7 +619,9 @@ func documentHeadParse(r *http.Request) (docRequest *request.DocumentRequest, db
 	return docRequest, docRequest.DbName, docRequest.SpaceName, nil
 }
 
-func documentParse(ctx context.Context, handler *DocumentHandler, r *http.Request, docRequest *request.DocumentRequest, space *entity.Space, args *vearchpb.BulkRequest) (err error) {
+// documentParse parses the documents of request to args.Docs, a document
+// failed to parse is returned as a rejected item and the others go on
+func documentParse(ctx context.Context, handler *DocumentHandler, r *http.Request, docRequest *request.DocumentRequest, space *entity.Space, args *vearchpb.BulkRequest) (rejected []*vearchpb.Item, err error) {
 	spaceProperties := space.SpaceProperties
 	if spaceProperties == nil {
 		spaceProperties, _ = entity.UnmarshalPropertyJSON(space.Fields)
@@ -564,61 +632,83 @@ func documentParse(ctx context.Context, handler *DocumentHandler, r *http.Reques
 			vectorFieldNum += 1
 		}
 	}
+	if err = embedDocuments(ctx, docRequest.Documents, space, spaceProperties); err != nil {
+		return nil, err
+	}
 	docs := make([]*vearchpb.Document, 0)
-	for _, docJson := range docRequest.Documents {
-		jsonMap, err := vjson.ByteToJsonMap(docJson)
+	for i, docJson := range docRequest.Documents {
+		doc, err := parseDocument(ctx, handler, r, i, docJson, space, spaceProperties, vectorFieldNum, args.Head)
 		if err != nil {
-			return err
+			vErr, ok := err.(*vearchpb.VearchErr)
+			if !ok {
+				vErr = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, err)
+			}
+			rejected = append(rejected, &vearchpb.Item{Doc: doc, Err: vErr.GetError()})
+			continue
 		}
-		primaryKey := jsonMap.GetJsonValString(IDField)
+		docs = append(docs, doc)
+	}
+	args.Docs = docs
+	if len(args.Docs) == 0 && len(rejected) == 0 {
+		err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("empty documents, should set at least one document"))
+		return nil, err
+	}
+	return rejected, nil
+}
 
-		fields, haveVector, err := MapDocument(docJson, space, spaceProperties)
-		if err != nil {
-			return err
-		}
+// parseDocument parses the document of index docIdx, the returned document
+// carries the primary key even if it failed
+func parseDocument(ctx context.Context, handler *DocumentHandler, r *http.Request, docIdx int, docJson []byte, space *entity.Space,
+	spaceProperties map[string]*entity.SpaceProperties, vectorFieldNum int, head *vearchpb.RequestHead) (*vearchpb.Document, error) {
+	doc := &vearchpb.Document{}
+	jsonMap, err := vjson.ByteToJsonMap(docJson)
+	if err != nil {
+		return doc, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document[%d] err: %v", docIdx, err))
+	}
+	doc.PKey = jsonMap.GetJsonValString(IDField)
 
-		if haveVector != vectorFieldNum {
-			if primaryKey == "" {
-				err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field num:%d is not equal to vector num of space fields:%d and document_id is empty", haveVector, vectorFieldNum))
-				return err
-			}
-			arg := &vearchpb.GetRequest{}
-			uriParams := make(map[string]string)
-			uriParams["db_name"] = args.Head.DbName
-			uriParams["space_name"] = args.Head.SpaceName
-			uriParams["_id"] = primaryKey
-			uriParamsMap := netutil.NewMockUriParams(uriParams)
-			arg.Head = setRequestHead(uriParamsMap, r)
-			arg.PrimaryKeys = make([]string, 1)
-			arg.PrimaryKeys[0] = primaryKey
-			reply := handler.docService.getDocs(ctx, arg)
-
-			_, err := docGetResponse(handler.client, arg, reply, nil, false)
-			if err != nil {
-				return err
-			}
+	fields, haveVector, err := MapDocument(docJson, space, spaceProperties)
+	if err != nil {
+		return doc, err
+	}
 
-			err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field num:%d is not equal to vector num of space fields:%d and document_id not exist so can't update", haveVector, vectorFieldNum))
-			if reply == nil {
-				return err
-			}
-			if len(reply.Items) == 0 {
-				return err
-			}
-			if reply.Items[0].Err != nil && reply.Items[0].Err.Code != vearchpb.ErrorEnum_SUCCESS {
-				return err
-			}
+	// a document with all vectors is a full document, a partial update of an
+	// existing document keeps the fields it does not carry, so only the
+	// fields missing in both are completed
+	var stored []*vearchpb.Field
+	if haveVector != vectorFieldNum {
+		if doc.PKey == "" {
+			return doc, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field num:%d is not equal to vector num of space fields:%d and document_id is empty", haveVector, vectorFieldNum))
 		}
-		doc := &vearchpb.Document{PKey: primaryKey, Fields: fields}
+		arg := &vearchpb.GetRequest{}
+		uriParams := make(map[string]string)
+		uriParams["db_name"] = head.DbName
+		uriParams["space_name"] = head.SpaceName
+		uriParams["_id"] = doc.PKey
+		uriParamsMap := netutil.NewMockUriParams(uriParams)
+		arg.Head = setRequestHead(uriParamsMap, r)
+		arg.PrimaryKeys = []string{doc.PKey}
+		reply := handler.docService.getDocs(ctx, arg)
 
-		docs = append(docs, doc)
+		if _, err := docGetResponse(handler.client, arg, reply, nil, false); err != nil {
+			return doc, err
+		}
+
+		err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector field num:%d is not equal to vector num of space fields:%d and document_id not exist so can't update", haveVector, vectorFieldNum))
+		if reply == nil || len(reply.Items) == 0 {
+			return doc, err
+		}
+		if reply.Items[0].Err != nil && reply.Items[0].Err.Code != vearchpb.ErrorEnum_SUCCESS {
+			return doc, err
+		}
+		if reply.Items[0].Doc != nil {
+			stored = reply.Items[0].Doc.Fields
+		}
 	}
-	args.Docs = docs
-	if len(args.Docs) == 0 {
-		err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("empty documents, should set at least one document"))
-		return err
+	if doc.Fields, err = fillDocument(docIdx, fields, stored, space, spaceProperties); err != nil {
+		return doc, err
 	}
-	return nil
+	return doc, nil
 }
 
 func documentRequestParse(r *http.Request) (searchDoc *request.SearchDocumentRequest, err error) {
@@ -0,0 +1,114 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"testing"
+
+	"github.com/valyala/fastjson"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func testProperties(t *testing.T, fields string) map[string]*entity.SpaceProperties {
+	t.Helper()
+	pros, err := entity.UnmarshalPropertyJSON([]byte(fields))
+	if err != nil {
+		t.Fatalf("UnmarshalPropertyJSON() error = %v", err)
+	}
+	return pros
+}
+
+func TestProcessPropertyNullSentinel(t *testing.T) {
+	pros := testProperties(t, `[
+		{"name": "age", "type": "integer", "nullable": true},
+		{"name": "count", "type": "integer"}
+	]`)
+	tests := []struct {
+		name    string
+		field   string
+		value   string
+		wantErr bool
+	}{
+		{name: "Null of nullable", field: "age", value: `null`},
+		{name: "Value of nullable", field: "age", value: `18`},
+		{name: "Sentinel of nullable", field: "age", value: `-2147483648`, wantErr: true},
+		{name: "Sentinel of not nullable", field: "count", value: `-2147483648`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fast fastjson.Parser
+			v, err := fast.Parse(tt.value)
+			if err != nil {
+				t.Fatalf("parse %s err: %v", tt.value, err)
+			}
+			_, err = processProperty(&DocVal{FieldName: tt.field}, v, "FLAT", pros[tt.field])
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processProperty() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFillDocument(t *testing.T) {
+	pros := testProperties(t, `[
+		{"name": "title", "type": "string", "required": true},
+		{"name": "lang", "type": "string", "default": "en"},
+		{"name": "age", "type": "integer", "nullable": true}
+	]`)
+	space := &entity.Space{Index: &entity.Index{Type: "FLAT"}}
+	title := &vearchpb.Field{Name: "title", Value: []byte("t")}
+	lang := &vearchpb.Field{Name: "lang", Value: []byte("zh")}
+
+	tests := []struct {
+		name    string
+		fields  []*vearchpb.Field
+		stored  []*vearchpb.Field
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "Full document", fields: []*vearchpb.Field{title}, want: map[string]string{"title": "t", "lang": "en", "age": "null"}},
+		{name: "Missing required", fields: []*vearchpb.Field{lang}, wantErr: true},
+		{name: "Partial with stored required", fields: []*vearchpb.Field{lang}, stored: []*vearchpb.Field{title}, want: map[string]string{"lang": "zh", "age": "null"}},
+		{name: "Partial keeps stored fields", fields: []*vearchpb.Field{title}, stored: []*vearchpb.Field{lang}, want: map[string]string{"title": "t", "age": "null"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := fillDocument(0, tt.fields, tt.stored, space, pros)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fillDocument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := make(map[string]string, len(fields))
+			for _, f := range fields {
+				if pros[f.Name].IsNull(f.Value) {
+					got[f.Name] = "null"
+				} else {
+					got[f.Name] = string(f.Value)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("fillDocument() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Fatalf("fillDocument() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
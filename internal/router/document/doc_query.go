@@ -16,12 +16,15 @@ package document
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cast"
+	"github.com/vearch/vearch/v3/internal/client"
 	"github.com/vearch/vearch/v3/internal/entity"
 	"github.com/vearch/vearch/v3/internal/entity/request"
 	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
@@ -46,6 +49,7 @@ const (
 type VectorQuery struct {
 	Field        string          `json:"field"`
 	FeatureData  json.RawMessage `json:"feature"`
+	Text         json.RawMessage `json:"text,omitempty"`
 	Feature      []float32       `json:"-"`
 	FeatureUint8 []uint8         `json:"-"`
 	Symbol       string          `json:"symbol"`
@@ -144,6 +148,17 @@ func parseFilter(filters *request.Filter, space *entity.Space) ([]*vearchpb.Rang
 				} else {
 					tm.Value = condition.Value
 				}
+			} else if condition.Operator == "IS NULL" || condition.Operator == "IS NOT NULL" {
+				rf, tf, err := parseNull(condition, proMap)
+				if err != nil {
+					return nil, nil, err
+				}
+				if rf != nil {
+					rfs = append(rfs, rf)
+				}
+				if tf != nil {
+					tfs = append(tfs, tf)
+				}
 			} else {
 				return nil, nil, vearchpb.NewError(vearchpb.ErrorEnum_FILTER_CONDITION_OPERATOR_TYPE_ERR, nil)
 			}
@@ -167,7 +182,7 @@ func parseFilter(filters *request.Filter, space *entity.Space) ([]*vearchpb.Rang
 	return rfs, tfs, nil
 }
 
-func parseSearch(vectors []json.RawMessage, filters *request.Filter, req *vearchpb.SearchRequest, space *entity.Space) error {
+func parseSearch(ctx context.Context, vectors []json.RawMessage, filters *request.Filter, req *vearchpb.SearchRequest, space *entity.Space) error {
 	vqs := make([]*vearchpb.VectorQuery, 0)
 
 	var err error
@@ -175,7 +190,7 @@ func parseSearch(vectors []json.RawMessage, filters *request.Filter, req *vearch
 
 	if len(vectors) > 0 {
 		req.MultiVectorRank = 1
-		if reqNum, vqs, err = parseVectors(reqNum, vqs, vectors, space); err != nil {
+		if reqNum, vqs, err = parseVectors(ctx, reqNum, vqs, vectors, space); err != nil {
 			return err
 		}
 	}
@@ -238,7 +253,7 @@ func unmarshalArray[T any](data []byte, dimension int) ([]T, error) {
 	return result, nil
 }
 
-func parseVectors(reqNum int, vqs []*vearchpb.VectorQuery, tmpArr []json.RawMessage, space *entity.Space) (int, []*vearchpb.VectorQuery, error) {
+func parseVectors(ctx context.Context, reqNum int, vqs []*vearchpb.VectorQuery, tmpArr []json.RawMessage, space *entity.Space) (int, []*vearchpb.VectorQuery, error) {
 	var err error
 	indexType := space.Index.Type
 	proMap := space.SpaceProperties
@@ -264,6 +279,12 @@ func parseVectors(reqNum int, vqs []*vearchpb.VectorQuery, tmpArr []json.RawMess
 			return reqNum, vqs, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] is not vector type", vqTemp.Field))
 		}
 
+		if len(vqTemp.FeatureData) == 0 && len(vqTemp.Text) > 0 {
+			if vqTemp.FeatureData, err = embedQueryText(ctx, space, vqTemp.Field, docField.Dimension, vqTemp.Text); err != nil {
+				return reqNum, vqs, err
+			}
+		}
+
 		if vqTemp.FeatureData == nil || len(vqTemp.FeatureData) == 0 {
 			return reqNum, vqs, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("vector embedding is null"))
 		}
@@ -348,6 +369,11 @@ func parseRange(rangeConditionMap map[string]*Range, proMap map[string]*entity.S
 			start = rv.Gt
 		}
 
+		// null value is the minimum of the field type, keep it out of range
+		if start == nil && docField.Nullable {
+			minInclusive = false
+		}
+
 		if rv.Lte != nil {
 			maxInclusive = true
 			end = rv.Lte
@@ -476,6 +502,67 @@ func parseRange(rangeConditionMap map[string]*Range, proMap map[string]*entity.S
 	return rangeFilters, nil
 }
 
+// parseNull translate IS NULL and IS NOT NULL to filters on the null value of the field
+func parseNull(condition request.Condition, proMap map[string]*entity.SpaceProperties) (*vearchpb.RangeFilter, *vearchpb.TermFilter, error) {
+	docField := proMap[condition.Field]
+	if docField == nil {
+		return nil, nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] not found in space fields", condition.Field))
+	}
+	if !docField.Nullable {
+		return nil, nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] is not nullable, can not use %s", condition.Field, condition.Operator))
+	}
+	if docField.Option&entity.FieldOption_Index != entity.FieldOption_Index {
+		return nil, nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] not set index", condition.Field))
+	}
+
+	isNull := condition.Operator == "IS NULL"
+	null := docField.NullValue()
+
+	if docField.FieldType == vearchpb.FieldType_STRING || docField.FieldType == vearchpb.FieldType_STRINGARRAY {
+		termFilter := &vearchpb.TermFilter{
+			Field:   condition.Field,
+			Value:   null,
+			IsUnion: 1,
+		}
+		if !isNull {
+			// not in
+			termFilter.IsUnion = 2
+		}
+		return nil, termFilter, nil
+	}
+
+	if isNull {
+		return &vearchpb.RangeFilter{
+			Field:        condition.Field,
+			LowerValue:   null,
+			UpperValue:   null,
+			IncludeLower: true,
+			IncludeUpper: true,
+		}, nil, nil
+	}
+
+	var max []byte
+	switch docField.FieldType {
+	case vearchpb.FieldType_INT:
+		max = cbbytes.Int32ToByte(math.MaxInt32)
+	case vearchpb.FieldType_LONG, vearchpb.FieldType_DATE:
+		max = cbbytes.Int64ToByte(math.MaxInt64)
+	case vearchpb.FieldType_FLOAT:
+		max = cbbytes.Float32ToByte(math.MaxFloat32)
+	case vearchpb.FieldType_DOUBLE:
+		max = cbbytes.Float64ToByteNew(math.MaxFloat64)
+	default:
+		return nil, nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] type:[%s] not support %s", condition.Field, docField.FieldType, condition.Operator))
+	}
+	return &vearchpb.RangeFilter{
+		Field:        condition.Field,
+		LowerValue:   null,
+		UpperValue:   max,
+		IncludeLower: false,
+		IncludeUpper: true,
+	}, nil, nil
+}
+
 func parseTerm(tm map[string]*Term, proMap map[string]*entity.SpaceProperties) ([]*vearchpb.TermFilter, error) {
 	isUnion := int32(1)
 
@@ -700,10 +787,11 @@ func queryRequestToPb(searchDoc *request.SearchDocumentRequest, space *entity.Sp
 	}
 
 	queryReq.Head.ClientType = searchDoc.LoadBalance
-	return nil
+	setAllowPartialResults(searchDoc, queryReq.Head)
+	return setConsistency(searchDoc, queryReq.Head)
 }
 
-func requestToPb(searchDoc *request.SearchDocumentRequest, space *entity.Space, searchReq *vearchpb.SearchRequest) error {
+func requestToPb(ctx context.Context, searchDoc *request.SearchDocumentRequest, space *entity.Space, searchReq *vearchpb.SearchRequest) error {
 	searchReq.IsVectorValue = searchDoc.VectorValue
 	searchReq.L2Sqrt = searchDoc.L2Sqrt
 	searchReq.Fields = searchDoc.Fields
@@ -822,7 +910,7 @@ func requestToPb(searchDoc *request.SearchDocumentRequest, space *entity.Space,
 	searchReq.SortFields = sortFieldArr
 	searchReq.SortFieldMap = sortFieldMap
 
-	err = parseSearch(searchDoc.Vectors, searchDoc.Filters, searchReq, space)
+	err = parseSearch(ctx, searchDoc.Vectors, searchDoc.Filters, searchReq, space)
 	if err != nil {
 		return err
 	}
@@ -835,6 +923,41 @@ func requestToPb(searchDoc *request.SearchDocumentRequest, space *entity.Space,
 	}
 
 	searchReq.Head.ClientType = searchDoc.LoadBalance
+	setAllowPartialResults(searchDoc, searchReq.Head)
+	return setConsistency(searchDoc, searchReq.Head)
+}
+
+// setAllowPartialResults puts allow_partial_results of request to head
+func setAllowPartialResults(searchDoc *request.SearchDocumentRequest, head *vearchpb.RequestHead) {
+	if searchDoc.AllowPartialResults == nil {
+		return
+	}
+	if head.Params == nil {
+		head.Params = make(map[string]string)
+	}
+	head.Params[client.AllowPartialResultsParam] = strconv.FormatBool(*searchDoc.AllowPartialResults)
+}
+
+// setConsistency puts the consistency token and consistency of read to head,
+// a linearizable read goes to leader
+func setConsistency(searchDoc *request.SearchDocumentRequest, head *vearchpb.RequestHead) error {
+	if head.Params == nil {
+		head.Params = make(map[string]string)
+	}
+	if searchDoc.ConsistencyToken != "" {
+		if _, err := entity.ParseConsistencyToken(searchDoc.ConsistencyToken); err != nil {
+			return err
+		}
+		head.Params[client.ConsistencyTokenParam] = searchDoc.ConsistencyToken
+	}
+	switch searchDoc.Consistency {
+	case "":
+	case client.ConsistencyLinearizable:
+		head.Params[client.ConsistencyParam] = client.ConsistencyLinearizable
+		head.ClientType = "leader"
+	default:
+		return vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("consistency should be empty or %s, but is %s", client.ConsistencyLinearizable, searchDoc.Consistency))
+	}
 	return nil
 }
 
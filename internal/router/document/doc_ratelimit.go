@@ -0,0 +1,249 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/pkg/httphelper"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"golang.org/x/time/rate"
+)
+
+const (
+	quotaKey = "quota"
+	// a bucket not used for it is evicted, it is full again when rebuilt
+	limiterIdleTime = 10 * time.Minute
+)
+
+// limiters keeps the token buckets of the rate limits, a bucket is rebuilt
+// when its limit is changed in master
+var limiters sync.Map // rate limit key -> *limiter
+
+// limiterSweep is the unix nano of the last sweep of idle limiters
+var limiterSweep atomic.Int64
+
+type limiter struct {
+	conf  entity.RateLimit
+	qps   *rate.Limiter
+	write *rate.Limiter
+	// inflight is kept by the limiter rebuilt for a changed limit, the
+	// requests running are released on it
+	inflight *atomic.Int64
+	used     atomic.Int64 // unix nano
+}
+
+func newLimiter(conf *entity.RateLimit, now time.Time) *limiter {
+	l := &limiter{conf: *conf, inflight: new(atomic.Int64)}
+	if conf.QPS > 0 {
+		l.qps = rate.NewLimiter(rate.Limit(conf.QPS), max(conf.Burst, int(math.Ceil(conf.QPS))))
+	}
+	if conf.WriteDocs > 0 {
+		l.write = rate.NewLimiter(rate.Limit(conf.WriteDocs), max(conf.WriteBurst, int(math.Ceil(conf.WriteDocs))))
+	}
+	l.used.Store(now.UnixNano())
+	return l
+}
+
+func limiterOf(conf *entity.RateLimit) *limiter {
+	now := time.Now()
+	sweepLimiters(now)
+	key := entity.RateLimitKey(conf.Scope, conf.Name)
+	v, loaded := limiters.Load(key)
+	for {
+		if loaded && v.(*limiter).conf == *conf {
+			l := v.(*limiter)
+			l.used.Store(now.UnixNano())
+			return l
+		}
+		l := newLimiter(conf, now)
+		if !loaded {
+			if v, loaded = limiters.LoadOrStore(key, l); !loaded {
+				return l
+			}
+			continue
+		}
+		// the limit is changed in master
+		l.inflight = v.(*limiter).inflight
+		if limiters.CompareAndSwap(key, v, l) {
+			return l
+		}
+		v, loaded = limiters.Load(key)
+	}
+}
+
+// sweepLimiters evicts the limiters idle for limiterIdleTime, at most once
+// in limiterIdleTime
+func sweepLimiters(now time.Time) {
+	last := limiterSweep.Load()
+	if now.UnixNano()-last < int64(limiterIdleTime) || !limiterSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	limiters.Range(func(key, v interface{}) bool {
+		if l := v.(*limiter); l.inflight.Load() == 0 && now.UnixNano()-l.used.Load() > int64(limiterIdleTime) {
+			limiters.CompareAndDelete(key, v)
+		}
+		return true
+	})
+}
+
+// rateLimitsOf returns the rate limit of scope from the cache of client, it
+// is nil if no limit
+func rateLimitsOf(client *client.Client) func(scope, name string) *entity.RateLimit {
+	return func(scope, name string) *entity.RateLimit {
+		return client.Master().Cache().RateLimitByCache(scope, name)
+	}
+}
+
+// quota is the rate limits a document request passed, it holds the
+// concurrency slots until the request ends. The tokens it took are given
+// back if a later limit rejects the request.
+type quota struct {
+	limits   func(scope, name string) *entity.RateLimit
+	user     string
+	acquired []*limiter
+	reserved []reservation
+}
+
+// reservation is given back at the time it was made, a later time finds
+// its tokens already spent
+type reservation struct {
+	*rate.Reservation
+	at time.Time
+}
+
+// rateLimit is the middleware of document apis, it enforces the qps and
+// concurrency limits of user, the handlers enforce the limits of db and
+// space and the write_docs of user after they parse the request
+func (handler *DocumentHandler) rateLimit(c *gin.Context) {
+	q := &quota{limits: handler.rateLimits, user: c.GetString(gin.AuthUserKey)}
+	c.Set(quotaKey, q)
+	defer q.release()
+
+	if q.user != "" {
+		if !q.allow(c, entity.RateLimitScopeUser, q.user, 0) {
+			c.Abort()
+			return
+		}
+	}
+	c.Next()
+}
+
+func quotaOf(c *gin.Context) *quota {
+	if v, ok := c.Get(quotaKey); ok {
+		return v.(*quota)
+	}
+	return nil
+}
+
+// allowSpace enforces the limits of db and space and the write_docs of
+// user, docs is the number of documents written by the request
+func (q *quota) allowSpace(c *gin.Context, dbName, spaceName string, docs int) bool {
+	if q == nil {
+		return true
+	}
+	if q.user != "" && docs > 0 {
+		if conf := q.limits(entity.RateLimitScopeUser, q.user); conf != nil && !q.allowDocs(c, conf, limiterOf(conf), docs, time.Now()) {
+			return false
+		}
+	}
+	return q.allow(c, entity.RateLimitScopeDB, dbName, docs) &&
+		q.allow(c, entity.RateLimitScopeSpace, entity.RateLimitName(entity.RateLimitScopeSpace, "", dbName, spaceName), docs)
+}
+
+// allow takes a request and docs documents from the buckets of limit, the
+// response is written if it is rejected
+func (q *quota) allow(c *gin.Context, scope, name string, docs int) bool {
+	conf := q.limits(scope, name)
+	if conf == nil {
+		return true
+	}
+	l := limiterOf(conf)
+
+	if conf.MaxConcurrency > 0 {
+		if l.inflight.Add(1) > int64(conf.MaxConcurrency) {
+			l.inflight.Add(-1)
+			q.reject(c, fmt.Errorf("max_concurrency %d of %s %s exceeded", conf.MaxConcurrency, scope, name), time.Second)
+			return false
+		}
+		q.acquired = append(q.acquired, l)
+	}
+
+	now := time.Now()
+	if l.qps != nil {
+		r := l.qps.ReserveN(now, 1)
+		if delay := r.DelayFrom(now); delay > 0 {
+			r.CancelAt(now)
+			q.reject(c, fmt.Errorf("qps %g of %s %s exceeded", conf.QPS, scope, name), delay)
+			return false
+		}
+		q.reserved = append(q.reserved, reservation{Reservation: r, at: now})
+	}
+	return q.allowDocs(c, conf, l, docs, now)
+}
+
+// allowDocs takes docs documents from the write bucket of limit
+func (q *quota) allowDocs(c *gin.Context, conf *entity.RateLimit, l *limiter, docs int, now time.Time) bool {
+	if docs <= 0 || l.write == nil {
+		return true
+	}
+	if docs > l.write.Burst() {
+		q.cancel()
+		err := vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("%d documents is more than write_burst %d of %s %s", docs, l.write.Burst(), conf.Scope, conf.Name))
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return false
+	}
+	r := l.write.ReserveN(now, docs)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		q.reject(c, fmt.Errorf("write_docs %g of %s %s exceeded", conf.WriteDocs, conf.Scope, conf.Name), delay)
+		return false
+	}
+	q.reserved = append(q.reserved, reservation{Reservation: r, at: now})
+	return true
+}
+
+// cancel gives back the tokens taken by the limits the request passed
+func (q *quota) cancel() {
+	for _, r := range q.reserved {
+		r.CancelAt(r.at)
+	}
+	q.reserved = nil
+}
+
+// reject responds 429 with the seconds to retry after
+func (q *quota) reject(c *gin.Context, err error, retryAfter time.Duration) {
+	q.cancel()
+	log.Debug("rate limit reject %s: %s", c.Request.URL.Path, err.Error())
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	httphelper.New(c).JsonError(errors.NewErrTooManyRequests(vearchpb.NewError(vearchpb.ErrorEnum_SERVICE_UNAVAILABLE, err)))
+}
+
+func (q *quota) release() {
+	for _, l := range q.acquired {
+		l.inflight.Add(-1)
+	}
+	q.acquired = nil
+}
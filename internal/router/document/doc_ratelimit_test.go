@@ -0,0 +1,279 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/entity"
+)
+
+// testRateLimitRouter serves /:db/:space/:docs with the rate limits, the
+// user is taken from the user header, the handler waits on block if it is
+// not nil
+func testRateLimitRouter(limits []*entity.RateLimit, block chan struct{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := &DocumentHandler{rateLimits: func(scope, name string) *entity.RateLimit {
+		for _, l := range limits {
+			if l.Scope == scope && l.Name == name {
+				return l
+			}
+		}
+		return nil
+	}}
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		if user := c.GetHeader("user"); user != "" {
+			c.Set(gin.AuthUserKey, user)
+		}
+	})
+	engine.POST("/:db/:space/:docs", handler.rateLimit, func(c *gin.Context) {
+		docs, _ := strconv.Atoi(c.Param("docs"))
+		if !quotaOf(c).allowSpace(c, c.Param("db"), c.Param("space"), docs) {
+			return
+		}
+		if block != nil {
+			<-block
+		}
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func testRateLimitServe(engine *gin.Engine, path string) *httptest.ResponseRecorder {
+	return testRateLimitServeUser(engine, path, "")
+}
+
+func testRateLimitServeUser(engine *gin.Engine, path, user string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	if user != "" {
+		req.Header.Set("user", user)
+	}
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitQPS(t *testing.T) {
+	engine := testRateLimitRouter([]*entity.RateLimit{
+		{Scope: entity.RateLimitScopeSpace, Name: entity.RateLimitName(entity.RateLimitScopeSpace, "", "qps_db", "s1"), QPS: 0.5, Burst: 1},
+	}, nil)
+
+	if w := testRateLimitServe(engine, "/qps_db/s1/0"); w.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want 200", w.Code)
+	}
+	w := testRateLimitServe(engine, "/qps_db/s1/0")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request status = %d, want 429", w.Code)
+	}
+	if retry, _ := strconv.Atoi(w.Header().Get("Retry-After")); retry < 1 || retry > 2 {
+		t.Errorf("Retry-After = %q, want 1 or 2 seconds", w.Header().Get("Retry-After"))
+	}
+	if w := testRateLimitServe(engine, "/qps_db/s2/0"); w.Code != http.StatusOK {
+		t.Errorf("other space status = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimitCancelDB(t *testing.T) {
+	engine := testRateLimitRouter([]*entity.RateLimit{
+		{Scope: entity.RateLimitScopeDB, Name: "cancel_db", QPS: 0.01, Burst: 2},
+		{Scope: entity.RateLimitScopeSpace, Name: entity.RateLimitName(entity.RateLimitScopeSpace, "", "cancel_db", "s1"), QPS: 0.01, Burst: 1},
+	}, nil)
+
+	if w := testRateLimitServe(engine, "/cancel_db/s1/0"); w.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want 200", w.Code)
+	}
+	// the space rejects, the token of db is given back
+	for i := 0; i < 3; i++ {
+		if w := testRateLimitServe(engine, "/cancel_db/s1/0"); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("request of limited space status = %d, want 429", w.Code)
+		}
+	}
+	if w := testRateLimitServe(engine, "/cancel_db/s2/0"); w.Code != http.StatusOK {
+		t.Fatalf("request of other space status = %d, want 200", w.Code)
+	}
+	if w := testRateLimitServe(engine, "/cancel_db/s2/0"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over db burst status = %d, want 429", w.Code)
+	}
+}
+
+func TestRateLimitWriteDocs(t *testing.T) {
+	engine := testRateLimitRouter([]*entity.RateLimit{
+		{Scope: entity.RateLimitScopeDB, Name: "write_db", QPS: 0.01, Burst: 1},
+		{Scope: entity.RateLimitScopeSpace, Name: entity.RateLimitName(entity.RateLimitScopeSpace, "", "write_db", "s1"), WriteDocs: 10, WriteBurst: 10},
+	}, nil)
+
+	if w := testRateLimitServe(engine, "/write_db/s1/11"); w.Code != http.StatusBadRequest {
+		t.Fatalf("documents over write_burst status = %d, want 400", w.Code)
+	}
+	if w := testRateLimitServe(engine, "/write_db/s1/10"); w.Code != http.StatusOK {
+		t.Fatalf("documents of write_burst status = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimitUserWriteDocs(t *testing.T) {
+	engine := testRateLimitRouter([]*entity.RateLimit{
+		{Scope: entity.RateLimitScopeUser, Name: "write_user", WriteDocs: 0.01, WriteBurst: 10},
+	}, nil)
+
+	if w := testRateLimitServeUser(engine, "/user_db/s1/11", "write_user"); w.Code != http.StatusBadRequest {
+		t.Fatalf("documents over write_burst of user status = %d, want 400", w.Code)
+	}
+	if w := testRateLimitServeUser(engine, "/user_db/s1/6", "write_user"); w.Code != http.StatusOK {
+		t.Fatalf("first write status = %d, want 200", w.Code)
+	}
+	// the documents of user are counted over spaces
+	if w := testRateLimitServeUser(engine, "/user_db/s2/6", "write_user"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("write over user write_docs status = %d, want 429", w.Code)
+	}
+	if w := testRateLimitServeUser(engine, "/user_db/s2/0", "write_user"); w.Code != http.StatusOK {
+		t.Fatalf("read of user status = %d, want 200", w.Code)
+	}
+	if w := testRateLimitServeUser(engine, "/user_db/s2/6", "other_user"); w.Code != http.StatusOK {
+		t.Fatalf("write of other user status = %d, want 200", w.Code)
+	}
+}
+
+func TestLimiterOf(t *testing.T) {
+	conf := &entity.RateLimit{Scope: entity.RateLimitScopeSpace, Name: "limiter_db/s1", QPS: 1}
+	l := limiterOf(conf)
+	if limiterOf(conf) != l {
+		t.Fatal("limiter of same limit is rebuilt")
+	}
+	// the limit is changed in master
+	changed := &entity.RateLimit{Scope: conf.Scope, Name: conf.Name, QPS: 2}
+	l2 := limiterOf(changed)
+	if l2 == l || l2.conf != *changed {
+		t.Fatal("limiter of changed limit is not rebuilt")
+	}
+	if v, _ := limiters.Load(entity.RateLimitKey(conf.Scope, conf.Name)); v != l2 {
+		t.Fatal("changed limiter is not stored")
+	}
+}
+
+func TestRateLimitMaxConcurrencyChanged(t *testing.T) {
+	name := entity.RateLimitName(entity.RateLimitScopeSpace, "", "concurrency_changed_db", "s1")
+	limits := []*entity.RateLimit{{Scope: entity.RateLimitScopeSpace, Name: name, MaxConcurrency: 1}}
+	block := make(chan struct{})
+	engine := testRateLimitRouter(limits, block)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		testRateLimitServe(engine, "/concurrency_changed_db/s1/0")
+	}()
+	l := func() *limiter {
+		v, _ := limiters.Load(entity.RateLimitKey(entity.RateLimitScopeSpace, name))
+		l, _ := v.(*limiter)
+		return l
+	}
+	for l() == nil || l().inflight.Load() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the qps is changed in master while the request is running
+	limits[0] = &entity.RateLimit{Scope: entity.RateLimitScopeSpace, Name: name, MaxConcurrency: 1, QPS: 100}
+	second := make(chan int, 1)
+	go func() {
+		second <- testRateLimitServe(engine, "/concurrency_changed_db/s1/0").Code
+	}()
+	select {
+	case code := <-second:
+		if code != http.StatusTooManyRequests {
+			t.Errorf("concurrent request after limit changed status = %d, want 429", code)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("concurrent request after limit changed is not rejected")
+	}
+	close(block)
+	wg.Wait()
+	if n := l().inflight.Load(); n != 0 {
+		t.Fatalf("inflight after requests = %d, want 0", n)
+	}
+}
+
+func TestSweepLimiters(t *testing.T) {
+	idle := limiterOf(&entity.RateLimit{Scope: entity.RateLimitScopeSpace, Name: "sweep_db/idle", QPS: 1})
+	busy := limiterOf(&entity.RateLimit{Scope: entity.RateLimitScopeSpace, Name: "sweep_db/busy", QPS: 1})
+	busy.inflight.Add(1)
+	defer busy.inflight.Add(-1)
+	used := limiterOf(&entity.RateLimit{Scope: entity.RateLimitScopeSpace, Name: "sweep_db/used", QPS: 1})
+
+	now := time.Now().Add(2 * limiterIdleTime)
+	used.used.Store(now.UnixNano())
+	limiterSweep.Store(0)
+	sweepLimiters(now)
+
+	loaded := func(l *limiter) bool {
+		_, ok := limiters.Load(entity.RateLimitKey(l.conf.Scope, l.conf.Name))
+		return ok
+	}
+	if loaded(idle) {
+		t.Error("idle limiter is not evicted")
+	}
+	if !loaded(busy) {
+		t.Error("limiter of running requests is evicted")
+	}
+	if !loaded(used) {
+		t.Error("recently used limiter is evicted")
+	}
+}
+
+func TestRateLimitMaxConcurrency(t *testing.T) {
+	name := entity.RateLimitName(entity.RateLimitScopeSpace, "", "concurrency_db", "s1")
+	block := make(chan struct{})
+	engine := testRateLimitRouter([]*entity.RateLimit{
+		{Scope: entity.RateLimitScopeSpace, Name: name, MaxConcurrency: 1},
+	}, block)
+
+	var wg sync.WaitGroup
+	var first *httptest.ResponseRecorder
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		first = testRateLimitServe(engine, "/concurrency_db/s1/0")
+	}()
+	l := func() *limiter {
+		v, _ := limiters.Load(entity.RateLimitKey(entity.RateLimitScopeSpace, name))
+		l, _ := v.(*limiter)
+		return l
+	}
+	for l() == nil || l().inflight.Load() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	w := testRateLimitServe(engine, "/concurrency_db/s1/0")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("concurrent request status = %d Retry-After %q, want 429 and 1", w.Code, w.Header().Get("Retry-After"))
+	}
+	close(block)
+	wg.Wait()
+	if first.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want 200", first.Code)
+	}
+	if n := l().inflight.Load(); n != 0 {
+		t.Fatalf("inflight after requests = %d, want 0", n)
+	}
+	if w := testRateLimitServe(engine, "/concurrency_db/s1/0"); w.Code != http.StatusOK {
+		t.Errorf("request after release status = %d, want 200", w.Code)
+	}
+}
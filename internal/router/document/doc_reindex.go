@@ -0,0 +1,795 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/errors"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/httphelper"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/netutil"
+	"github.com/vearch/vearch/v3/internal/pkg/server/vearchhttp"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine/mapping"
+	"golang.org/x/time/rate"
+)
+
+const (
+	URLParamJobID = "job_id"
+
+	defaultReindexBatchSize = 100
+	maxReindexBatchSize     = 1000
+
+	ReindexRunning  = "running"
+	ReindexFinished = "finished"
+	ReindexCanceled = "canceled"
+	ReindexFailed   = "failed"
+
+	nextDocidField = "_docid"
+
+	// finished jobs are kept in etcd for the retention
+	reindexJobRetention = 24 * time.Hour
+	// the running job is saved by the interval, and it is lost if not saved
+	// for reindexJobLostTime, the router running it may be restarted
+	reindexJobSaveInterval = 5 * time.Second
+	reindexJobLostTime     = time.Minute
+	// the keys of documents matching the filters are queried in a page, the
+	// partitions are scanned if more documents match, as query has no offset
+	reindexFilterPageSize = 10000
+)
+
+// reindexJobStore persists reindex jobs, so jobs are seen by all routers and
+// kept after the router restarts
+type reindexJobStore interface {
+	Put(ctx context.Context, key string, value []byte) error
+	CreateWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	PrefixScan(ctx context.Context, prefix string) ([][]byte, [][]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// reindexJob copies documents of source space into dest space, it runs in
+// the router which receives the request and is saved in etcd
+type reindexJob struct {
+	ID                 string                  `json:"job_id"`
+	Request            *request.ReindexRequest `json:"request"`
+	Status             string                  `json:"status"`
+	Partitions         int                     `json:"partitions"`
+	FinishedPartitions int32                   `json:"finished_partitions"`
+	Scanned            int64                   `json:"scanned"`
+	Written            int64                   `json:"written"`
+	Failed             int64                   `json:"failed"`
+	Error              string                  `json:"error,omitempty"`
+	StartTime          time.Time               `json:"start_time"`
+	EndTime            *time.Time              `json:"end_time,omitempty"`
+	UpdateTime         time.Time               `json:"update_time"`
+
+	lock   sync.RWMutex
+	cancel context.CancelFunc
+}
+
+func (job *reindexJob) setError(msg string) {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	job.Error = msg
+}
+
+func (job *reindexJob) finish(status string) {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	now := time.Now()
+	job.Status = status
+	job.EndTime = &now
+}
+
+// snapshot returns a copy of job for response
+func (job *reindexJob) snapshot() *reindexJob {
+	job.lock.RLock()
+	defer job.lock.RUnlock()
+	return &reindexJob{
+		ID:                 job.ID,
+		Request:            job.Request,
+		Status:             job.Status,
+		Partitions:         job.Partitions,
+		FinishedPartitions: atomic.LoadInt32(&job.FinishedPartitions),
+		Scanned:            atomic.LoadInt64(&job.Scanned),
+		Written:            atomic.LoadInt64(&job.Written),
+		Failed:             atomic.LoadInt64(&job.Failed),
+		Error:              job.Error,
+		StartTime:          job.StartTime,
+		EndTime:            job.EndTime,
+	}
+}
+
+func (job *reindexJob) running() bool {
+	job.lock.RLock()
+	defer job.lock.RUnlock()
+	return job.Status == ReindexRunning
+}
+
+// checkLost marks the running job which is not saved for reindexJobLostTime
+// as failed
+func (job *reindexJob) checkLost(now time.Time) {
+	if job.Status == ReindexRunning && now.Sub(job.UpdateTime) > reindexJobLostTime {
+		job.Status = ReindexFailed
+		job.Error = "reindex job is lost, the router running it may be restarted"
+	}
+}
+
+// saveReindexJob saves the snapshot of job, the finished job expires after
+// the retention
+func (handler *DocumentHandler) saveReindexJob(ctx context.Context, job *reindexJob) error {
+	snapshot := job.snapshot()
+	snapshot.UpdateTime = time.Now()
+	value, err := vjson.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if snapshot.Status == ReindexRunning {
+		return handler.reindexStore.Put(ctx, entity.ReindexJobKey(job.ID), value)
+	}
+	return handler.reindexStore.CreateWithTTL(ctx, entity.ReindexJobKey(job.ID), value, reindexJobRetention)
+}
+
+// loadReindexJob returns the saved job, nil if not found
+func (handler *DocumentHandler) loadReindexJob(ctx context.Context, id string) (*reindexJob, error) {
+	value, err := handler.reindexStore.Get(ctx, entity.ReindexJobKey(id))
+	if err != nil || value == nil {
+		return nil, err
+	}
+	job := &reindexJob{}
+	if err = vjson.Unmarshal(value, job); err != nil {
+		return nil, err
+	}
+	job.checkLost(time.Now())
+	return job, nil
+}
+
+// loadReindexJobs returns the saved jobs, the lost jobs out of retention are
+// removed
+func (handler *DocumentHandler) loadReindexJobs(ctx context.Context) ([]*reindexJob, error) {
+	_, values, err := handler.reindexStore.PrefixScan(ctx, entity.PrefixReindex)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	jobs := make([]*reindexJob, 0, len(values))
+	for _, value := range values {
+		job := &reindexJob{}
+		if err := vjson.Unmarshal(value, job); err != nil {
+			log.Error("unmarshal reindex job err: %s", err.Error())
+			continue
+		}
+		if job.Status == ReindexRunning && now.Sub(job.UpdateTime) > reindexJobRetention {
+			if err := handler.reindexStore.Delete(ctx, entity.ReindexJobKey(job.ID)); err != nil {
+				log.Error("remove lost reindex job:[%s] err: %s", job.ID, err.Error())
+			}
+			continue
+		}
+		job.checkLost(now)
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].StartTime.Before(jobs[j].StartTime) })
+	return jobs, nil
+}
+
+// reindexTask holds the parsed request of reindex job
+type reindexTask struct {
+	sourceHead   *vearchpb.RequestHead
+	destHead     *vearchpb.RequestHead
+	source       *entity.Space
+	dest         *entity.Space
+	sourceMap    map[string]*entity.SpaceProperties
+	destMap      map[string]*entity.SpaceProperties
+	rangeFilters []*vearchpb.RangeFilter
+	termFilters  []*vearchpb.TermFilter
+	drop         map[string]bool
+	limiter      *rate.Limiter
+	batchSize    int
+}
+
+func (handler *DocumentHandler) handleReindex(c *gin.Context) {
+	reqBody, err := netutil.GetReqBody(c.Request)
+	if err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+	args := &request.ReindexRequest{}
+	if err = vjson.Unmarshal(reqBody, args); err != nil {
+		err = vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("ReindexRequest param convert json %s err: %v", string(reqBody), err))
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	// the privileges are checked before the spaces are got, so the errors
+	// do not tell whether spaces exist
+	args.Source.SpaceName = handler.resolveAlias(c.Request.Context(), args.Source.SpaceName)
+	args.Dest.SpaceName = handler.resolveAlias(c.Request.Context(), args.Dest.SpaceName)
+	if !handler.allow(c, args.Source.DbName, args.Source.SpaceName, entity.PrivilegeSelect) ||
+		!handler.allow(c, args.Dest.DbName, args.Dest.SpaceName, entity.PrivilegeInsert|entity.PrivilegeUpdate) {
+		return
+	}
+	task, err := handler.newReindexTask(c.Request.Context(), args)
+	if err != nil {
+		httphelper.New(c).JsonError(errors.NewErrBadRequest(err))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &reindexJob{
+		ID:         uuid.NewString(),
+		Request:    args,
+		Status:     ReindexRunning,
+		Partitions: len(task.source.Partitions),
+		StartTime:  time.Now(),
+		cancel:     cancel,
+	}
+	if err = handler.saveReindexJob(c.Request.Context(), job); err != nil {
+		cancel()
+		httphelper.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	handler.reindexJobs.Store(job.ID, job)
+
+	go handler.runReindex(ctx, job, task)
+
+	httphelper.New(c).JsonSuccess(map[string]string{URLParamJobID: job.ID})
+}
+
+// resolveAlias returns the space name the alias points to, the name itself
+// if it is not an alias
+func (handler *DocumentHandler) resolveAlias(ctx context.Context, name string) string {
+	if alias, err := handler.aliasOf(ctx, name); err == nil {
+		return alias.SpaceName
+	}
+	return name
+}
+
+// visible reports whether the user of request can select both spaces of job
+func (handler *DocumentHandler) visible(c *gin.Context, job *reindexJob) bool {
+	if job.Request == nil {
+		return vearchhttp.Has(c, handler.users, "", "", entity.PrivilegeSelect)
+	}
+	return vearchhttp.Has(c, handler.users, job.Request.Source.DbName, job.Request.Source.SpaceName, entity.PrivilegeSelect) &&
+		vearchhttp.Has(c, handler.users, job.Request.Dest.DbName, job.Request.Dest.SpaceName, entity.PrivilegeSelect)
+}
+
+// handleReindexList lists the jobs of spaces the user can select
+func (handler *DocumentHandler) handleReindexList(c *gin.Context) {
+	jobs, err := handler.loadReindexJobs(c.Request.Context())
+	if err != nil {
+		httphelper.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	visible := jobs[:0]
+	for _, job := range jobs {
+		if handler.visible(c, job) {
+			visible = append(visible, job)
+		}
+	}
+	httphelper.New(c).JsonSuccess(visible)
+}
+
+func (handler *DocumentHandler) handleReindexGet(c *gin.Context) {
+	job, err := handler.loadReindexJob(c.Request.Context(), c.Param(URLParamJobID))
+	if err != nil {
+		httphelper.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	if job == nil || !handler.visible(c, job) {
+		reindexJobNotFound(c)
+		return
+	}
+	httphelper.New(c).JsonSuccess(job)
+}
+
+// handleReindexCancel cancels the job running in this router, the job of
+// other router is canceled by the cancel key it watches
+func (handler *DocumentHandler) handleReindexCancel(c *gin.Context) {
+	if value, ok := handler.reindexJobs.Load(c.Param(URLParamJobID)); ok {
+		job := value.(*reindexJob)
+		if !handler.visible(c, job) {
+			reindexJobNotFound(c)
+			return
+		}
+		job.cancel()
+		httphelper.New(c).JsonSuccess(job.snapshot())
+		return
+	}
+	job, err := handler.loadReindexJob(c.Request.Context(), c.Param(URLParamJobID))
+	if err != nil {
+		httphelper.New(c).JsonError(errors.NewErrInternal(err))
+		return
+	}
+	if job == nil || !handler.visible(c, job) {
+		reindexJobNotFound(c)
+		return
+	}
+	if job.Status == ReindexRunning {
+		if err = handler.reindexStore.CreateWithTTL(c.Request.Context(), entity.ReindexCancelKey(job.ID), []byte(job.ID), reindexJobLostTime); err != nil {
+			httphelper.New(c).JsonError(errors.NewErrInternal(err))
+			return
+		}
+	}
+	httphelper.New(c).JsonSuccess(job)
+}
+
+// reindexJobNotFound replies the job in path is not found, a job of spaces
+// the user can not select is also not found
+func reindexJobNotFound(c *gin.Context) {
+	err := vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("reindex job:[%s] not found", c.Param(URLParamJobID)))
+	httphelper.New(c).JsonError(errors.NewErrNotFound(err))
+}
+
+// newReindexTask checks the request and the fields of source and dest space
+func (handler *DocumentHandler) newReindexTask(ctx context.Context, args *request.ReindexRequest) (*reindexTask, error) {
+	sourceHead := &vearchpb.RequestHead{DbName: args.Source.DbName, SpaceName: args.Source.SpaceName}
+	source, err := handler.docService.getSpace(ctx, sourceHead)
+	if err != nil {
+		return nil, err
+	}
+	args.Source.SpaceName = sourceHead.SpaceName
+	destHead := &vearchpb.RequestHead{DbName: args.Dest.DbName, SpaceName: args.Dest.SpaceName}
+	dest, err := handler.docService.getSpace(ctx, destHead)
+	if err != nil {
+		return nil, err
+	}
+	args.Dest.SpaceName = destHead.SpaceName
+	if source.Id == dest.Id {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("source and dest space should be different"))
+	}
+
+	task := &reindexTask{sourceHead: sourceHead, destHead: destHead, source: source, dest: dest, drop: make(map[string]bool), limiter: rate.NewLimiter(rate.Inf, 0)}
+	if task.sourceMap, err = entity.UnmarshalPropertyJSON(source.Fields); err != nil {
+		return nil, err
+	}
+	if task.destMap, err = entity.UnmarshalPropertyJSON(dest.Fields); err != nil {
+		return nil, err
+	}
+
+	for _, name := range args.DropFields {
+		if task.sourceMap[name] == nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("drop field:[%s] not found in source space", name))
+		}
+		task.drop[name] = true
+	}
+	for from, to := range args.FieldsMap {
+		if task.sourceMap[from] == nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] of fields_map not found in source space", from))
+		}
+		if task.destMap[to] == nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("field:[%s] of fields_map not found in dest space", to))
+		}
+	}
+
+	// the engine value of field is copied, so the type should be same
+	for name, pro := range task.sourceMap {
+		if task.drop[name] {
+			continue
+		}
+		to := name
+		if args.FieldsMap[name] != "" {
+			to = args.FieldsMap[name]
+		}
+		destPro := task.destMap[to]
+		if destPro == nil {
+			continue
+		}
+		if destPro.FieldType != pro.FieldType {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("type of source field:[%s] is [%s] but dest field:[%s] is [%s]", name, pro.FieldType.String(), to, destPro.FieldType.String()))
+		}
+		if pro.FieldType == vearchpb.FieldType_VECTOR {
+			if destPro.Dimension != pro.Dimension {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("dimension of source field:[%s] is [%d] but dest field:[%s] is [%d]", name, pro.Dimension, to, destPro.Dimension))
+			}
+			if (source.Index.Type == "BINARYIVF") != (dest.Index.Type == "BINARYIVF") {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("binary vector field:[%s] can not reindex to float vector", name))
+			}
+		}
+	}
+
+	if args.Filters != nil {
+		if task.rangeFilters, task.termFilters, err = parseFilter(args.Filters, source); err != nil {
+			return nil, err
+		}
+	}
+
+	task.batchSize = args.BatchSize
+	if task.batchSize <= 0 {
+		task.batchSize = defaultReindexBatchSize
+	}
+	if task.batchSize > maxReindexBatchSize {
+		task.batchSize = maxReindexBatchSize
+	}
+	if args.Rate > 0 {
+		task.limiter = rate.NewLimiter(rate.Limit(args.Rate), task.batchSize)
+	}
+	return task, nil
+}
+
+func (handler *DocumentHandler) runReindex(ctx context.Context, job *reindexJob, task *reindexTask) {
+	done := make(chan struct{})
+	go handler.watchReindex(job, done)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("reindex job:[%s] panic: %v, stack: %s", job.ID, r, string(debug.Stack()))
+			job.setError(fmt.Sprintf("%v", r))
+			job.finish(ReindexFailed)
+		}
+		close(done)
+		handler.reindexJobs.Delete(job.ID)
+		if err := handler.saveReindexJob(context.Background(), job); err != nil {
+			log.Error("save reindex job:[%s] err: %s", job.ID, err.Error())
+		}
+	}()
+	log.Info("reindex job:[%s] from [%s/%s] to [%s/%s] begin", job.ID, task.sourceHead.DbName, task.sourceHead.SpaceName, task.destHead.DbName, task.destHead.SpaceName)
+
+	var err error
+	copied := false
+	if len(task.rangeFilters) > 0 || len(task.termFilters) > 0 {
+		copied, err = handler.reindexFiltered(ctx, job, task)
+	}
+	if err == nil && !copied {
+		for _, partition := range task.source.Partitions {
+			if err = handler.reindexPartition(ctx, job, task, partition.Id); err != nil {
+				err = fmt.Errorf("partition:[%d] err: %w", partition.Id, err)
+				break
+			}
+			atomic.AddInt32(&job.FinishedPartitions, 1)
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			job.finish(ReindexCanceled)
+			log.Info("reindex job:[%s] canceled", job.ID)
+			return
+		}
+		job.setError(err.Error())
+		job.finish(ReindexFailed)
+		log.Error("reindex job:[%s] err: %s", job.ID, err.Error())
+		return
+	}
+	job.finish(ReindexFinished)
+	log.Info("reindex job:[%s] end, scanned:[%d] written:[%d] failed:[%d]", job.ID, atomic.LoadInt64(&job.Scanned), atomic.LoadInt64(&job.Written), atomic.LoadInt64(&job.Failed))
+}
+
+// watchReindex saves the progress of running job, and cancels it when the
+// cancel key is put by any router
+func (handler *DocumentHandler) watchReindex(job *reindexJob, done chan struct{}) {
+	ticker := time.NewTicker(reindexJobSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), reindexJobSaveInterval)
+		if err := handler.saveReindexJob(ctx, job); err != nil {
+			log.Error("save reindex job:[%s] err: %s", job.ID, err.Error())
+		}
+		if value, err := handler.reindexStore.Get(ctx, entity.ReindexCancelKey(job.ID)); err != nil {
+			log.Error("get cancel of reindex job:[%s] err: %s", job.ID, err.Error())
+		} else if value != nil {
+			log.Info("reindex job:[%s] is canceled by other router", job.ID)
+			job.cancel()
+		}
+		cancel()
+	}
+}
+
+// reindexFiltered queries the keys of documents matching the filters on the
+// engine, then copies the documents by keys in batches. It copies nothing
+// and returns false if more than a page of documents match, the partitions
+// are scanned then and the documents are filtered by matchFilters.
+func (handler *DocumentHandler) reindexFiltered(ctx context.Context, job *reindexJob, task *reindexTask) (bool, error) {
+	args := &vearchpb.QueryRequest{
+		Head:         newReindexHead(task.sourceHead),
+		RangeFilters: task.rangeFilters,
+		TermFilters:  task.termFilters,
+		Fields:       []string{mapping.IdField},
+		Limit:        reindexFilterPageSize,
+	}
+	reply := handler.docService.query(ctx, args)
+	if err := replyErr(reply.Head); err != nil {
+		return false, err
+	}
+	keys := make([]string, 0)
+	for _, result := range reply.Results {
+		if result == nil {
+			continue
+		}
+		for _, item := range result.ResultItems {
+			keys = append(keys, item.PKey)
+		}
+	}
+	if len(keys) >= reindexFilterPageSize {
+		log.Info("reindex job:[%s] more than %d documents match the filters, scan partitions", job.ID, reindexFilterPageSize)
+		return false, nil
+	}
+
+	for start := 0; start < len(keys); start += task.batchSize {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		args := &vearchpb.GetRequest{Head: newReindexHead(task.sourceHead), PrimaryKeys: keys[start:min(start+task.batchSize, len(keys))]}
+		reply := handler.docService.getDocs(ctx, args)
+		if err := replyErr(reply.Head); err != nil {
+			return false, err
+		}
+		if err := handler.reindexItems(ctx, job, task, reply.Items); err != nil {
+			return false, err
+		}
+	}
+	atomic.StoreInt32(&job.FinishedPartitions, int32(job.Partitions))
+	return true, nil
+}
+
+// reindexItems converts the got documents and writes them to dest space,
+// the filters are checked again as documents may change after queried
+func (handler *DocumentHandler) reindexItems(ctx context.Context, job *reindexJob, task *reindexTask, items []*vearchpb.Item) error {
+	docs := make([]*vearchpb.Document, 0, len(items))
+	for _, item := range items {
+		if item.Err != nil && item.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+			continue
+		}
+		if item.Doc == nil || item.Doc.Fields == nil {
+			continue
+		}
+		atomic.AddInt64(&job.Scanned, 1)
+		if !matchFilters(item.Doc.Fields, task) {
+			continue
+		}
+		doc, err := reindexDocument(len(docs), item.Doc.Fields, task, job.Request.FieldsMap)
+		if err != nil {
+			atomic.AddInt64(&job.Failed, 1)
+			job.setError(err.Error())
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	if err := task.limiter.WaitN(ctx, len(docs)); err != nil {
+		return err
+	}
+	return handler.reindexWrite(ctx, job, task, docs)
+}
+
+// reindexPartition scans the documents of partition by docid, a batch of
+// docids are got at once, then the next existing docid is got by next flag.
+// It is used when the job has no filters.
+func (handler *DocumentHandler) reindexPartition(ctx context.Context, job *reindexJob, task *reindexTask, partitionID entity.PartitionID) error {
+	noNext, next := false, true
+	docid := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		keys := make([]string, task.batchSize)
+		for i := range keys {
+			keys[i] = strconv.Itoa(docid + i)
+		}
+		args := &vearchpb.GetRequest{Head: newReindexHead(task.sourceHead), PrimaryKeys: keys}
+		reply := handler.docService.getDocsByPartition(ctx, args, uint32(partitionID), &noNext)
+		if err := replyErr(reply.Head); err != nil {
+			return err
+		}
+
+		if err := handler.reindexItems(ctx, job, task, reply.Items); err != nil {
+			return err
+		}
+
+		// find the next existing document after this batch
+		args = &vearchpb.GetRequest{Head: newReindexHead(task.sourceHead), PrimaryKeys: []string{keys[len(keys)-1]}}
+		reply = handler.docService.getDocsByPartition(ctx, args, uint32(partitionID), &next)
+		if err := replyErr(reply.Head); err != nil {
+			return err
+		}
+		if len(reply.Items) == 0 || reply.Items[0].Doc == nil || reply.Items[0].Doc.Fields == nil {
+			return nil
+		}
+		if item := reply.Items[0]; item.Err != nil && item.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+			if item.Err.Code == vearchpb.ErrorEnum_DOCUMENT_NOT_EXIST {
+				return nil
+			}
+			return vearchpb.NewError(item.Err.Code, fmt.Errorf("%s", item.Err.Msg))
+		}
+		nextDocid := -1
+		for _, field := range reply.Items[0].Doc.Fields {
+			if field.Name == nextDocidField {
+				nextDocid = int(cbbytes.Bytes2Int32(field.Value))
+			}
+		}
+		if nextDocid <= docid {
+			return nil
+		}
+		docid = nextDocid
+	}
+}
+
+func (handler *DocumentHandler) reindexWrite(ctx context.Context, job *reindexJob, task *reindexTask, docs []*vearchpb.Document) error {
+	args := &vearchpb.BulkRequest{Head: newReindexHead(task.destHead), Docs: docs}
+	reply := handler.docService.bulk(ctx, args)
+	getResultCache().invalidate(task.dest)
+	if err := replyErr(reply.Head); err != nil {
+		return err
+	}
+	for _, item := range reply.Items {
+		if item.Err != nil && item.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+			atomic.AddInt64(&job.Failed, 1)
+			job.setError(item.Err.Msg)
+		} else {
+			atomic.AddInt64(&job.Written, 1)
+		}
+	}
+	return nil
+}
+
+func newReindexHead(head *vearchpb.RequestHead) *vearchpb.RequestHead {
+	return &vearchpb.RequestHead{DbName: head.DbName, SpaceName: head.SpaceName, Params: make(map[string]string)}
+}
+
+func replyErr(head *vearchpb.ResponseHead) error {
+	if head != nil && head.Err != nil && head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
+		return vearchpb.NewError(head.Err.Code, fmt.Errorf("%s", head.Err.Msg))
+	}
+	return nil
+}
+
+// reindexDocument converts the fields of source document to dest document
+func reindexDocument(docIdx int, fields []*vearchpb.Field, task *reindexTask, fieldsMap map[string]string) (*vearchpb.Document, error) {
+	doc := &vearchpb.Document{Fields: make([]*vearchpb.Field, 0, len(fields))}
+	haveVector, vectorFieldNum := 0, 0
+	for _, pro := range task.destMap {
+		if pro.FieldType == vearchpb.FieldType_VECTOR {
+			vectorFieldNum++
+		}
+	}
+	for _, field := range fields {
+		if field.Name == mapping.IdField {
+			doc.PKey = string(field.Value)
+			continue
+		}
+		if field.Name == nextDocidField || task.drop[field.Name] {
+			continue
+		}
+		name := field.Name
+		if fieldsMap[name] != "" {
+			name = fieldsMap[name]
+		}
+		pro := task.destMap[name]
+		if pro == nil {
+			continue
+		}
+		opt := vearchpb.FieldOption_Null
+		if pro.Option&entity.FieldOption_Index == entity.FieldOption_Index {
+			opt = vearchpb.FieldOption_Index
+		}
+		f, err := processField(name, pro.FieldType, field.Value, opt)
+		if err != nil {
+			return nil, err
+		}
+		if pro.FieldType == vearchpb.FieldType_VECTOR {
+			haveVector++
+		}
+		doc.Fields = append(doc.Fields, f)
+	}
+	if haveVector != vectorFieldNum {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("document:[%s] vector field num:%d is not equal to vector num of dest space fields:%d", doc.PKey, haveVector, vectorFieldNum))
+	}
+	var err error
+	if doc.Fields, err = fillDocument(docIdx, doc.Fields, nil, task.dest, task.destMap); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// matchFilters evaluates the filters parsed by parseFilter on the engine
+// value of fields, all filters should be matched
+func matchFilters(fields []*vearchpb.Field, task *reindexTask) bool {
+	if len(task.rangeFilters) == 0 && len(task.termFilters) == 0 {
+		return true
+	}
+	values := make(map[string][]byte, len(fields))
+	for _, field := range fields {
+		values[field.Name] = field.Value
+	}
+	for _, rf := range task.rangeFilters {
+		value, ok := values[rf.Field]
+		pro := task.sourceMap[rf.Field]
+		if !ok || pro == nil || !matchRange(rf, pro.FieldType, value) {
+			return false
+		}
+	}
+	for _, tf := range task.termFilters {
+		value, ok := values[tf.Field]
+		if !ok || !matchTerm(tf, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchRange(rf *vearchpb.RangeFilter, fieldType vearchpb.FieldType, value []byte) bool {
+	var v, lower, upper float64
+	switch fieldType {
+	case vearchpb.FieldType_INT:
+		v, lower, upper = float64(cbbytes.Bytes2Int32(value)), float64(cbbytes.Bytes2Int32(rf.LowerValue)), float64(cbbytes.Bytes2Int32(rf.UpperValue))
+	case vearchpb.FieldType_LONG, vearchpb.FieldType_DATE:
+		// compare int64 directly, float64 loses precision
+		iv, il, iu := cbbytes.Bytes2Int(value), cbbytes.Bytes2Int(rf.LowerValue), cbbytes.Bytes2Int(rf.UpperValue)
+		return (iv > il || (rf.IncludeLower && iv == il)) && (iv < iu || (rf.IncludeUpper && iv == iu))
+	case vearchpb.FieldType_FLOAT:
+		v, lower, upper = float64(cbbytes.ByteToFloat32(value)), float64(cbbytes.ByteToFloat32(rf.LowerValue)), float64(cbbytes.ByteToFloat32(rf.UpperValue))
+	case vearchpb.FieldType_DOUBLE:
+		v, lower, upper = cbbytes.ByteToFloat64New(value), cbbytes.ByteToFloat64New(rf.LowerValue), cbbytes.ByteToFloat64New(rf.UpperValue)
+	default:
+		return false
+	}
+	return (v > lower || (rf.IncludeLower && v == lower)) && (v < upper || (rf.IncludeUpper && v == upper))
+}
+
+func matchTerm(tf *vearchpb.TermFilter, value []byte) bool {
+	terms := bytes.Split(tf.Value, []byte{'\001'})
+	values := bytes.Split(value, []byte{'\001'})
+	contains := func(term []byte) bool {
+		for _, v := range values {
+			if bytes.Equal(v, term) {
+				return true
+			}
+		}
+		return false
+	}
+	switch tf.IsUnion {
+	case 0: // and
+		for _, term := range terms {
+			if !contains(term) {
+				return false
+			}
+		}
+		return true
+	case 2: // not
+		for _, term := range terms {
+			if contains(term) {
+				return false
+			}
+		}
+		return true
+	default: // or
+		for _, term := range terms {
+			if contains(term) {
+				return true
+			}
+		}
+		return false
+	}
+}
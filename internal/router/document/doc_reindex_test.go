@@ -0,0 +1,458 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/server/vearchhttp"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+	"github.com/vearch/vearch/v3/internal/ps/engine/mapping"
+)
+
+func TestMatchRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		fieldType vearchpb.FieldType
+		rf        *vearchpb.RangeFilter
+		value     []byte
+		want      bool
+	}{
+		{
+			name:      "Int in range",
+			fieldType: vearchpb.FieldType_INT,
+			rf:        &vearchpb.RangeFilter{LowerValue: cbbytes.Int32ToByte(1), UpperValue: cbbytes.Int32ToByte(10)},
+			value:     cbbytes.Int32ToByte(5),
+			want:      true,
+		},
+		{
+			name:      "Int on excluded bound",
+			fieldType: vearchpb.FieldType_INT,
+			rf:        &vearchpb.RangeFilter{LowerValue: cbbytes.Int32ToByte(1), UpperValue: cbbytes.Int32ToByte(10)},
+			value:     cbbytes.Int32ToByte(10),
+		},
+		{
+			name:      "Int on included bound",
+			fieldType: vearchpb.FieldType_INT,
+			rf:        &vearchpb.RangeFilter{LowerValue: cbbytes.Int32ToByte(1), UpperValue: cbbytes.Int32ToByte(10), IncludeUpper: true},
+			value:     cbbytes.Int32ToByte(10),
+			want:      true,
+		},
+		{
+			name:      "Long keeps precision",
+			fieldType: vearchpb.FieldType_LONG,
+			rf:        &vearchpb.RangeFilter{LowerValue: cbbytes.Int64ToByte(1 << 60), UpperValue: cbbytes.Int64ToByte(1<<60 + 2)},
+			value:     cbbytes.Int64ToByte(1<<60 + 1),
+			want:      true,
+		},
+		{
+			name:      "Date out of range",
+			fieldType: vearchpb.FieldType_DATE,
+			rf:        &vearchpb.RangeFilter{LowerValue: cbbytes.Int64ToByte(100), UpperValue: cbbytes.Int64ToByte(200), IncludeLower: true, IncludeUpper: true},
+			value:     cbbytes.Int64ToByte(99),
+		},
+		{
+			name:      "Float in range",
+			fieldType: vearchpb.FieldType_FLOAT,
+			rf:        &vearchpb.RangeFilter{LowerValue: cbbytes.Float32ToByte(0.5), UpperValue: cbbytes.Float32ToByte(1.5)},
+			value:     cbbytes.Float32ToByte(1),
+			want:      true,
+		},
+		{
+			name:      "Double below range",
+			fieldType: vearchpb.FieldType_DOUBLE,
+			rf:        &vearchpb.RangeFilter{LowerValue: cbbytes.Float64ToByteNew(0.5), UpperValue: cbbytes.Float64ToByteNew(1.5)},
+			value:     cbbytes.Float64ToByteNew(0.25),
+		},
+		{
+			name:      "String never matches",
+			fieldType: vearchpb.FieldType_STRING,
+			rf:        &vearchpb.RangeFilter{},
+			value:     []byte("a"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchRange(tt.rf, tt.fieldType, tt.value); got != tt.want {
+				t.Fatalf("matchRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchTerm(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms string
+		union int32
+		value string
+		want  bool
+	}{
+		{name: "Or matches one", terms: "a\001b", union: 1, value: "b", want: true},
+		{name: "Or matches none", terms: "a\001b", union: 1, value: "c"},
+		{name: "And matches all", terms: "a\001b", union: 0, value: "b\001a\001c", want: true},
+		{name: "And misses one", terms: "a\001b", union: 0, value: "a"},
+		{name: "Not matches none", terms: "a\001b", union: 2, value: "c", want: true},
+		{name: "Not matches one", terms: "a\001b", union: 2, value: "c\001a"},
+		{name: "Whole term only", terms: "ab", union: 1, value: "a\001b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tf := &vearchpb.TermFilter{Value: []byte(tt.terms), IsUnion: tt.union}
+			if got := matchTerm(tf, []byte(tt.value)); got != tt.want {
+				t.Fatalf("matchTerm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReindexDocument(t *testing.T) {
+	task := &reindexTask{
+		dest: &entity.Space{Index: &entity.Index{Type: "FLAT"}},
+		destMap: testProperties(t, `[
+			{"name": "title", "type": "string"},
+			{"name": "lang", "type": "string", "default": "en"},
+			{"name": "vec", "type": "vector", "dimension": 2}
+		]`),
+		drop: map[string]bool{"secret": true},
+	}
+	id := &vearchpb.Field{Name: mapping.IdField, Value: []byte("doc1")}
+	docid := &vearchpb.Field{Name: nextDocidField, Value: cbbytes.Int32ToByte(3)}
+	name := &vearchpb.Field{Name: "name", Value: []byte("t")}
+	secret := &vearchpb.Field{Name: "secret", Value: []byte("s")}
+	vec := &vearchpb.Field{Name: "vec", Value: cbbytes.Float32ToByte(1)}
+
+	tests := []struct {
+		name    string
+		fields  []*vearchpb.Field
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "Map drop and fill",
+			fields: []*vearchpb.Field{id, docid, name, secret, vec},
+			want:   map[string]string{"title": "t", "lang": "en", "vec": string(vec.Value)},
+		},
+		{name: "Missing vector", fields: []*vearchpb.Field{id, name}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := reindexDocument(0, tt.fields, task, map[string]string{"name": "title"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reindexDocument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if doc.PKey != "doc1" {
+				t.Fatalf("reindexDocument() key = %s, want doc1", doc.PKey)
+			}
+			got := make(map[string]string, len(doc.Fields))
+			for _, f := range doc.Fields {
+				got[f.Name] = string(f.Value)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("reindexDocument() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Fatalf("reindexDocument() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchFilters(t *testing.T) {
+	task := &reindexTask{
+		sourceMap:    testProperties(t, `[{"name": "age", "type": "integer"}, {"name": "tag", "type": "string"}]`),
+		rangeFilters: []*vearchpb.RangeFilter{{Field: "age", LowerValue: cbbytes.Int32ToByte(18), UpperValue: cbbytes.Int32ToByte(60), IncludeLower: true}},
+		termFilters:  []*vearchpb.TermFilter{{Field: "tag", Value: []byte("a"), IsUnion: 1}},
+	}
+	tag := &vearchpb.Field{Name: "tag", Value: []byte("a")}
+	if !matchFilters([]*vearchpb.Field{{Name: "age", Value: cbbytes.Int32ToByte(18)}, tag}, task) {
+		t.Fatalf("matchFilters() = false, want true")
+	}
+	if matchFilters([]*vearchpb.Field{{Name: "age", Value: cbbytes.Int32ToByte(60)}, tag}, task) {
+		t.Fatalf("matchFilters() = true for out of range, want false")
+	}
+	if matchFilters([]*vearchpb.Field{tag}, task) {
+		t.Fatalf("matchFilters() = true for missing field, want false")
+	}
+}
+
+type fakeReindexValue struct {
+	value []byte
+	ttl   time.Duration
+}
+
+// fakeReindexStore keeps values in memory
+type fakeReindexStore struct {
+	mu     sync.Mutex
+	values map[string]fakeReindexValue
+}
+
+func newFakeReindexStore() *fakeReindexStore {
+	return &fakeReindexStore{values: make(map[string]fakeReindexValue)}
+}
+
+func (s *fakeReindexStore) Put(ctx context.Context, key string, value []byte) error {
+	return s.CreateWithTTL(ctx, key, value, 0)
+}
+
+func (s *fakeReindexStore) CreateWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = fakeReindexValue{value: value, ttl: ttl}
+	return nil
+}
+
+func (s *fakeReindexStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key].value, nil
+}
+
+func (s *fakeReindexStore) PrefixScan(ctx context.Context, prefix string) ([][]byte, [][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys, values [][]byte
+	for k, v := range s.values {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, []byte(k))
+			values = append(values, v.value)
+		}
+	}
+	return keys, values, nil
+}
+
+func (s *fakeReindexStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	return nil
+}
+
+func (s *fakeReindexStore) putJob(t *testing.T, job *reindexJob) {
+	t.Helper()
+	value, err := vjson.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(context.Background(), entity.ReindexJobKey(job.ID), value)
+}
+
+func TestSaveReindexJob(t *testing.T) {
+	store := newFakeReindexStore()
+	handler := &DocumentHandler{reindexStore: store}
+	job := &reindexJob{ID: "job1", Request: &request.ReindexRequest{}, Status: ReindexRunning, StartTime: time.Now()}
+
+	if err := handler.saveReindexJob(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	if v := store.values[entity.ReindexJobKey("job1")]; v.value == nil || v.ttl != 0 {
+		t.Fatalf("running job saved with ttl %v, want no ttl", v.ttl)
+	}
+
+	job.finish(ReindexFinished)
+	if err := handler.saveReindexJob(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	if v := store.values[entity.ReindexJobKey("job1")]; v.ttl != reindexJobRetention {
+		t.Fatalf("finished job saved with ttl %v, want %v", v.ttl, reindexJobRetention)
+	}
+
+	loaded, err := handler.loadReindexJob(context.Background(), "job1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded == nil || loaded.Status != ReindexFinished || loaded.UpdateTime.IsZero() {
+		t.Fatalf("loadReindexJob() = %+v, want finished job", loaded)
+	}
+	if loaded, _ = handler.loadReindexJob(context.Background(), "none"); loaded != nil {
+		t.Fatalf("loadReindexJob() = %+v, want nil", loaded)
+	}
+}
+
+func TestLoadReindexJobs(t *testing.T) {
+	store := newFakeReindexStore()
+	handler := &DocumentHandler{reindexStore: store}
+	now := time.Now()
+	store.putJob(t, &reindexJob{ID: "running", Status: ReindexRunning, StartTime: now.Add(-time.Minute), UpdateTime: now})
+	store.putJob(t, &reindexJob{ID: "lost", Status: ReindexRunning, StartTime: now.Add(-time.Hour), UpdateTime: now.Add(-2 * reindexJobLostTime)})
+	store.putJob(t, &reindexJob{ID: "expired", Status: ReindexRunning, StartTime: now.Add(-48 * time.Hour), UpdateTime: now.Add(-reindexJobRetention - time.Hour)})
+	store.putJob(t, &reindexJob{ID: "finished", Status: ReindexFinished, StartTime: now.Add(-2 * time.Hour), UpdateTime: now.Add(-2 * time.Hour)})
+
+	jobs, err := handler.loadReindexJobs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(jobs))
+	status := make(map[string]string, len(jobs))
+	for _, job := range jobs {
+		got = append(got, job.ID)
+		status[job.ID] = job.Status
+	}
+	if strings.Join(got, ",") != "finished,lost,running" {
+		t.Fatalf("loadReindexJobs() = %v, want jobs by start time", got)
+	}
+	if status["lost"] != ReindexFailed || status["running"] != ReindexRunning || status["finished"] != ReindexFinished {
+		t.Fatalf("loadReindexJobs() status = %v", status)
+	}
+	if _, ok := store.values[entity.ReindexJobKey("expired")]; ok {
+		t.Fatalf("lost job out of retention is not removed")
+	}
+}
+
+func TestHandleReindexCancel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := newFakeReindexStore()
+	handler := &DocumentHandler{reindexStore: store}
+	store.putJob(t, &reindexJob{ID: "remote", Status: ReindexRunning, StartTime: time.Now(), UpdateTime: time.Now()})
+
+	canceled := false
+	handler.reindexJobs.Store("local", &reindexJob{ID: "local", Status: ReindexRunning, cancel: func() { canceled = true }})
+
+	tests := []struct {
+		name       string
+		id         string
+		wantCode   int
+		wantCancel bool
+	}{
+		{name: "Local job", id: "local", wantCode: http.StatusOK},
+		{name: "Job of other router", id: "remote", wantCode: http.StatusOK, wantCancel: true},
+		{name: "Unknown job", id: "none", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodDelete, "/reindex/"+tt.id, nil)
+			c.Params = gin.Params{{Key: URLParamJobID, Value: tt.id}}
+			handler.handleReindexCancel(c)
+			if w.Code != tt.wantCode {
+				t.Fatalf("handleReindexCancel() code = %d, want %d, body %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			_, put := store.values[entity.ReindexCancelKey(tt.id)]
+			if put != tt.wantCancel {
+				t.Fatalf("cancel key put = %v, want %v", put, tt.wantCancel)
+			}
+		})
+	}
+	if !canceled {
+		t.Fatalf("local job is not canceled")
+	}
+}
+
+func TestReindexJobsVisible(t *testing.T) {
+	store := newFakeReindexStore()
+	handler := &DocumentHandler{reindexStore: store, users: fakeUsers{"db/s1": entity.PrivilegeSelect, "db/s2": entity.PrivilegeSelect}}
+	now := time.Now()
+	reindexSpaces := func(source, dest string) *request.ReindexRequest {
+		return &request.ReindexRequest{Source: request.ReindexSpace{DbName: "db", SpaceName: source}, Dest: request.ReindexSpace{DbName: "db", SpaceName: dest}}
+	}
+	store.putJob(t, &reindexJob{ID: "visible", Request: reindexSpaces("s1", "s2"), Status: ReindexFinished, StartTime: now, UpdateTime: now})
+	store.putJob(t, &reindexJob{ID: "other_source", Request: reindexSpaces("s3", "s2"), Status: ReindexFinished, StartTime: now, UpdateTime: now})
+	store.putJob(t, &reindexJob{ID: "other_dest", Request: reindexSpaces("s1", "s3"), Status: ReindexRunning, StartTime: now, UpdateTime: now})
+	canceled := false
+	handler.reindexJobs.Store("other_dest", &reindexJob{ID: "other_dest", Request: reindexSpaces("s1", "s3"), Status: ReindexRunning, cancel: func() { canceled = true }})
+
+	c, w := testUserContext(http.MethodGet, "/reindex")
+	handler.handleReindexList(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handleReindexList() code = %d, body %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"visible"`) || strings.Contains(body, "other_source") || strings.Contains(body, "other_dest") {
+		t.Fatalf("handleReindexList() = %s, want only the job of spaces the user can select", body)
+	}
+
+	// without auth every job is listed
+	c, w = testUserContext(http.MethodGet, "/reindex")
+	delete(c.Keys, vearchhttp.UserKey)
+	handler.handleReindexList(c)
+	if body := w.Body.String(); !strings.Contains(body, "other_source") || !strings.Contains(body, "other_dest") {
+		t.Fatalf("handleReindexList() without auth = %s, want every job", body)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		id       string
+		serve    func(c *gin.Context)
+		wantCode int
+	}{
+		{name: "Get visible job", id: "visible", serve: handler.handleReindexGet, wantCode: http.StatusOK},
+		{name: "Get job of other space", id: "other_source", serve: handler.handleReindexGet, wantCode: http.StatusNotFound},
+		{name: "Cancel local job of other space", id: "other_dest", serve: handler.handleReindexCancel, wantCode: http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := testUserContext(http.MethodGet, "/reindex/"+tt.id)
+			c.Params = gin.Params{{Key: URLParamJobID, Value: tt.id}}
+			tt.serve(c)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d, body %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+	if canceled {
+		t.Fatalf("job of space the user can not select is canceled")
+	}
+}
+
+func TestHandleReindexPrivilege(t *testing.T) {
+	// the handler has no client, the spaces are never got if denied
+	handler := &DocumentHandler{
+		users: fakeUsers{"db/s1": entity.PrivilegeSelect, "db/s2": entity.PrivilegeSelect},
+		aliasOf: func(ctx context.Context, name string) (*entity.Alias, error) {
+			switch name {
+			case "a1":
+				return &entity.Alias{Name: name, DbName: "db", SpaceName: "s1"}, nil
+			case "a3":
+				return &entity.Alias{Name: name, DbName: "db", SpaceName: "s3"}, nil
+			}
+			return nil, fmt.Errorf("alias_name:[%s] not found", name)
+		},
+	}
+	for _, tt := range []struct {
+		name      string
+		body      string
+		wantSpace string
+	}{
+		{name: "Source not exist", body: `{"source": {"db_name": "db", "space_name": "missing"}, "dest": {"db_name": "db", "space_name": "s2"}}`, wantSpace: "db/missing"},
+		{name: "Dest without insert", body: `{"source": {"db_name": "db", "space_name": "a1"}, "dest": {"db_name": "db", "space_name": "s2"}}`, wantSpace: "db/s2"},
+		{name: "Source alias of other space", body: `{"source": {"db_name": "db", "space_name": "a3"}, "dest": {"db_name": "db", "space_name": "s2"}}`, wantSpace: "db/s3"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := testUserContext(http.MethodPost, "/reindex")
+			c.Request = httptest.NewRequest(http.MethodPost, "/reindex", strings.NewReader(tt.body))
+			handler.handleReindex(c)
+			if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), tt.wantSpace) {
+				t.Fatalf("handleReindex() code = %d, body %s, want 403 on %s", w.Code, w.Body.String(), tt.wantSpace)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/monitor"
+	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
+	"github.com/vearch/vearch/v3/internal/pkg/expr"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vjson"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	RerankHTTP       = "http"
+	RerankExpression = "expression"
+
+	ScoreVar = "_score"
+
+	defaultRerankTimeoutMs = 1000
+	maxRerankTopK          = 10000
+)
+
+var rerankClient = &http.Client{}
+
+// rerankRequest and rerankResponse are the cross-encoder rerank protocol
+// shared by the common rerank services
+type rerankRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+}
+
+type rerankResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+}
+
+// reranker reorders the merged results of a search, if it fails the hits
+// keep the order of the search
+type reranker struct {
+	conf     *request.Rerank
+	endpoint *config.RerankerCfg
+	expr     *expr.Expression
+	proMap   map[string]*entity.SpaceProperties
+	limit    int32
+	fields   []string
+	added    []string // fields not asked by the search
+}
+
+func newReranker(searchDoc *request.SearchDocumentRequest, space *entity.Space) (*reranker, error) {
+	conf := searchDoc.Rerank
+	proMap := space.SpaceProperties
+	if proMap == nil {
+		proMap, _ = entity.UnmarshalPropertyJSON(space.Fields)
+	}
+	r := &reranker{conf: conf, proMap: proMap, limit: searchDoc.Limit}
+	if r.limit <= 0 {
+		r.limit = DefaultSize
+	}
+	if conf.TopK < 0 || conf.TopK > maxRerankTopK {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rerank top_k should be in [0, %d]", maxRerankTopK))
+	}
+	if conf.TimeoutMs < 0 {
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rerank timeout_ms can not be negative"))
+	}
+
+	switch conf.Type {
+	case RerankHTTP:
+		if conf.URL != "" || conf.APIKey != "" {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rerank url and api_key are not accepted, pick a reranker of router config by name"))
+		}
+		r.endpoint = rerankerOf(conf.Name)
+		if r.endpoint == nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rerank name:[%s] is not a reranker of router config", conf.Name))
+		}
+		u, err := url.Parse(r.endpoint.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_INTERNAL_ERROR, fmt.Errorf("url:[%s] of reranker:[%s] should be http or https url", r.endpoint.URL, conf.Name))
+		}
+		if conf.Query == "" {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rerank query is empty"))
+		}
+		pro := proMap[conf.TextField]
+		if pro == nil || pro.FieldType != vearchpb.FieldType_STRING {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rerank text_field:[%s] should be string field of space", conf.TextField))
+		}
+		r.fields = []string{conf.TextField}
+	case RerankExpression:
+		e, err := expr.Parse(conf.Expression)
+		if err != nil {
+			return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rerank %s", err.Error()))
+		}
+		for _, name := range e.Vars() {
+			if name == ScoreVar {
+				continue
+			}
+			if pro := proMap[name]; pro == nil || !isNumericField(pro.FieldType) {
+				return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("rerank expression variable:[%s] should be _score or numeric field of space", name))
+			}
+			r.fields = append(r.fields, name)
+		}
+		r.expr = e
+	default:
+		return nil, vearchpb.NewError(vearchpb.ErrorEnum_PARAM_ERROR, fmt.Errorf("unsupport rerank type: %s, now only support %s and %s", conf.Type, RerankHTTP, RerankExpression))
+	}
+	return r, nil
+}
+
+// rerankerOf returns the reranker of router config named name, nil if not
+// found
+func rerankerOf(name string) *config.RerankerCfg {
+	if name == "" || config.Conf() == nil || config.Conf().Router == nil {
+		return nil
+	}
+	return config.Conf().Router.Rerankers[name]
+}
+
+// prepare fetches top_k candidates with the fields the reranker needs
+func (r *reranker) prepare(req *vearchpb.SearchRequest) {
+	r.added = fetchCandidates(req, r.conf.TopK, r.fields)
+}
+
+// fetchCandidates raises the topN of search to topK and adds fields to
+// the returned fields, it returns the added fields
+func fetchCandidates(req *vearchpb.SearchRequest, topK int32, fields []string) []string {
+	if topK > req.TopN {
+		req.TopN = topK
+	}
+	var added []string
+	for _, name := range fields {
+		found := false
+		for _, f := range req.Fields {
+			if f == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			req.Fields = append(req.Fields, name)
+			added = append(added, name)
+		}
+	}
+	return added
+}
+
+// dropSourceFields removes the fields only fetched for scoring from the
+// source of hits
+func dropSourceFields(results []*vearchpb.SearchResult, fields []string) {
+	if len(fields) == 0 {
+		return
+	}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		for _, item := range result.ResultItems {
+			if len(item.Source) == 0 {
+				continue
+			}
+			source := make(map[string]json.RawMessage)
+			if err := vjson.Unmarshal(item.Source, &source); err != nil {
+				log.Error("drop fields unmarshal source err: %s", err.Error())
+				continue
+			}
+			for _, field := range fields {
+				delete(source, field)
+			}
+			var err error
+			if item.Source, err = vjson.Marshal(source); err != nil {
+				log.Error("drop fields marshal source err: %s", err.Error())
+			}
+		}
+	}
+}
+
+// rerank reorders and truncates every result, each result falls back to
+// its original order on error or timeout and fallback is reported
+func (r *reranker) rerank(ctx context.Context, results []*vearchpb.SearchResult) (fallback bool) {
+	defer monitor.Profiler("rerank", time.Now())
+
+	timeout := r.conf.TimeoutMs
+	if timeout <= 0 {
+		timeout = defaultRerankTimeoutMs
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+
+	for _, result := range results {
+		if result == nil || len(result.ResultItems) == 0 {
+			continue
+		}
+		var scores []float64
+		var err error
+		if r.conf.Type == RerankHTTP {
+			scores, err = r.httpScores(ctx, result.ResultItems)
+		} else {
+			scores, err = r.expressionScores(result.ResultItems)
+		}
+		if err != nil {
+			log.Warnf("rerank by %s failed, keep search order, err: %s", r.conf.Type, err.Error())
+			fallback = true
+		} else {
+			items := result.ResultItems
+			for i, item := range items {
+				item.Score = scores[i]
+			}
+			sort.SliceStable(items, func(i, j int) bool { return items[i].Score > items[j].Score })
+			result.MaxScore = items[0].Score
+		}
+		if len(result.ResultItems) > int(r.limit) {
+			result.ResultItems = result.ResultItems[:r.limit]
+		}
+	}
+	return fallback
+}
+
+func (r *reranker) expressionScores(items []*vearchpb.ResultItem) ([]float64, error) {
+	scores := make([]float64, len(items))
+	for i, item := range items {
+		vars := itemVars(item, r.proMap)
+		score, err := r.expr.Eval(vars)
+		if err != nil {
+			return nil, fmt.Errorf("document [%s] %s", item.PKey, err.Error())
+		}
+		scores[i] = score
+	}
+	return scores, nil
+}
+
+func (r *reranker) httpScores(ctx context.Context, items []*vearchpb.ResultItem) ([]float64, error) {
+	texts := make([]string, len(items))
+	for i, item := range items {
+		for _, f := range item.Fields {
+			if f.Name == r.conf.TextField {
+				texts[i] = string(f.Value)
+				break
+			}
+		}
+	}
+
+	body, err := json.Marshal(&rerankRequest{Model: r.endpoint.Model, Query: r.conf.Query, Documents: texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.endpoint.APIKeyEnv != "" {
+		key := os.Getenv(r.endpoint.APIKeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("environment variable:[%s] of reranker:[%s] api key is not set in router", r.endpoint.APIKeyEnv, r.conf.Name)
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := rerankClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status:[%d] response: %s", resp.StatusCode, string(data))
+	}
+
+	result := &rerankResponse{}
+	if err = vjson.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+	if len(result.Results) != len(items) {
+		return nil, fmt.Errorf("reranker returns %d scores for %d documents", len(result.Results), len(items))
+	}
+	scores := make([]float64, len(items))
+	seen := make([]bool, len(items))
+	for _, res := range result.Results {
+		if res.Index < 0 || res.Index >= len(items) || seen[res.Index] {
+			return nil, fmt.Errorf("reranker returns invalid index %d", res.Index)
+		}
+		seen[res.Index] = true
+		scores[res.Index] = res.RelevanceScore
+	}
+	return scores, nil
+}
+
+func isNumericField(fieldType vearchpb.FieldType) bool {
+	switch fieldType {
+	case vearchpb.FieldType_INT, vearchpb.FieldType_LONG, vearchpb.FieldType_FLOAT,
+		vearchpb.FieldType_DOUBLE, vearchpb.FieldType_DATE:
+		return true
+	}
+	return false
+}
+
+// itemVars resolves _score and numeric fields of a hit, a date is the
+// seconds since epoch, a null value is not resolved
+func itemVars(item *vearchpb.ResultItem, proMap map[string]*entity.SpaceProperties) expr.Vars {
+	return func(name string) (float64, bool) {
+		if name == ScoreVar {
+			return item.Score, true
+		}
+		pro := proMap[name]
+		if pro == nil {
+			return 0, false
+		}
+		for _, f := range item.Fields {
+			if f.Name != name || len(f.Value) == 0 {
+				continue
+			}
+			if pro.IsNull(f.Value) {
+				return 0, false
+			}
+			switch pro.FieldType {
+			case vearchpb.FieldType_INT:
+				return float64(cbbytes.Bytes2Int32(f.Value)), true
+			case vearchpb.FieldType_LONG:
+				return float64(cbbytes.Bytes2Int(f.Value)), true
+			case vearchpb.FieldType_DATE:
+				// a date is stored as unix nanoseconds
+				return float64(cbbytes.Bytes2Int(f.Value)) / 1e9, true
+			case vearchpb.FieldType_FLOAT, vearchpb.FieldType_DOUBLE:
+				return cbbytes.ByteToFloat64(f.Value), true
+			}
+			return 0, false
+		}
+		return 0, false
+	}
+}
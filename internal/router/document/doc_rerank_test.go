@@ -0,0 +1,163 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+func testRerankResults() []*vearchpb.SearchResult {
+	items := make([]*vearchpb.ResultItem, 0, 3)
+	for i, text := range []string{"a", "bbb", "cc"} {
+		items = append(items, &vearchpb.ResultItem{
+			PKey:   text,
+			Score:  float64(3 - i),
+			Fields: []*vearchpb.Field{{Name: "text", Type: vearchpb.FieldType_STRING, Value: []byte(text)}},
+			Source: []byte(`{"text":"` + text + `","title":"t"}`),
+		})
+	}
+	return []*vearchpb.SearchResult{{ResultItems: items}}
+}
+
+func testRerankKeys(results []*vearchpb.SearchResult) []string {
+	keys := make([]string, 0)
+	for _, item := range results[0].ResultItems {
+		keys = append(keys, item.PKey)
+	}
+	return keys
+}
+
+// testRerankerConfig configures reranker "ce" of url, its key is in env
+func testRerankerConfig(t *testing.T, url string) {
+	t.Helper()
+	t.Setenv("TEST_RERANKER_KEY", "secret")
+	testRouterConfig(t, fmt.Sprintf("[router.rerankers.ce]\nurl = %q\nmodel = \"ce-model\"\napi_key_env = \"TEST_RERANKER_KEY\"", url))
+}
+
+func TestNewRerankerHTTP(t *testing.T) {
+	testRerankerConfig(t, "http://127.0.0.1:1")
+	fields := `[{"name": "text", "type": "string"}]`
+	space := &entity.Space{Fields: []byte(fields), SpaceProperties: testProperties(t, fields)}
+	tests := []struct {
+		name    string
+		rerank  request.Rerank
+		wantErr bool
+	}{
+		{name: "Configured", rerank: request.Rerank{Name: "ce"}},
+		{name: "Not configured", rerank: request.Rerank{Name: "other"}, wantErr: true},
+		{name: "No name", rerank: request.Rerank{}, wantErr: true},
+		{name: "Url in request", rerank: request.Rerank{Name: "ce", URL: "http://10.0.0.1/admin"}, wantErr: true},
+		{name: "Api key in request", rerank: request.Rerank{Name: "ce", APIKey: "key"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := tt.rerank
+			conf.Type, conf.Query, conf.TextField = RerankHTTP, "q", "text"
+			_, err := newReranker(&request.SearchDocumentRequest{Rerank: &conf}, space)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newReranker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRerankFallback(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		req := &rerankRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.Model != "ce-model" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := &rerankResponse{}
+		for i, doc := range req.Documents {
+			resp.Results = append(resp.Results, struct {
+				Index          int     `json:"index"`
+				RelevanceScore float64 `json:"relevance_score"`
+			}{Index: i, RelevanceScore: float64(len(doc))})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+	testRerankerConfig(t, srv.URL)
+
+	fields := `[{"name": "text", "type": "string"}, {"name": "title", "type": "string"}]`
+	space := &entity.Space{Fields: []byte(fields), SpaceProperties: testProperties(t, fields)}
+	searchDoc := &request.SearchDocumentRequest{
+		Limit:  2,
+		Rerank: &request.Rerank{Type: RerankHTTP, Name: "ce", Query: "q", TextField: "text", TopK: 3},
+	}
+	rr, err := newReranker(searchDoc, space)
+	if err != nil {
+		t.Fatalf("newReranker() error = %v", err)
+	}
+
+	results := testRerankResults()
+	if rr.rerank(context.Background(), results) {
+		t.Errorf("rerank() reports fallback on success")
+	}
+	if got := testRerankKeys(results); !reflect.DeepEqual(got, []string{"bbb", "cc"}) {
+		t.Errorf("reranked = %v, want [bbb cc]", got)
+	}
+
+	status = http.StatusInternalServerError
+	results = testRerankResults()
+	if !rr.rerank(context.Background(), results) {
+		t.Errorf("rerank() does not report fallback on failure")
+	}
+	if got := testRerankKeys(results); !reflect.DeepEqual(got, []string{"a", "bbb"}) {
+		t.Errorf("fallback = %v, want [a bbb]", got)
+	}
+}
+
+func TestFetchCandidatesDropFields(t *testing.T) {
+	req := &vearchpb.SearchRequest{TopN: 2, Fields: []string{"title", "_id"}}
+	added := fetchCandidates(req, 10, []string{"title", "text"})
+	if req.TopN != 10 {
+		t.Errorf("TopN = %d, want 10", req.TopN)
+	}
+	if !reflect.DeepEqual(added, []string{"text"}) {
+		t.Errorf("added = %v, want [text]", added)
+	}
+	if !reflect.DeepEqual(req.Fields, []string{"title", "_id", "text"}) {
+		t.Errorf("Fields = %v, want [title _id text]", req.Fields)
+	}
+
+	results := testRerankResults()
+	dropSourceFields(results, added)
+	for _, item := range results[0].ResultItems {
+		if string(item.Source) != `{"title":"t"}` {
+			t.Errorf("source of %s = %s, want {\"title\":\"t\"}", item.PKey, item.Source)
+		}
+	}
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/vearch/vearch/v3/internal/client"
 	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/response"
 	"github.com/vearch/vearch/v3/internal/pkg/cbbytes"
 	"github.com/vearch/vearch/v3/internal/pkg/log"
 	"github.com/vearch/vearch/v3/internal/pkg/vjson"
@@ -95,7 +96,7 @@ func documentUpsertResponse(args *vearchpb.BulkRequest, reply *vearchpb.BulkResp
 
 	if reply.Head != nil && reply.Head.Err != nil {
 		if reply.Head.Err.Code != vearchpb.ErrorEnum_SUCCESS {
-			return nil, vearchpb.NewError(reply.Head.Err.Code, nil)
+			return nil, vearchpb.NewError(reply.Head.Err.Code, errors.New(reply.Head.Err.Msg))
 		}
 	}
 
@@ -115,10 +116,23 @@ func documentUpsertResponse(args *vearchpb.BulkRequest, reply *vearchpb.BulkResp
 	}
 
 	response["document_ids"] = documentIDs
+	if token := reply.Head.GetParams()[client.ConsistencyTokenParam]; token != "" {
+		response["consistency_token"] = token
+	}
 
 	return response, nil
 }
 
+// rejectedResponse is the documents rejected by parse of an upsert, it is
+// replied with the error of the bulk failed
+func rejectedResponse(rejected []*vearchpb.Item) map[string]interface{} {
+	documentIDs := make([]interface{}, 0, len(rejected))
+	for _, item := range rejected {
+		documentIDs = append(documentIDs, documentResultSerialize(item))
+	}
+	return map[string]interface{}{"total": 0, "document_ids": documentIDs}
+}
+
 func documentResultSerialize(item *vearchpb.Item) map[string]interface{} {
 	result := make(map[string]interface{})
 	if item == nil {
@@ -131,7 +145,10 @@ func documentResultSerialize(item *vearchpb.Item) map[string]interface{} {
 	result["_id"] = doc.PKey
 
 	if item.Err != nil {
-		if item.Err.Msg != "success" {
+		if item.Err.Code == vearchpb.ErrorEnum_PARAM_ERROR {
+			result["code"] = http.StatusBadRequest
+			result["msg"] = item.Err.Msg
+		} else if item.Err.Msg != "success" {
 			result["code"] = http.StatusNotFound
 			result["msg"] = item.Err.Msg
 		}
@@ -196,6 +213,20 @@ func documentGetResponse(client *client.Client, args *vearchpb.GetRequest, reply
 	return response, nil
 }
 
+// failedPartitions decodes the partitions failed in a search or query
+// answered with partial results, nil if all of them answered
+func failedPartitions(head *vearchpb.ResponseHead) []*response.PartitionFailure {
+	if head == nil || head.Params == nil || head.Params[client.FailedPartitionsParam] == "" {
+		return nil
+	}
+	var failures []*response.PartitionFailure
+	if err := vjson.Unmarshal([]byte(head.Params[client.FailedPartitionsParam]), &failures); err != nil {
+		log.Error("unmarshal failed partitions err: %s", err.Error())
+		return nil
+	}
+	return failures
+}
+
 func documentQueryResponse(srs []*vearchpb.SearchResult, head *vearchpb.ResponseHead) (map[string]interface{}, error) {
 	response := make(map[string]interface{})
 
@@ -373,6 +404,9 @@ func documentDeleteResponse(items []*vearchpb.Item, head *vearchpb.ResponseHead,
 	if len(resultIds) == 0 {
 		response["document_ids"] = []string{}
 	}
+	if token := head.GetParams()[client.ConsistencyTokenParam]; token != "" {
+		response["consistency_token"] = token
+	}
 
 	return response, nil
 }
@@ -447,6 +481,9 @@ func docFieldSerialize(doc *vearchpb.Document, space *entity.Space, returnFields
 			default:
 				log.Warn("can not set value by type:[%v] ", field.FieldType)
 			}
+			if field.IsNull(fv.Value) {
+				docOut[name] = nil
+			}
 		}
 	}
 
@@ -589,6 +626,9 @@ func deleteByQueryResult(resp *vearchpb.DelByQueryeResponse) (map[string]interfa
 	} else {
 		result["document_ids"] = []string{}
 	}
+	if token := resp.Head.GetParams()[client.ConsistencyTokenParam]; token != "" {
+		result["consistency_token"] = token
+	}
 
 	return result, nil
 }
@@ -28,6 +28,7 @@ import (
 )
 
 const defaultRpcTimeOut int64 = 10 * 1000 // 10 second
+const defaultBatchRpcTimeOut int64 = 3 * 1000
 
 type docService struct {
 	client *client.Client
@@ -44,6 +45,12 @@ func setTimeOut(ctx context.Context, head *vearchpb.RequestHead) (context.Contex
 	if config.Conf().Router.RpcTimeOut > 0 {
 		timeout = int64(config.Conf().Router.RpcTimeOut)
 	}
+	if head.Params[client.PriorityParam] == client.PriorityBatch {
+		timeout = defaultBatchRpcTimeOut
+		if config.Conf().Router.BatchRpcTimeOut > 0 {
+			timeout = int64(config.Conf().Router.BatchRpcTimeOut)
+		}
+	}
 	if head.TimeOutMs > 0 {
 		timeout = head.TimeOutMs
 	}
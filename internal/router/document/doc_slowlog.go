@@ -0,0 +1,204 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/entity/response"
+	"github.com/vearch/vearch/v3/internal/pkg/log"
+	"github.com/vearch/vearch/v3/internal/pkg/vearchlog"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+const (
+	slowLogKey              = "slow_log"
+	slowLogModule           = "SLOW"
+	defaultSlowLogBodyLimit = 1024
+)
+
+var (
+	slowLogOnce   sync.Once
+	slowLogWriter log.Log
+)
+
+// slowLogEntry is a line of slow log, the handlers fill what they know
+// about the request
+type slowLogEntry struct {
+	Time          string                       `json:"time"`
+	Operation     string                       `json:"operation"`
+	DbName        string                       `json:"db_name,omitempty"`
+	SpaceName     string                       `json:"space_name,omitempty"`
+	User          string                       `json:"user,omitempty"`
+	Took          float64                      `json:"took_ms"`
+	Status        int                          `json:"status"`
+	Limit         int32                        `json:"limit,omitempty"`
+	Documents     int                          `json:"documents,omitempty"`
+	Vectors       []string                     `json:"vectors,omitempty"`
+	Filter        string                       `json:"filter,omitempty"`
+	Partitions    int                          `json:"partitions,omitempty"`
+	PartitionTook []*response.PartitionExplain `json:"partition_took,omitempty"`
+	Body          string                       `json:"body,omitempty"`
+	BodyTruncated bool                         `json:"body_truncated,omitempty"`
+}
+
+func slowLog() log.Log {
+	slowLogOnce.Do(func() {
+		slowLogWriter = vearchlog.NewVearchLog(config.Conf().GetLogDir(), slowLogModule, "INFO", false)
+	})
+	return slowLogWriter
+}
+
+// slowQuery is the middleware of document apis, it writes the requests
+// slower than router slow_query_ms to slow log
+func slowQuery(operation string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Conf().Router
+		if cfg.SlowQueryMs <= 0 {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		limit := cfg.SlowQueryBodyLimit
+		if limit == 0 {
+			limit = defaultSlowLogBodyLimit
+		}
+		// the sample is taken before the request, only the head of body of
+		// sampled requests is kept while the handler reads it
+		var body *bodyHead
+		if limit > 0 && c.Request.Body != nil && (cfg.SlowQuerySampleRate <= 0 || rand.Float64() < cfg.SlowQuerySampleRate) {
+			body = &bodyHead{limit: limit}
+			c.Request.Body = &teeBody{Reader: io.TeeReader(c.Request.Body, body), Closer: c.Request.Body}
+		}
+		entry := &slowLogEntry{Operation: operation}
+		c.Set(slowLogKey, entry)
+
+		c.Next()
+
+		took := time.Since(start)
+		if took < time.Duration(cfg.SlowQueryMs)*time.Millisecond {
+			return
+		}
+		entry.Time = start.Format(time.RFC3339Nano)
+		entry.Took = took.Seconds() * 1000
+		entry.Status = c.Writer.Status()
+		entry.User = c.GetString(gin.AuthUserKey)
+		if body != nil {
+			entry.Body = redactSecrets(body.data)
+			entry.BodyTruncated = body.truncated
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			log.Error("marshal slow log err: %s", err.Error())
+			return
+		}
+		slowLog().Info(string(data))
+	}
+}
+
+// secretPattern matches the string values of secret keys in a json body, a
+// value cut by the body limit is matched to its end
+var secretPattern = regexp.MustCompile(`("(?:api_key|password|secret|token)"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+
+// redactSecrets blanks the values of secret keys in body
+func redactSecrets(body []byte) string {
+	return secretPattern.ReplaceAllString(string(body), `$1"***"`)
+}
+
+// bodyHead keeps the first limit bytes written to it
+type bodyHead struct {
+	data      []byte
+	limit     int
+	truncated bool
+}
+
+func (h *bodyHead) Write(p []byte) (int, error) {
+	n := min(len(p), h.limit-len(h.data))
+	if n < len(p) {
+		h.truncated = true
+	}
+	h.data = append(h.data, p[:n]...)
+	return len(p), nil
+}
+
+// teeBody is the request body copied to bodyHead as it is read
+type teeBody struct {
+	io.Reader
+	io.Closer
+}
+
+// slowLogOf returns the slow log entry of request, it is nil if slow log
+// is off
+func slowLogOf(c *gin.Context) *slowLogEntry {
+	if v, ok := c.Get(slowLogKey); ok {
+		return v.(*slowLogEntry)
+	}
+	return nil
+}
+
+// setSearch records the search or query of entry, the partitions report
+// their latency by the partition took param
+func (entry *slowLogEntry) setSearch(searchDoc *request.SearchDocumentRequest, space *entity.Space, head *vearchpb.RequestHead, vqs []*vearchpb.VectorQuery) {
+	if entry == nil {
+		return
+	}
+	for _, vq := range vqs {
+		entry.Vectors = append(entry.Vectors, vq.Name)
+	}
+	entry.DbName, entry.SpaceName = head.DbName, head.SpaceName
+	entry.Limit = searchDoc.Limit
+	if entry.Limit == 0 {
+		entry.Limit = DefaultSize
+	}
+	entry.Filter = filterShape(searchDoc.Filters)
+	if searchDoc.PartitionId != nil {
+		entry.Partitions = 1
+	} else if space != nil {
+		entry.Partitions = len(space.Partitions)
+	}
+	head.Params[client.PartitionTookParam] = "true"
+}
+
+func (entry *slowLogEntry) setPartitions(head *vearchpb.ResponseHead) {
+	if entry == nil {
+		return
+	}
+	entry.PartitionTook = partitionExplains(head)
+}
+
+// filterShape is the filter without values, such as AND(age >=, city IN)
+func filterShape(filters *request.Filter) string {
+	if filters == nil {
+		return ""
+	}
+	conditions := make([]string, 0, len(filters.Conditions))
+	for _, cond := range filters.Conditions {
+		conditions = append(conditions, cond.Field+" "+cond.Operator)
+	}
+	return strings.ToUpper(filters.Operator) + "(" + strings.Join(conditions, ", ") + ")"
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 The Vearch Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package document
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vearch/vearch/v3/internal/client"
+	"github.com/vearch/vearch/v3/internal/config"
+	"github.com/vearch/vearch/v3/internal/entity"
+	"github.com/vearch/vearch/v3/internal/entity/request"
+	"github.com/vearch/vearch/v3/internal/proto/vearchpb"
+)
+
+// testRouterConfig loads the router section of config for the test
+func testRouterConfig(t *testing.T, router string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	conf := fmt.Sprintf("[global]\nlog = %q\n\n[router]\n%s\n", dir, router)
+	if err := os.WriteFile(path, []byte(conf), 0644); err != nil {
+		t.Fatalf("write config err: %v", err)
+	}
+	config.InitConfig(path)
+}
+
+func TestSlowQueryBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := "0123456789abcdef"
+	tests := []struct {
+		name          string
+		router        string
+		wantWrapped   bool
+		wantBody      string
+		wantTruncated bool
+	}{
+		{name: "Sampled", router: "slow_query_ms = 1\nslow_query_body_limit = 32", wantWrapped: true, wantBody: body},
+		{name: "Truncated", router: "slow_query_ms = 1\nslow_query_body_limit = 8", wantWrapped: true, wantBody: "01234567", wantTruncated: true},
+		{name: "Not sampled", router: "slow_query_ms = 1\nslow_query_sample_rate = 1e-300"},
+		{name: "No body", router: "slow_query_ms = 1\nslow_query_body_limit = -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRouterConfig(t, tt.router)
+			var entry *slowLogEntry
+			var wrapped bool
+			var read string
+			engine := gin.New()
+			engine.POST("/document/search", slowQuery("search"), func(c *gin.Context) {
+				entry = slowLogOf(c)
+				_, wrapped = c.Request.Body.(*teeBody)
+				data, _ := io.ReadAll(c.Request.Body)
+				read = string(data)
+				time.Sleep(2 * time.Millisecond)
+			})
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/document/search", strings.NewReader(body)))
+
+			if read != body {
+				t.Errorf("handler read %q, want %q", read, body)
+			}
+			if wrapped != tt.wantWrapped {
+				t.Errorf("body buffered = %v, want %v", wrapped, tt.wantWrapped)
+			}
+			if entry == nil || entry.Took == 0 {
+				t.Fatalf("slow request is not logged: %+v", entry)
+			}
+			if entry.Body != tt.wantBody || entry.BodyTruncated != tt.wantTruncated {
+				t.Errorf("logged body = %q truncated %v, want %q truncated %v", entry.Body, entry.BodyTruncated, tt.wantBody, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestSlowLogSetSearch(t *testing.T) {
+	entry := &slowLogEntry{Operation: "search"}
+	head := &vearchpb.RequestHead{DbName: "db", SpaceName: "space", Params: make(map[string]string)}
+	searchDoc := &request.SearchDocumentRequest{
+		Filters: &request.Filter{Operator: "and", Conditions: []request.Condition{{Field: "age", Operator: ">="}}},
+	}
+	space := &entity.Space{Partitions: []*entity.Partition{{Id: 1}, {Id: 2}}}
+	entry.setSearch(searchDoc, space, head, []*vearchpb.VectorQuery{{Name: "vec"}})
+
+	if head.Params[client.PartitionTookParam] != "true" {
+		t.Errorf("partition took is not asked: %v", head.Params)
+	}
+	if client.IsExplain(head) || newExplainer(searchDoc, space, head) != nil {
+		t.Errorf("slow log should not explain the search")
+	}
+	if entry.Filter != "AND(age >=)" || entry.Partitions != 2 || entry.Limit != DefaultSize {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	var nilEntry *slowLogEntry
+	head = &vearchpb.RequestHead{Params: make(map[string]string)}
+	nilEntry.setSearch(searchDoc, space, head, nil)
+	if len(head.Params) != 0 {
+		t.Errorf("slow log off changes params: %v", head.Params)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "No secret", body: `{"limit": 10}`, want: `{"limit": 10}`},
+		{
+			name: "Rerank api key",
+			body: `{"rerank": {"type": "http", "api_key": "sk-\"x\"", "query": "q"}}`,
+			want: `{"rerank": {"type": "http", "api_key": "***", "query": "q"}}`,
+		},
+		{name: "Password", body: `{"name":"u","password":"p@ss"}`, want: `{"name":"u","password":"***"}`},
+		{name: "Truncated value", body: `{"api_key": "sk-12`, want: `{"api_key": "***"`},
+		{name: "Not a string", body: `{"token": 1}`, want: `{"token": 1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactSecrets([]byte(tt.body)); got != tt.want {
+				t.Errorf("redactSecrets() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}